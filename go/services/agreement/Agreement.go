@@ -0,0 +1,500 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agreement extends the ifs.ServiceLevelAgreement with the service level
+// attributes that are specific to the l8services transaction and election layers.
+// Every node keeps its own Agreement of a service, by service name and area, from its
+// first use until the service is deactivated on the node. Attributes that must match
+// across the nodes of a service, like its commit policy, are set on the SLA, by OnSLA,
+// before activation, so every node activating the SLA starts with its own copy of them.
+package agreement
+
+import (
+	"sync"
+	"time"
+
+	"github.com/saichler/l8services/go/services/election"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// CommitPolicy defines how many of a transaction's targets must apply it for it to commit.
+type CommitPolicy int
+
+const (
+	// CommitAll requires every target to apply the transaction, any failure rolls it back.
+	CommitAll CommitPolicy = iota
+	// CommitMajority requires more than half of the targets to apply the transaction.
+	CommitMajority
+	// CommitQuorum requires a fixed number of the targets to apply the transaction.
+	CommitQuorum
+)
+
+// ReadConsistency defines which node serves a GET of a transactional service and what
+// it must have applied before serving it.
+type ReadConsistency int
+
+const (
+	// ReadStrong reads are served by the leader once all the transactions queued before
+	// them have finished.
+	ReadStrong ReadConsistency = iota
+	// ReadSession reads are served by the leader once the caller's last transaction has
+	// finished, without waiting for the transactions of other callers.
+	ReadSession
+	// ReadEventual reads are served by any participant from its own cache.
+	ReadEventual
+)
+
+// SplitBrainPolicy defines what a leader deposed after a partition healed does with the
+// writes of its deposed term that diverged from the new leader. Either way, the keys they
+// set are resynced from the new leader first.
+type SplitBrainPolicy int
+
+const (
+	// SplitBrainQuarantine keeps a copy of the divergent writes in quarantine, for the
+	// operators to reconcile or discard them.
+	SplitBrainQuarantine SplitBrainPolicy = iota
+	// SplitBrainReplay replays the divergent writes on the new leader, the ones it rejects,
+	// e.g. on a version conflict, stay in quarantine.
+	SplitBrainReplay
+)
+
+// ReplicaPlacement defines how the participants holding the replicas of a new key of a
+// replicated service are picked.
+type ReplicaPlacement int
+
+const (
+	// PlacementRoundRobin picks the participants used the least recently.
+	PlacementRoundRobin ReplicaPlacement = iota
+	// PlacementConsistentHash picks the participants following the key's hash on a ring of
+	// the participants, so every node picks the same ones and a participant joining or
+	// leaving only moves the keys next to it on the ring. The leader moves the existing
+	// keys to their new replicas once the participants change.
+	PlacementConsistentHash
+)
+
+// DefaultVirtualNodes is the number of points every participant has on the hash ring.
+const DefaultVirtualNodes = 128
+
+// Agreement holds the l8services specific attributes of a service level agreement.
+type Agreement struct {
+	maxConcurrent      int
+	transactionTimeout time.Duration
+	commitPolicy       CommitPolicy
+	commitQuorum       int
+	idempotencyWindow  time.Duration
+	preCommitTTL       time.Duration
+	repairInterval     time.Duration
+	versionField       string
+	managedRevision    bool
+	revisionStore      ifs.IStorage
+	readConsistency    ReadConsistency
+	electionStrategy   election.Strategy
+	electionTimings    ElectionTimings
+	timingsChanged     chan struct{}
+	splitBrainPolicy   SplitBrainPolicy
+	replicaPlacement   ReplicaPlacement
+	virtualNodes       int
+	unbalanced         bool
+	mtx                *sync.RWMutex
+}
+
+// IAgreements is implemented by the services keeping the Agreement of every service of
+// their node.
+type IAgreements interface {
+	Agreement(serviceName string, serviceArea byte) *Agreement
+}
+
+// Of returns the node's Agreement extending the given SLA, creating a default one on first use.
+func Of(sla *ifs.ServiceLevelAgreement, r ifs.IResources) *Agreement {
+	return For(r, sla.ServiceName(), sla.ServiceArea())
+}
+
+// carried holds the Agreement carried by every SLA, as ifs.ServiceLevelAgreement has no
+// place for the l8services attributes.
+var carried = &sync.Map{}
+
+// OnSLA returns the Agreement carried by an SLA, creating a default one on first use. Its
+// attributes are set before the SLA is activated, every node activating it copies them
+// to its own Agreement of the service.
+func OnSLA(sla *ifs.ServiceLevelAgreement) *Agreement {
+	existing, ok := carried.Load(sla)
+	if ok {
+		return existing.(*Agreement)
+	}
+	actual, _ := carried.LoadOrStore(sla, NewAgreement())
+	return actual.(*Agreement)
+}
+
+// Carried returns a copy of the Agreement carried by an SLA, or nil if it carries none.
+func Carried(sla *ifs.ServiceLevelAgreement) *Agreement {
+	existing, ok := carried.Load(sla)
+	if !ok {
+		return nil
+	}
+	return existing.(*Agreement).Copy()
+}
+
+// For returns the node's Agreement of a service and area, creating a default one on first
+// use. A node whose services do not keep agreements gets the default attributes.
+func For(r ifs.IResources, serviceName string, serviceArea byte) *Agreement {
+	if r != nil {
+		agreements, ok := r.Services().(IAgreements)
+		if ok {
+			return agreements.Agreement(serviceName, serviceArea)
+		}
+	}
+	return NewAgreement()
+}
+
+// NewAgreement creates an Agreement with the default attributes.
+func NewAgreement() *Agreement {
+	agr := &Agreement{}
+	agr.maxConcurrent = 1
+	agr.transactionTimeout = 30 * time.Second
+	agr.idempotencyWindow = 5 * time.Minute
+	agr.preCommitTTL = 10 * time.Minute
+	agr.repairInterval = 10 * time.Second
+	agr.electionTimings = DefaultElectionTimings()
+	agr.timingsChanged = make(chan struct{})
+	agr.virtualNodes = DefaultVirtualNodes
+	agr.mtx = &sync.RWMutex{}
+	return agr
+}
+
+// Copy returns an Agreement with the same attributes, and a copy of the election strategy,
+// so changing it does not change this one. The revision store is shared.
+func (this *Agreement) Copy() *Agreement {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	agr := *this
+	if copier, ok := this.electionStrategy.(election.Copier); ok {
+		agr.electionStrategy = copier.Copy()
+	}
+	agr.timingsChanged = make(chan struct{})
+	agr.mtx = &sync.RWMutex{}
+	return &agr
+}
+
+// SetMaxConcurrentTransactions sets how many transactions, on different keys, the node
+// may run in parallel while it is the leader of the service. Transactions on the same key
+// are always ordered. Values lower than 1 are treated as 1, fully serializing the
+// service's transactions.
+func (this *Agreement) SetMaxConcurrentTransactions(max int) *Agreement {
+	if max < 1 {
+		max = 1
+	}
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.maxConcurrent = max
+	return this
+}
+
+// MaxConcurrentTransactions returns how many transactions the node may run in parallel.
+func (this *Agreement) MaxConcurrentTransactions() int {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.maxConcurrent
+}
+
+// SetTransactionTimeout sets the default time a transaction of the service has to complete,
+// for transactions that were not created with their own timeout. Values lower than a second
+// are treated as a second.
+func (this *Agreement) SetTransactionTimeout(timeout time.Duration) *Agreement {
+	if timeout < time.Second {
+		timeout = time.Second
+	}
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.transactionTimeout = timeout
+	return this
+}
+
+// TransactionTimeout returns the default time a transaction of the service has to complete.
+func (this *Agreement) TransactionTimeout() time.Duration {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.transactionTimeout
+}
+
+// SetCommitPolicy sets how many targets must apply a transaction of the service for it to
+// commit. The quorum is only used by CommitQuorum, and is capped by the number of targets.
+// The targets that miss a committed transaction are recorded for repair.
+func (this *Agreement) SetCommitPolicy(policy CommitPolicy, quorum int) *Agreement {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.commitPolicy = policy
+	this.commitQuorum = quorum
+	return this
+}
+
+// CommitPolicy returns the commit policy of the service and its quorum.
+func (this *Agreement) CommitPolicy() (CommitPolicy, int) {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.commitPolicy, this.commitQuorum
+}
+
+// Required returns how many of the given number of targets must apply a transaction
+// for it to commit, according to the commit policy.
+func (this *Agreement) Required(targets int) int {
+	policy, quorum := this.CommitPolicy()
+	required := targets
+	switch policy {
+	case CommitMajority:
+		required = targets/2 + 1
+	case CommitQuorum:
+		required = quorum
+	}
+	if required > targets {
+		required = targets
+	}
+	if required < 1 && targets > 0 {
+		required = 1
+	}
+	return required
+}
+
+// SetIdempotencyWindow sets for how long the leader remembers the idempotency key a client
+// sent with a write, returning the original transaction's result to retries carrying the
+// same key.
+func (this *Agreement) SetIdempotencyWindow(window time.Duration) *Agreement {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.idempotencyWindow = window
+	return this
+}
+
+// IdempotencyWindow returns for how long the leader remembers the idempotency key of a request.
+func (this *Agreement) IdempotencyWindow() time.Duration {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.idempotencyWindow
+}
+
+// SetPreCommitTTL sets for how long a participant keeps the pre-commit state of a transaction
+// waiting for the leader's cleanup or rollback. Older entries are resolved with the leader
+// by the participant's sweeper. Zero, or a negative value, disables the sweeper.
+func (this *Agreement) SetPreCommitTTL(ttl time.Duration) *Agreement {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.preCommitTTL = ttl
+	return this
+}
+
+// PreCommitTTL returns for how long a participant keeps the pre-commit state of a transaction.
+func (this *Agreement) PreCommitTTL() time.Duration {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.preCommitTTL
+}
+
+// SetRepairInterval sets how often the leader replays the transactions its lagging peers
+// missed. Zero, or a negative value, disables the automatic repair.
+func (this *Agreement) SetRepairInterval(interval time.Duration) *Agreement {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.repairInterval = interval
+	return this
+}
+
+// RepairInterval returns how often the leader repairs its lagging peers.
+func (this *Agreement) RepairInterval() time.Duration {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.repairInterval
+}
+
+// SetVersionField names the integer field of the service's elements that holds their
+// version. A PUT or PATCH must carry the current version of the element, or it is rejected
+// as a conflict, and the service increments it on every accepted write. An empty name
+// disables the version checks.
+func (this *Agreement) SetVersionField(field string) *Agreement {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.versionField = field
+	return this
+}
+
+// VersionField returns the name of the field holding the version of the service's elements.
+func (this *Agreement) VersionField() string {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.versionField
+}
+
+// SetManagedRevision sets whether the service keeps a hidden revision of every element,
+// for elements that have no version field. The revision is incremented on every write,
+// including a delete. A PUT or PATCH must carry the current revision, in an L8Revisioned
+// or through the conditional writes, and a plain one is applied only to a new element.
+func (this *Agreement) SetManagedRevision(managed bool) *Agreement {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.managedRevision = managed
+	return this
+}
+
+// ManagedRevision returns whether the service keeps a hidden revision of every element.
+func (this *Agreement) ManagedRevision() bool {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.managedRevision
+}
+
+// SetRevisionStore sets the store persisting the managed revisions of the service's
+// elements, so they outlive a restart of the node. Without one they are kept in memory.
+func (this *Agreement) SetRevisionStore(store ifs.IStorage) *Agreement {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.revisionStore = store
+	return this
+}
+
+// RevisionStore returns the store persisting the managed revisions, nil if there is none.
+func (this *Agreement) RevisionStore() ifs.IStorage {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.revisionStore
+}
+
+// SetReadConsistency sets the consistency of the service's GETs that do not ask for one.
+// A session read needs the caller's last transaction, so as a default it reads strong.
+func (this *Agreement) SetReadConsistency(consistency ReadConsistency) *Agreement {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.readConsistency = consistency
+	return this
+}
+
+// ReadConsistency returns the consistency of the service's GETs that do not ask for one.
+func (this *Agreement) ReadConsistency() ReadConsistency {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.readConsistency
+}
+
+// SetElectionStrategy sets the strategy ranking the candidates of the service's leader
+// election, e.g. to prefer some nodes as leaders. Every node of the service should set
+// the same strategy. A nil strategy restores the default, the higher UUID wins.
+func (this *Agreement) SetElectionStrategy(strategy election.Strategy) *Agreement {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.electionStrategy = strategy
+	return this
+}
+
+// ElectionStrategy returns the strategy ranking the candidates of the service's leader election.
+func (this *Agreement) ElectionStrategy() election.Strategy {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	if this.electionStrategy == nil {
+		return &election.UuidStrategy{}
+	}
+	return this.electionStrategy
+}
+
+// PreferredLeaderPriority is the priority SetPreferredLeaders gives the preferred leaders
+// of a service, the other nodes have priority 0.
+const PreferredLeaderPriority = 100
+
+// SetLeaderPriority sets the priority of a node UUID, label or alias to lead the service.
+// The candidates are then ranked by a WeightedStrategy, installed unless the service's
+// strategy is one already.
+func (this *Agreement) SetLeaderPriority(nodeOrLabel string, priority int) *Agreement {
+	this.weighted().SetPriority(nodeOrLabel, priority)
+	return this
+}
+
+// SetPreferredLeaders prefers the given node UUIDs, labels or aliases as the leaders of
+// the service, over the nodes with no priority of their own.
+func (this *Agreement) SetPreferredLeaders(nodesOrLabels ...string) *Agreement {
+	weighted := this.weighted()
+	for _, nodeOrLabel := range nodesOrLabels {
+		weighted.SetPriority(nodeOrLabel, PreferredLeaderPriority)
+	}
+	return this
+}
+
+// SetNodeLabels sets the labels of a node, in addition to its alias, for the priorities
+// set on labels.
+func (this *Agreement) SetNodeLabels(node string, labels ...string) *Agreement {
+	this.weighted().SetLabels(node, labels...)
+	return this
+}
+
+// weighted returns the service's WeightedStrategy, installing a new one if the service
+// ranks its candidates by another strategy.
+func (this *Agreement) weighted() *election.WeightedStrategy {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	weighted, ok := this.electionStrategy.(*election.WeightedStrategy)
+	if !ok {
+		weighted = election.NewWeightedStrategy()
+		this.electionStrategy = weighted
+	}
+	return weighted
+}
+
+// SetSplitBrainPolicy sets what a leader deposed after a partition healed does with the
+// writes it committed while another leader was elected.
+func (this *Agreement) SetSplitBrainPolicy(policy SplitBrainPolicy) *Agreement {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.splitBrainPolicy = policy
+	return this
+}
+
+// SplitBrainPolicy returns what a deposed leader does with its divergent writes.
+func (this *Agreement) SplitBrainPolicy() SplitBrainPolicy {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.splitBrainPolicy
+}
+
+// SetReplicaPlacement sets how the replicas of a new key of the service are placed. The
+// virtual nodes are only used by PlacementConsistentHash, more of them spread the keys
+// more evenly. Values lower than 1 are treated as DefaultVirtualNodes. Every node of the
+// service should set the same placement.
+func (this *Agreement) SetReplicaPlacement(placement ReplicaPlacement, virtualNodes int) *Agreement {
+	if virtualNodes < 1 {
+		virtualNodes = DefaultVirtualNodes
+	}
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.replicaPlacement = placement
+	this.virtualNodes = virtualNodes
+	return this
+}
+
+// ReplicaPlacement returns how the replicas of a new key are placed and the virtual nodes
+// of every participant on the hash ring.
+func (this *Agreement) ReplicaPlacement() (ReplicaPlacement, int) {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.replicaPlacement, this.virtualNodes
+}
+
+// SetLeadershipBalanced sets whether the leadership balancer places the leader of the
+// service. Services every node runs for the others, whose leader does not matter, opt out.
+func (this *Agreement) SetLeadershipBalanced(balanced bool) *Agreement {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.unbalanced = !balanced
+	return this
+}
+
+// LeadershipBalanced returns whether the leadership balancer places the leader of the service.
+func (this *Agreement) LeadershipBalanced() bool {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return !this.unbalanced
+}
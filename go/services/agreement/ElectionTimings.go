@@ -0,0 +1,115 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agreement
+
+import (
+	"errors"
+	"time"
+)
+
+// Default election timings, used by the services that do not set their own.
+const (
+	DefaultElectionTimeout  = 3 * time.Second
+	DefaultHeartbeatTimeout = 5 * time.Second
+	DefaultHeartbeatPeriod  = 2 * time.Second
+	DefaultDebounceWindow   = 500 * time.Millisecond
+)
+
+// MinElectionTiming is the shortest election timeout, heartbeat timeout and heartbeat
+// period a service may set, to keep the election loops from spinning.
+const MinElectionTiming = 10 * time.Millisecond
+
+// ElectionTimings are the timings of a service's leader election. Latency sensitive
+// services may shorten them for a faster failover, WAN deployments may lengthen them
+// to avoid flapping leaders.
+type ElectionTimings struct {
+	// ElectionTimeout is the time a candidate waits for a higher ranked node to respond
+	// before it becomes the leader. A resigned leader stays out of the elections for
+	// three election timeouts.
+	ElectionTimeout time.Duration
+	// HeartbeatTimeout is the time without a heartbeat after which the followers consider
+	// the leader dead and elect a new one.
+	HeartbeatTimeout time.Duration
+	// HeartbeatPeriod is the interval between the leader's heartbeats.
+	HeartbeatPeriod time.Duration
+	// DebounceWindow is the time the elections requested on activation are held back,
+	// so a burst of activations starts a single election.
+	DebounceWindow time.Duration
+}
+
+// DefaultElectionTimings returns the default election timings.
+func DefaultElectionTimings() ElectionTimings {
+	return ElectionTimings{
+		ElectionTimeout:  DefaultElectionTimeout,
+		HeartbeatTimeout: DefaultHeartbeatTimeout,
+		HeartbeatPeriod:  DefaultHeartbeatPeriod,
+		DebounceWindow:   DefaultDebounceWindow,
+	}
+}
+
+// Validate returns an error if the timings can not work, i.e. a timeout or period is
+// shorter than MinElectionTiming, the debounce window is negative or the heartbeat
+// timeout does not exceed the heartbeat period, which would let the followers time out
+// a healthy leader between two heartbeats.
+func (this ElectionTimings) Validate() error {
+	if this.ElectionTimeout < MinElectionTiming {
+		return errors.New("ElectionTimings: election timeout must be at least " + MinElectionTiming.String())
+	}
+	if this.HeartbeatTimeout < MinElectionTiming {
+		return errors.New("ElectionTimings: heartbeat timeout must be at least " + MinElectionTiming.String())
+	}
+	if this.HeartbeatPeriod < MinElectionTiming {
+		return errors.New("ElectionTimings: heartbeat period must be at least " + MinElectionTiming.String())
+	}
+	if this.HeartbeatTimeout <= this.HeartbeatPeriod {
+		return errors.New("ElectionTimings: heartbeat timeout " + this.HeartbeatTimeout.String() +
+			" must exceed the heartbeat period " + this.HeartbeatPeriod.String())
+	}
+	if this.DebounceWindow < 0 {
+		return errors.New("ElectionTimings: debounce window can not be negative")
+	}
+	return nil
+}
+
+// SetElectionTimings sets the timings of the service's leader election, or returns an
+// error and keeps the current ones if they are not valid. The election loops already
+// running for the service pick up the new timings without a restart. Every node of the
+// service should set the same timings.
+func (this *Agreement) SetElectionTimings(timings ElectionTimings) error {
+	err := timings.Validate()
+	if err != nil {
+		return err
+	}
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.electionTimings = timings
+	close(this.timingsChanged)
+	this.timingsChanged = make(chan struct{})
+	return nil
+}
+
+// ElectionTimings returns the timings of the service's leader election.
+func (this *Agreement) ElectionTimings() ElectionTimings {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.electionTimings
+}
+
+// ElectionTimingsChanged returns a channel that is closed the next time the timings of
+// the service's leader election change.
+func (this *Agreement) ElectionTimingsChanged() <-chan struct{} {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.timingsChanged
+}
@@ -17,7 +17,10 @@
 package base
 
 import (
+	"sync"
+
 	"github.com/saichler/l8reflect/go/reflect/updating"
+	"github.com/saichler/l8services/go/types/l8svcs"
 	"github.com/saichler/l8srlz/go/serialize/object"
 	"github.com/saichler/l8types/go/ifs"
 	"github.com/saichler/l8types/go/types/l8web"
@@ -29,11 +32,13 @@ import (
 // CRUD operations with caching, SLA enforcement, and notification support.
 // It serves as the foundation for distributed services in the Layer 8 ecosystem.
 type BaseService struct {
-	cache   *cache.Cache
-	vnic    ifs.IVNic
-	sla     *ifs.ServiceLevelAgreement
-	nQueue  *queues.Queue
-	running bool
+	cache      *cache.Cache
+	vnic       ifs.IVNic
+	sla        *ifs.ServiceLevelAgreement
+	nQueue     *queues.Queue
+	running    bool
+	revisions  *cache.Cache
+	versionMtx *sync.Mutex
 }
 
 // Post creates new elements in the service cache. It delegates to the do method
@@ -65,6 +70,10 @@ func (this *BaseService) Delete(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements
 // - Query mode: retrieves multiple elements with pagination support
 // The SLA callback's Before hook is invoked prior to fetching data.
 func (this *BaseService) Get(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	revisioned, ok := pb.Element().(*l8svcs.L8Revisioned)
+	if ok && pb.IsFilterMode() {
+		return this.getRevisioned(revisioned, vnic)
+	}
 	if this.sla.Callback() != nil {
 		elem, cont, err := this.sla.Callback().Before(pb, ifs.GET, false, vnic)
 		if err != nil {
@@ -153,9 +162,24 @@ func (this *BaseService) ReplicationCount() int {
 }
 
 // KeyOf extracts and returns the primary key value from the given elements
-// using the introspector's primary key decorator.
+// using the introspector's primary key decorator. An element carried by an
+// L8Revisioned is keyed by the element it carries.
 func (this *BaseService) KeyOf(elems ifs.IElements, r ifs.IResources) string {
-	key, _, _ := r.Introspector().Decorators().PrimaryKeyDecoratorValue(elems.Element())
+	elem := elems.Element()
+	revisioned, ok := elem.(*l8svcs.L8Revisioned)
+	if ok {
+		var err error
+		elem, err = elementOf(revisioned, r)
+		if err != nil {
+			return ""
+		}
+	}
+	return keyOf(elem, r)
+}
+
+// keyOf returns the primary key of an element.
+func keyOf(elem interface{}, r ifs.IResources) string {
+	key, _, _ := r.Introspector().Decorators().PrimaryKeyDecoratorValue(elem)
 	return key
 }
 
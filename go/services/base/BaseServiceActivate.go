@@ -18,6 +18,7 @@ import (
 	"github.com/saichler/l8services/go/services/recovery"
 	"github.com/saichler/l8utils/go/utils/queues"
 	"reflect"
+	"sync"
 
 	"github.com/saichler/l8types/go/ifs"
 	"github.com/saichler/l8utils/go/utils/cache"
@@ -52,6 +53,7 @@ func (this *BaseService) Activate(sla *ifs.ServiceLevelAgreement, vnic ifs.IVNic
 		}
 		this.cache = cache.NewCache(this.sla.ServiceItem(), this.sla.InitItems(),
 			this.sla.Store(), vnic.Resources())
+		this.versionMtx = &sync.Mutex{}
 		if sla.MetadataFunc() != nil {
 			for name, f := range sla.MetadataFunc() {
 				this.cache.AddMetadataFunc(name, f)
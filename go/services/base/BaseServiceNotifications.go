@@ -26,14 +26,41 @@ import (
 // It invokes the SLA callback's Before and After hooks, performs the cache operation,
 // and queues notifications for property changes when the service is stateful and voting.
 func (this *BaseService) do(action ifs.Action, pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return this.write(action, pb, vnic, this.checkVersion, 1)
+}
+
+// write executes a CRUD action like do, applying the elements' cache operations only if
+// they all pass the version check, so a conflict of one leaves none of them written. An
+// element carried by an L8Revisioned is checked against the revision it carries. Elements
+// synchronized from other nodes, and writes with a nil check, are applied without checking
+// their version. Every applied element moves its managed revision by delta.
+func (this *BaseService) write(action ifs.Action, pb ifs.IElements, vnic ifs.IVNic, check versionCheck, delta int64) ifs.IElements {
 	createNotification := this.sla.Stateful() && this.sla.Voter() && !pb.Notification()
 	if this.vnic != nil {
 		vnic = this.vnic
 	}
-	for _, elem := range pb.Elements() {
-		if elem == nil {
-			continue
+	elems, revisions, err := unwrap(pb.Elements(), vnic.Resources())
+	if err != nil {
+		return object.New(err, &l8web.L8Empty{})
+	}
+	versioned := this.versioned(vnic.Resources())
+	if versioned {
+		this.versionMtx.Lock()
+		defer this.versionMtx.Unlock()
+		if check != nil && !pb.Notification() {
+			for i, elem := range elems {
+				elemCheck := check
+				if revisions[i] != 0 {
+					elemCheck = this.revisionCheck(revisions[i])
+				}
+				err = elemCheck(action, elem, vnic.Resources())
+				if err != nil {
+					return object.New(err, &l8web.L8Empty{})
+				}
+			}
 		}
+	}
+	for _, elem := range elems {
 		var n *l8notify.L8NotificationSet
 		var e error
 		if this.sla.Callback() != nil {
@@ -49,15 +76,9 @@ func (this *BaseService) do(action ifs.Action, pb ifs.IElements, vnic ifs.IVNic)
 			}
 		}
 		if this.cache != nil {
-			switch action {
-			case ifs.POST:
-				n, e = this.cache.Post(elem, createNotification)
-			case ifs.PUT:
-				n, e = this.cache.Put(elem, createNotification)
-			case ifs.PATCH:
-				n, e = this.cache.Patch(elem, createNotification)
-			case ifs.DELETE:
-				n, e = this.cache.Delete(elem, createNotification)
+			n, e = this.apply(action, elem, createNotification)
+			if versioned && e == nil {
+				this.bumpRevision(elem, delta, vnic.Resources())
 			}
 		}
 		if this.sla.Callback() != nil {
@@ -85,6 +106,21 @@ func (this *BaseService) do(action ifs.Action, pb ifs.IElements, vnic ifs.IVNic)
 	return object.New(nil, &l8web.L8Empty{})
 }
 
+// apply performs the cache operation of an element.
+func (this *BaseService) apply(action ifs.Action, elem interface{}, createNotification bool) (*l8notify.L8NotificationSet, error) {
+	switch action {
+	case ifs.POST:
+		return this.cache.Post(elem, createNotification)
+	case ifs.PUT:
+		return this.cache.Put(elem, createNotification)
+	case ifs.PATCH:
+		return this.cache.Patch(elem, createNotification)
+	case ifs.DELETE:
+		return this.cache.Delete(elem, createNotification)
+	}
+	return nil, nil
+}
+
 // processNotificationQueue runs as a background goroutine that continuously
 // processes notification sets from the queue and broadcasts property change
 // notifications via the virtual NIC. Stops when this.running becomes false.
@@ -0,0 +1,379 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package base
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/types/l8web"
+	"github.com/saichler/l8utils/go/utils/cache"
+	"google.golang.org/protobuf/proto"
+)
+
+// conflictPrefix starts the message of every ConflictError, so a conflict can still be
+// recognized once the error was carried as a message from another node.
+const conflictPrefix = "Version Conflict: "
+
+// ConflictError is returned for a write that was based on a version of an element
+// that is no longer the current one, i.e. the element was changed in the meantime.
+type ConflictError struct {
+	Key      string
+	Expected int64
+	Current  int64
+}
+
+// Error returns the conflict's message.
+func (this *ConflictError) Error() string {
+	return conflictPrefix + "element " + this.Key + " is at version " + strconv.FormatInt(this.Current, 10) +
+		", the write was based on version " + strconv.FormatInt(this.Expected, 10)
+}
+
+// IsConflict returns true if the error is a version conflict, including one received as
+// the error message of a remote service or of a failed transaction.
+func IsConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	var conflict *ConflictError
+	if errors.As(err, &conflict) {
+		return true
+	}
+	return strings.Contains(err.Error(), conflictPrefix)
+}
+
+// versionCheck verifies, under the version lock, that a write of an element may be
+// applied, returning an error if it may not.
+type versionCheck func(action ifs.Action, elem interface{}, r ifs.IResources) error
+
+// versioned returns whether the service checks the versions of its elements.
+func (this *BaseService) versioned(r ifs.IResources) bool {
+	if this.cache == nil {
+		return false
+	}
+	a := agreement.Of(this.sla, r)
+	return a.VersionField() != "" || a.ManagedRevision()
+}
+
+// checkVersion is the check of the regular writes. When the agreement names a version
+// field, a PUT or PATCH of an existing element must carry its current version, which
+// is then incremented on the written element. When the service manages revisions, a
+// regular PUT or PATCH carries no revision, so it may only write a new element.
+func (this *BaseService) checkVersion(action ifs.Action, elem interface{}, r ifs.IResources) error {
+	return this.revisionCheck(0)(action, elem, r)
+}
+
+// revisionCheck returns the check of a PUT or PATCH based on the given managed revision,
+// which is applied only if the revision of the element is still the given one and, when
+// the agreement names a version field, the element carries its current version.
+func (this *BaseService) revisionCheck(revision int64) versionCheck {
+	return func(action ifs.Action, elem interface{}, r ifs.IResources) error {
+		if action != ifs.PUT && action != ifs.PATCH {
+			return nil
+		}
+		a := agreement.Of(this.sla, r)
+		if a.ManagedRevision() {
+			key := keyOf(elem, r)
+			current := this.revisionOf(key, r)
+			if current != revision {
+				return &ConflictError{Key: key, Expected: revision, Current: current}
+			}
+		}
+		field := a.VersionField()
+		if field == "" {
+			return nil
+		}
+		current, exists, err := this.compareVersion(elem, field, r)
+		if err != nil || !exists {
+			return err
+		}
+		return setVersion(elem, field, current+1)
+	}
+}
+
+// compareVersion returns the version of the cached element with the same key, whether
+// there is one, and a ConflictError if the element carries a different version.
+func (this *BaseService) compareVersion(elem interface{}, field string, r ifs.IResources) (int64, bool, error) {
+	existing, err := this.cache.Get(elem)
+	if err != nil || existing == nil {
+		return 0, false, nil
+	}
+	current, err := versionOf(existing, field)
+	if err != nil {
+		return 0, true, err
+	}
+	incoming, err := versionOf(elem, field)
+	if err != nil {
+		return current, true, err
+	}
+	if incoming != current {
+		return current, true, &ConflictError{Key: keyOf(elem, r), Expected: incoming, Current: current}
+	}
+	return current, true, nil
+}
+
+// revisionsOf returns the cache of the managed revisions, creating it on first use with
+// the revision store of the agreement, so the revisions persisted by a previous run are
+// loaded. The caller holds the version lock.
+func (this *BaseService) revisionsOf(r ifs.IResources) *cache.Cache {
+	if this.revisions == nil {
+		r.Registry().Register(&l8svcs.L8Revision{})
+		r.Introspector().Decorators().AddPrimaryKeyDecorator(&l8svcs.L8Revision{}, "Key")
+		this.revisions = cache.NewCache(&l8svcs.L8Revision{}, nil, agreement.Of(this.sla, r).RevisionStore(), r)
+	}
+	return this.revisions
+}
+
+// revisionOf returns the managed revision of the element with the given key, 0 if it has
+// none. The caller holds the version lock.
+func (this *BaseService) revisionOf(key string, r ifs.IResources) int64 {
+	existing, err := this.revisionsOf(r).Get(&l8svcs.L8Revision{Key: key})
+	if err != nil || existing == nil {
+		return 0
+	}
+	revision, ok := existing.(*l8svcs.L8Revision)
+	if !ok {
+		return 0
+	}
+	return revision.Revision
+}
+
+// bumpRevision moves the managed revision of an element by delta after it was written.
+// A delete moves it too, keeping the revision of the deleted element, so a write based
+// on the element before it was deleted and created again is still a conflict. The
+// record is removed once the revision is back to 0. The caller holds the version lock.
+func (this *BaseService) bumpRevision(elem interface{}, delta int64, r ifs.IResources) {
+	if !agreement.Of(this.sla, r).ManagedRevision() {
+		return
+	}
+	key := keyOf(elem, r)
+	revision := this.revisionOf(key, r) + delta
+	if revision <= 0 {
+		this.revisionsOf(r).Delete(&l8svcs.L8Revision{Key: key}, false)
+		return
+	}
+	this.revisionsOf(r).Put(&l8svcs.L8Revision{Key: key, Revision: revision}, false)
+}
+
+// Revision returns the managed revision of an element, 0 if the element is unknown or
+// the service does not manage revisions. Pass it to PutIfRevision or PatchIfRevision.
+func (this *BaseService) Revision(elem interface{}, r ifs.IResources) int64 {
+	if this.versionMtx == nil {
+		return 0
+	}
+	this.versionMtx.Lock()
+	defer this.versionMtx.Unlock()
+	return this.revisionOf(keyOf(elem, r), r)
+}
+
+// PutIfRevision replaces an element only if its managed revision is still the given
+// one, otherwise it returns a ConflictError.
+func (this *BaseService) PutIfRevision(elem interface{}, revision int64, vnic ifs.IVNic) ifs.IElements {
+	return this.write(ifs.PUT, object.New(nil, elem), vnic, this.revisionCheck(revision), 1)
+}
+
+// PatchIfRevision updates an element only if its managed revision is still the given
+// one, otherwise it returns a ConflictError.
+func (this *BaseService) PatchIfRevision(elem interface{}, revision int64, vnic ifs.IVNic) ifs.IElements {
+	return this.write(ifs.PATCH, object.New(nil, elem), vnic, this.revisionCheck(revision), 1)
+}
+
+// Restore writes the elements as they are, without checking their versions. It is
+// used by a transaction rollback to put back the pre-commit state, whose version is
+// the one before the rolled back write, so the managed revisions are moved back too.
+func (this *BaseService) Restore(pb ifs.IElements, action ifs.Action, vnic ifs.IVNic) ifs.IElements {
+	return this.write(action, pb, vnic, nil, -1)
+}
+
+// ValidateTransaction votes on a transactional change during the prepare phase,
+// rejecting a PUT or PATCH based on a version, or a managed revision, that is no longer
+// the current one. It is checked again, and incremented, when the change is committed.
+func (this *BaseService) ValidateTransaction(pb ifs.IElements, action ifs.Action, vnic ifs.IVNic) error {
+	r := vnic.Resources()
+	if !this.versioned(r) || (action != ifs.PUT && action != ifs.PATCH) {
+		return nil
+	}
+	a := agreement.Of(this.sla, r)
+	field := a.VersionField()
+	elems, revisions, err := unwrap(pb.Elements(), r)
+	if err != nil {
+		return err
+	}
+	this.versionMtx.Lock()
+	defer this.versionMtx.Unlock()
+	for i, elem := range elems {
+		if a.ManagedRevision() {
+			key := keyOf(elem, r)
+			current := this.revisionOf(key, r)
+			if current != revisions[i] {
+				return &ConflictError{Key: key, Expected: revisions[i], Current: current}
+			}
+		}
+		if field == "" {
+			continue
+		}
+		_, _, err = this.compareVersion(elem, field, r)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unwrap returns the elements of a write, the elements carried by an L8Revisioned replaced
+// by the element they carry, and the managed revision each of the writes was based on,
+// 0 for an element that was not carried by an L8Revisioned.
+func unwrap(elems []interface{}, r ifs.IResources) ([]interface{}, []int64, error) {
+	result := make([]interface{}, 0, len(elems))
+	revisions := make([]int64, 0, len(elems))
+	for _, elem := range elems {
+		if elem == nil {
+			continue
+		}
+		revision := int64(0)
+		revisioned, ok := elem.(*l8svcs.L8Revisioned)
+		if ok {
+			var err error
+			elem, err = elementOf(revisioned, r)
+			if err != nil {
+				return nil, nil, err
+			}
+			revision = revisioned.Revision
+		}
+		result = append(result, elem)
+		revisions = append(revisions, revision)
+	}
+	return result, revisions, nil
+}
+
+// elementOf decodes the element carried by an L8Revisioned as its registered type.
+func elementOf(revisioned *l8svcs.L8Revisioned, r ifs.IResources) (interface{}, error) {
+	info, err := r.Registry().Info(revisioned.ElementType)
+	if err != nil {
+		return nil, err
+	}
+	elem, err := info.NewInstance()
+	if err != nil {
+		return nil, err
+	}
+	pb, ok := elem.(proto.Message)
+	if !ok {
+		return nil, errors.New("Revisioned element type " + revisioned.ElementType + " is not a protobuf message")
+	}
+	err = proto.Unmarshal(revisioned.ElementData, pb)
+	if err != nil {
+		return nil, err
+	}
+	return elem, nil
+}
+
+// getRevisioned serves a GET of an element carried by an L8Revisioned, returning the
+// element with its current managed revision, read under the version lock so the two match.
+func (this *BaseService) getRevisioned(revisioned *l8svcs.L8Revisioned, vnic ifs.IVNic) ifs.IElements {
+	if this.vnic != nil {
+		vnic = this.vnic
+	}
+	if this.cache == nil || vnic == nil {
+		return object.NewError("Revisioned GET of a service without a cache")
+	}
+	r := vnic.Resources()
+	filter, err := elementOf(revisioned, r)
+	if err != nil {
+		return object.New(err, &l8web.L8Empty{})
+	}
+	this.versionMtx.Lock()
+	defer this.versionMtx.Unlock()
+	resp := this.Get(object.New(nil, filter), vnic)
+	if resp == nil || resp.Error() != nil || resp.Element() == nil {
+		return resp
+	}
+	current, err := Revisioned(resp.Element(), this.revisionOf(keyOf(filter, r), r))
+	if err != nil {
+		return object.New(err, &l8web.L8Empty{})
+	}
+	return object.New(nil, current)
+}
+
+// Revisioned wraps an element with the managed revision a PUT or PATCH of it is based on.
+// It is how a remote client writes to a service managing revisions, the revision being
+// the one returned by a GET of the element wrapped the same way.
+func Revisioned(elem interface{}, revision int64) (*l8svcs.L8Revisioned, error) {
+	pb, ok := elem.(proto.Message)
+	if !ok || pb == nil {
+		return nil, errors.New("Revisioned element is not a protobuf message")
+	}
+	data, err := proto.Marshal(pb)
+	if err != nil {
+		return nil, err
+	}
+	return &l8svcs.L8Revisioned{Revision: revision, ElementType: reflect.ValueOf(pb).Elem().Type().Name(),
+		ElementData: data}, nil
+}
+
+// versionField returns the settable integer field holding the version of an element.
+func versionField(elem interface{}, field string) (reflect.Value, error) {
+	v := reflect.ValueOf(elem)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, errors.New("Version field " + field + ": element is not a struct")
+	}
+	f := v.FieldByName(field)
+	if !f.IsValid() {
+		return reflect.Value{}, errors.New("Version field " + field + " does not exist in " + v.Type().Name())
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return f, nil
+	}
+	return reflect.Value{}, errors.New("Version field " + field + " of " + v.Type().Name() + " is not an integer")
+}
+
+// versionOf returns the version of an element.
+func versionOf(elem interface{}, field string) (int64, error) {
+	f, err := versionField(elem, field)
+	if err != nil {
+		return 0, err
+	}
+	switch f.Kind() {
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return int64(f.Uint()), nil
+	}
+	return f.Int(), nil
+}
+
+// setVersion sets the version of an element.
+func setVersion(elem interface{}, field string, version int64) error {
+	f, err := versionField(elem, field)
+	if err != nil {
+		return err
+	}
+	if !f.CanSet() {
+		return errors.New("Version field " + field + " cannot be set")
+	}
+	switch f.Kind() {
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		f.SetUint(uint64(version))
+	default:
+		f.SetInt(version)
+	}
+	return nil
+}
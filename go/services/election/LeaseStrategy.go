@@ -0,0 +1,62 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package election
+
+import "time"
+
+// LeaseStrategy keeps the leadership with the incumbent leader. The leader holds a lease
+// that every heartbeat renews, while it is valid the leader outranks every other node
+// and no other node may become the leader, so a node joining, or a leader that missed
+// a few heartbeats, does not move the leadership. Once the lease expired, the fallback
+// strategy ranks the candidates.
+type LeaseStrategy struct {
+	lease    time.Duration
+	fallback Strategy
+}
+
+// NewLeaseStrategy creates a lease strategy with the given lease duration, falling back to
+// the given strategy, or to the UUID strategy if it is nil, once the lease expired.
+func NewLeaseStrategy(lease time.Duration, fallback Strategy) *LeaseStrategy {
+	if fallback == nil {
+		fallback = &UuidStrategy{}
+	}
+	return &LeaseStrategy{lease: lease, fallback: fallback}
+}
+
+// Copy returns a lease strategy with the same lease, and a copy of the fallback strategy.
+func (this *LeaseStrategy) Copy() Strategy {
+	return NewLeaseStrategy(this.lease, copyOf(this.fallback))
+}
+
+// Outranks returns true if the candidate is the leader holding a valid lease, otherwise
+// it ranks the candidates with the fallback strategy.
+func (this *LeaseStrategy) Outranks(candidate, other string, c *Candidacy) bool {
+	if this.leased(c) {
+		return candidate == c.Leader
+	}
+	return this.fallback.Outranks(candidate, other, c)
+}
+
+// Eligible returns true if no other node holds a valid lease.
+func (this *LeaseStrategy) Eligible(node string, c *Candidacy) bool {
+	if this.leased(c) {
+		return node == c.Leader
+	}
+	return this.fallback.Eligible(node, c)
+}
+
+// leased returns true if the leader's lease is still valid.
+func (this *LeaseStrategy) leased(c *Candidacy) bool {
+	return c != nil && c.Leader != "" && time.Since(c.LastHeartbeat) < this.lease
+}
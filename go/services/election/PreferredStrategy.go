@@ -0,0 +1,73 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package election
+
+import "sync"
+
+// PreferredStrategy prefers a single node as the leader, it outranks all the other nodes,
+// which the fallback strategy ranks among themselves. The preferred node can be changed
+// at any time, e.g. by a balancer spreading the leadership of the services.
+type PreferredStrategy struct {
+	preferred string
+	fallback  Strategy
+	mtx       *sync.RWMutex
+}
+
+// NewPreferredStrategy creates a strategy preferring the given node, falling back to the
+// given strategy, or to the UUID strategy if it is nil, for the other nodes.
+func NewPreferredStrategy(preferred string, fallback Strategy) *PreferredStrategy {
+	if fallback == nil {
+		fallback = &UuidStrategy{}
+	}
+	return &PreferredStrategy{preferred: preferred, fallback: fallback, mtx: &sync.RWMutex{}}
+}
+
+// SetPreferred sets the preferred node, an empty UUID prefers no node.
+func (this *PreferredStrategy) SetPreferred(node string) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.preferred = node
+}
+
+// Preferred returns the preferred node.
+func (this *PreferredStrategy) Preferred() string {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	return this.preferred
+}
+
+// Copy returns a strategy preferring the same node, with a copy of the fallback strategy.
+func (this *PreferredStrategy) Copy() Strategy {
+	return NewPreferredStrategy(this.Preferred(), copyOf(this.fallback))
+}
+
+// Outranks returns true if the candidate is the preferred node, otherwise it ranks the
+// candidates with the fallback strategy.
+func (this *PreferredStrategy) Outranks(candidate, other string, c *Candidacy) bool {
+	preferred := this.Preferred()
+	if preferred != "" && candidate != other {
+		if candidate == preferred {
+			return true
+		}
+		if other == preferred {
+			return false
+		}
+	}
+	return this.fallback.Outranks(candidate, other, c)
+}
+
+// Eligible returns whether the fallback strategy lets the node become the leader.
+func (this *PreferredStrategy) Eligible(node string, c *Candidacy) bool {
+	return this.fallback.Eligible(node, c)
+}
@@ -0,0 +1,73 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package election provides the strategies that rank the candidates of a service's
+// leader election. The election itself is a bully election, a candidate multicasts an
+// election request and becomes the leader unless a node that outranks it responds.
+// The strategy decides which node outranks which, so every node of a service must
+// use the same strategy, with the same configuration, for the election to converge.
+package election
+
+import (
+	"time"
+
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// Candidacy is what a node knows of a service's election when it ranks two candidates.
+type Candidacy struct {
+	ServiceName   string
+	ServiceArea   byte
+	Leader        string    // The current leader, or the last known one if there is none
+	LastHeartbeat time.Time // When the leader was last heard of
+	Resources     ifs.IResources
+}
+
+// Strategy ranks the candidates of a service's leader election.
+type Strategy interface {
+	// Outranks returns true if the candidate should lead the service rather than the other node.
+	Outranks(candidate, other string, c *Candidacy) bool
+	// Eligible returns true if the node may become the leader now, if no node outranking
+	// it responded to its election request.
+	Eligible(node string, c *Candidacy) bool
+}
+
+// Copier is implemented by the strategies with settings of their own, so every node
+// activating a service gets its own copy of the strategy carried by the service's SLA,
+// and changing it on one node does not change it on the others.
+type Copier interface {
+	// Copy returns a strategy with the same settings that shares none of them.
+	Copy() Strategy
+}
+
+// copyOf returns a copy of a strategy that has settings of its own, or the strategy itself.
+func copyOf(strategy Strategy) Strategy {
+	copier, ok := strategy.(Copier)
+	if ok {
+		return copier.Copy()
+	}
+	return strategy
+}
+
+// UuidStrategy is the default strategy, the node with the higher UUID wins.
+type UuidStrategy struct{}
+
+// Outranks returns true if the candidate's UUID is higher than the other node's.
+func (this *UuidStrategy) Outranks(candidate, other string, c *Candidacy) bool {
+	return candidate > other
+}
+
+// Eligible returns true, any node may become the leader.
+func (this *UuidStrategy) Eligible(node string, c *Candidacy) bool {
+	return true
+}
@@ -0,0 +1,108 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package election
+
+import (
+	"sync"
+
+	"github.com/saichler/l8bus/go/overlay/health"
+)
+
+// WeightedStrategy ranks the candidates by priority, the node with the higher priority
+// wins and the higher UUID breaks ties. Priorities are set for a node UUID or for a
+// label, a node has the highest priority of its UUID and its labels. The alias of a
+// node is one of its labels, so preferred leaders can be chosen by alias.
+type WeightedStrategy struct {
+	priorities map[string]int
+	labels     map[string][]string
+	mtx        *sync.RWMutex
+}
+
+// NewWeightedStrategy creates a weighted strategy in which all the nodes have priority 0.
+func NewWeightedStrategy() *WeightedStrategy {
+	return &WeightedStrategy{priorities: make(map[string]int), labels: make(map[string][]string),
+		mtx: &sync.RWMutex{}}
+}
+
+// SetPriority sets the priority of a node UUID or of a label.
+func (this *WeightedStrategy) SetPriority(nodeOrLabel string, priority int) *WeightedStrategy {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.priorities[nodeOrLabel] = priority
+	return this
+}
+
+// SetLabels sets the labels of a node, in addition to its alias.
+func (this *WeightedStrategy) SetLabels(node string, labels ...string) *WeightedStrategy {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.labels[node] = labels
+	return this
+}
+
+// Copy returns a weighted strategy with the same priorities and labels.
+func (this *WeightedStrategy) Copy() Strategy {
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	result := NewWeightedStrategy()
+	for nodeOrLabel, priority := range this.priorities {
+		result.priorities[nodeOrLabel] = priority
+	}
+	for node, labels := range this.labels {
+		result.labels[node] = append([]string{}, labels...)
+	}
+	return result
+}
+
+// PriorityOf returns the priority of a node, the highest of its UUID and labels.
+func (this *WeightedStrategy) PriorityOf(node string, c *Candidacy) int {
+	alias := ""
+	if c != nil && c.Resources != nil {
+		hp := health.HealthOf(node, c.Resources)
+		if hp != nil {
+			alias = hp.Alias
+		}
+	}
+	this.mtx.RLock()
+	defer this.mtx.RUnlock()
+	priority, found := this.priorities[node]
+	labels := this.labels[node]
+	if alias != "" {
+		labels = append([]string{alias}, labels...)
+	}
+	for _, label := range labels {
+		p, ok := this.priorities[label]
+		if ok && (!found || p > priority) {
+			priority = p
+			found = true
+		}
+	}
+	return priority
+}
+
+// Outranks returns true if the candidate has a higher priority than the other node, or
+// the same priority and a higher UUID.
+func (this *WeightedStrategy) Outranks(candidate, other string, c *Candidacy) bool {
+	pc := this.PriorityOf(candidate, c)
+	po := this.PriorityOf(other, c)
+	if pc != po {
+		return pc > po
+	}
+	return candidate > other
+}
+
+// Eligible returns true, any node may become the leader if no preferred node responded.
+func (this *WeightedStrategy) Eligible(node string, c *Candidacy) bool {
+	return true
+}
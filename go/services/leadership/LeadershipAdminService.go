@@ -0,0 +1,145 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package leadership provides an admin service for moving the leadership of services,
+// e.g. before maintenance. Only the current leader can give up its leadership, a node
+// that is not the leader forwards the request to the leader of the service.
+package leadership
+
+import (
+	"errors"
+
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// Service constants for the leadership admin service registration.
+const (
+	ServiceType = "LeadershipAdminService"
+	ServiceName = "LdrAdmin"
+	ServiceArea = byte(0)
+)
+
+// forwardTimeout is the time in seconds to wait for the leader to move its leadership.
+const forwardTimeout = 30
+
+// ILeadershipProvider is implemented by the service manager running the leader elections.
+type ILeadershipProvider interface {
+	TransferLeadership(serviceName string, serviceArea byte, target string, vnic ifs.IVNic) (int, error)
+	StepDown(serviceName string, serviceArea byte, vnic ifs.IVNic) (string, int, error)
+	GetLeader(serviceName string, serviceArea byte) string
+}
+
+// LeadershipAdminService moves the leadership of services on POST requests and answers
+// GET requests with the current leader of a service.
+type LeadershipAdminService struct {
+	provider ILeadershipProvider
+}
+
+// Activate binds the service to the node's service manager.
+func (this *LeadershipAdminService) Activate(sla *ifs.ServiceLevelAgreement, vnic ifs.IVNic) error {
+	provider, ok := vnic.Resources().Services().(ILeadershipProvider)
+	if !ok {
+		return errors.New("LeadershipAdminService: services do not provide leader elections")
+	}
+	this.provider = provider
+	return nil
+}
+
+// DeActivate performs cleanup when the service is shut down.
+func (this *LeadershipAdminService) DeActivate() error {
+	return nil
+}
+
+// Post transfers the leadership of the service in the request to its target, or steps
+// down if it has no target. A node that is not the leader forwards the request to it.
+func (this *LeadershipAdminService) Post(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	request, err := requestOf(pb)
+	if err != nil {
+		return object.NewError(err.Error())
+	}
+	serviceArea := byte(request.ServiceArea)
+	leader := this.provider.GetLeader(request.ServiceName, serviceArea)
+	if leader != "" && leader != vnic.Resources().SysConfig().LocalUuid {
+		return vnic.Request(leader, ServiceName, ServiceArea, ifs.POST, request, forwardTimeout)
+	}
+	result := &l8svcs.L8LeadershipRequest{ServiceName: request.ServiceName, ServiceArea: request.ServiceArea, Target: request.Target}
+	handedOff := 0
+	if request.Target != "" {
+		handedOff, err = this.provider.TransferLeadership(request.ServiceName, serviceArea, request.Target, vnic)
+		result.Leader = this.provider.GetLeader(request.ServiceName, serviceArea)
+	} else {
+		result.Leader, handedOff, err = this.provider.StepDown(request.ServiceName, serviceArea, vnic)
+	}
+	result.HandedOff = int32(handedOff)
+	return object.New(err, result)
+}
+
+// Put is not supported, use Post to move the leadership.
+func (this *LeadershipAdminService) Put(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.NewError("LeadershipAdminService: use POST to move the leadership")
+}
+
+// Patch is not supported, use Post to move the leadership.
+func (this *LeadershipAdminService) Patch(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.NewError("LeadershipAdminService: use POST to move the leadership")
+}
+
+// Delete is not supported, use Post to move the leadership.
+func (this *LeadershipAdminService) Delete(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.NewError("LeadershipAdminService: use POST to move the leadership")
+}
+
+// Get returns the current leader of the service in the request.
+func (this *LeadershipAdminService) Get(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	request, err := requestOf(pb)
+	if err != nil {
+		return object.NewError(err.Error())
+	}
+	return object.New(nil, &l8svcs.L8LeadershipRequest{ServiceName: request.ServiceName, ServiceArea: request.ServiceArea,
+		Leader: this.provider.GetLeader(request.ServiceName, byte(request.ServiceArea))})
+}
+
+// Failed handles message delivery failures (no-op for the leadership admin service).
+func (this *LeadershipAdminService) Failed(pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+	return nil
+}
+
+// TransactionConfig returns nil as the leadership admin service doesn't use transactions.
+func (this *LeadershipAdminService) TransactionConfig() ifs.ITransactionConfig {
+	return nil
+}
+
+// WebService returns nil as the leadership admin service doesn't expose a web interface.
+func (this *LeadershipAdminService) WebService() ifs.IWebService {
+	return nil
+}
+
+// requestOf returns the leadership request in the elements.
+func requestOf(pb ifs.IElements) (*l8svcs.L8LeadershipRequest, error) {
+	if pb == nil {
+		return nil, errors.New("LeadershipAdminService: missing leadership request")
+	}
+	request, ok := pb.Element().(*l8svcs.L8LeadershipRequest)
+	if !ok || request == nil || request.ServiceName == "" {
+		return nil, errors.New("LeadershipAdminService: expected a leadership request with a service name")
+	}
+	return request, nil
+}
+
+// Service returns the leadership admin service handler from the resources.
+func Service(r ifs.IResources) ifs.IServiceHandler {
+	adminService, _ := r.Services().ServiceHandler(ServiceName, ServiceArea)
+	return adminService
+}
@@ -22,6 +22,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/saichler/l8services/go/services/agreement"
 	"github.com/saichler/l8services/go/services/replication"
 	"github.com/saichler/l8srlz/go/serialize/object"
 	"github.com/saichler/l8types/go/ifs"
@@ -82,12 +83,19 @@ func (this *ServiceManager) Activate(sla *ifs.ServiceLevelAgreement, vnic ifs.IV
 	h := vnic.Resources().Registry().NewOf(sla.ServiceHandlerInstance())
 	handler = h.(ifs.IServiceHandler)
 
+	// The node's agreement of the service starts with the attributes set on the SLA
+	carried := agreement.Carried(sla)
+	if carried != nil {
+		this.agreements.Store(cacheKey(sla.ServiceName(), sla.ServiceArea()), carried)
+	}
+
 	err = handler.Activate(sla, vnic)
 	if err != nil {
 		panic(err)
 	}
 
 	this.services.put(sla.ServiceName(), sla.ServiceArea(), handler)
+	this.slas.Store(cacheKey(sla.ServiceName(), sla.ServiceArea()), sla)
 	ifs.AddService(this.resources.SysConfig(), sla.ServiceName(), int32(sla.ServiceArea()))
 
 	// Store group mapping before publishing so incoming ServiceRegister
@@ -118,6 +126,7 @@ func (this *ServiceManager) Activate(sla *ifs.ServiceLevelAgreement, vnic ifs.IV
 	}
 
 	if sla.Stateful() {
+		this.watchLeadership(sla.ServiceName(), sla.ServiceArea(), handler, vnic)
 		this.triggerElections(sla.ServiceName(), sla.ServiceArea(), sla.ServiceGroup(), handler, vnic)
 		go func() {
 			time.Sleep(time.Second * 2)
@@ -160,11 +169,15 @@ func (this *ServiceManager) registerForReplication(serviceName string, serviceAr
 				repService, _ = this.Activate(sla, vnic)
 			}
 
-			index := &l8services.L8ReplicationIndex{}
-			index.ServiceName = serviceName
-			index.ServiceArea = int32(serviceArea)
-			index.Keys = make(map[string]*l8services.L8ReplicationKey)
-			repService.Post(object.New(nil, index), vnic)
+			//A node joining the service keeps the index the other participants built
+			if replication.ReplicationIndex(serviceName, serviceArea, vnic.Resources()) == nil {
+				index := &l8services.L8ReplicationIndex{}
+				index.ServiceName = serviceName
+				index.ServiceArea = int32(serviceArea)
+				index.Keys = make(map[string]*l8services.L8ReplicationKey)
+				repService.Post(object.New(nil, index), vnic)
+			}
+			this.rebalancer.start(vnic)
 		}
 	}
 	return nil
@@ -192,7 +205,7 @@ func (this *ServiceManager) triggerElections(serviceName string, serviceArea byt
 	this.participantRegistry.RegisterParticipant(groupName, groupArea, localUuid)
 
 	// Announce ourselves as a participant using the service name for VNet routing
-	vnic.Multicast(serviceName, serviceArea, ifs.ServiceRegister, nil)
+	vnic.Multicast(serviceName, serviceArea, ifs.ServiceRegister, this.participantRegistry.announcement(localUuid))
 
 	// Discover existing participants using the service name for VNet routing
 	vnic.Multicast(serviceName, serviceArea, ifs.ServiceQuery, nil)
@@ -15,11 +15,15 @@ package manager
 
 import (
 	"errors"
+
+	"github.com/saichler/l8services/go/services/agreement"
 	"github.com/saichler/l8types/go/ifs"
 )
 
 // DeActivate removes a service from the registry and shuts it down.
-// It notifies the network of the service removal if the listener is a VNIC.
+// It notifies the network of the service removal if the listener is a VNIC, and
+// unregisters the node as a participant of a replicated service whose keys are placed
+// by consistent hashing.
 func (this *ServiceManager) DeActivate(serviceName string, serviceArea byte, r ifs.IResources, l ifs.IServiceCacheListener) error {
 
 	if serviceName == "" {
@@ -32,11 +36,24 @@ func (this *ServiceManager) DeActivate(serviceName string, serviceArea byte, r i
 	}
 
 	defer handler.DeActivate()
+	placement, _ := this.Agreement(serviceName, serviceArea).ReplicaPlacement()
+	this.slas.Delete(cacheKey(serviceName, serviceArea))
+	this.agreements.Delete(cacheKey(serviceName, serviceArea))
+	this.unwatchLeadership(serviceName, serviceArea)
+	if handler.TransactionConfig() != nil {
+		this.trManager.Shutdown(serviceName, serviceArea)
+	}
 
 	ifs.RemoveService(this.resources.SysConfig().Services, serviceName, int32(serviceArea))
 	vnic, ok := l.(ifs.IVNic)
 	if ok {
 		vnic.NotifyServiceRemoved(serviceName, serviceArea)
+		// The participants of a service placing its keys on the hash ring move them off the
+		// node that left it
+		if handler.TransactionConfig() != nil && handler.TransactionConfig().Replication() &&
+			placement == agreement.PlacementConsistentHash {
+			vnic.Multicast(serviceName, serviceArea, ifs.ServiceUnregister, nil)
+		}
 	}
 	return nil
 }
@@ -20,8 +20,6 @@ import (
 	"github.com/saichler/l8types/go/ifs"
 )
 
-const electionDebounceWindow = 500 * time.Millisecond
-
 // pendingElection holds the data needed to start a deferred election.
 type pendingElection struct {
 	serviceName string
@@ -49,7 +47,8 @@ func NewElectionDebouncer(le *LeaderElection) *ElectionDebouncer {
 
 // RequestElection queues an election for the given service.
 // If a debounce window is already active, the timer resets.
-// Elections fire once the window expires with no new requests.
+// Elections fire once the window expires with no new requests,
+// the longest debounce window of the pending services.
 func (d *ElectionDebouncer) RequestElection(serviceName string, serviceArea byte, vnic ifs.IVNic) {
 	d.mtx.Lock()
 	defer d.mtx.Unlock()
@@ -64,7 +63,14 @@ func (d *ElectionDebouncer) RequestElection(serviceName string, serviceArea byte
 	if d.timer != nil {
 		d.timer.Stop()
 	}
-	d.timer = time.AfterFunc(electionDebounceWindow, d.fireElections)
+	window := time.Duration(0)
+	for _, pe := range d.pending {
+		w := d.le.serviceManager.timingsOf(pe.serviceName, pe.serviceArea).DebounceWindow
+		if w > window {
+			window = w
+		}
+	}
+	d.timer = time.AfterFunc(window, d.fireElections)
 }
 
 // fireElections starts all pending elections and clears the queue.
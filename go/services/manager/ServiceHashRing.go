@@ -0,0 +1,126 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// ringPoint is a virtual node of a participant on the hash ring.
+type ringPoint struct {
+	hash uint64
+	uuid string
+}
+
+// hashRing places the participants of a service on a ring, each at several points hashed
+// from its UUID, so the keys spread evenly and the ring depends only on the membership.
+type hashRing struct {
+	points       []ringPoint
+	members      map[string]struct{}
+	virtualNodes int
+}
+
+// newHashRing creates the ring of the given participants.
+func newHashRing(uuids map[string]struct{}, virtualNodes int) *hashRing {
+	ring := &hashRing{virtualNodes: virtualNodes}
+	ring.members = make(map[string]struct{}, len(uuids))
+	ring.points = make([]ringPoint, 0, len(uuids)*virtualNodes)
+	for uuid := range uuids {
+		ring.members[uuid] = struct{}{}
+		for i := 0; i < virtualNodes; i++ {
+			ring.points = append(ring.points, ringPoint{hash: hashOf(uuid + "#" + strconv.Itoa(i)), uuid: uuid})
+		}
+	}
+	sort.Slice(ring.points, func(i, j int) bool {
+		if ring.points[i].hash == ring.points[j].hash {
+			return ring.points[i].uuid < ring.points[j].uuid
+		}
+		return ring.points[i].hash < ring.points[j].hash
+	})
+	return ring
+}
+
+// hashOf returns the position of a string on the ring.
+func hashOf(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// matches returns true if the ring was built for the given participants and virtual nodes.
+func (this *hashRing) matches(uuids map[string]struct{}, virtualNodes int) bool {
+	if this.virtualNodes != virtualNodes || len(this.members) != len(uuids) {
+		return false
+	}
+	for uuid := range uuids {
+		if _, ok := this.members[uuid]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// pick walks the ring clockwise from the key's position and returns the first distinct
+// participants, numbered as replicas in the order they were met.
+func (this *hashRing) pick(key string, replications int) map[string]byte {
+	if replications > len(this.members) {
+		replications = len(this.members)
+	}
+	result := make(map[string]byte, replications)
+	if replications == 0 {
+		return result
+	}
+	position := hashOf(key)
+	start := sort.Search(len(this.points), func(i int) bool { return this.points[i].hash >= position })
+	for i := 0; i < len(this.points) && len(result) < replications; i++ {
+		uuid := this.points[(start+i)%len(this.points)].uuid
+		if _, ok := result[uuid]; ok {
+			continue
+		}
+		result[uuid] = byte(len(result))
+	}
+	return result
+}
+
+// HashParticipants picks the participants holding the replicas of a key by consistent
+// hashing, building the ring of the service again whenever its participants changed.
+func (pr *ParticipantRegistry) HashParticipants(serviceName string, serviceArea byte, key string, replications int,
+	virtualNodes int) map[string]byte {
+	ps := pr.getParticipantSet(makeServiceKey(serviceName, serviceArea))
+	if ps == nil {
+		return map[string]byte{}
+	}
+
+	ps.mtx.Lock()
+	if ps.ring == nil || !ps.ring.matches(ps.uuids, virtualNodes) {
+		ps.ring = newHashRing(ps.uuids, virtualNodes)
+	}
+	ring := ps.ring
+	ps.mtx.Unlock()
+
+	return ring.pick(key, replications)
+}
+
+// HashParticipants picks the participants holding the replicas of a key of a replicated
+// service, on the hash ring of its participants with the virtual nodes of its agreement.
+// The pick depends only on the membership and the key, so every node picks the same
+// participants. A write leaves out the unhealthy ones, to repair them later, and does not
+// move the key off them.
+func (this *ServiceManager) HashParticipants(serviceName string, serviceArea byte, key string, replications int) map[string]byte {
+	_, virtualNodes := this.Agreement(serviceName, serviceArea).ReplicaPlacement()
+	gName, gArea := this.resolveGroup(serviceName, serviceArea)
+	return this.participantRegistry.HashParticipants(gName, gArea, key, replications, virtualNodes)
+}
@@ -0,0 +1,104 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// KeyRebalancer keeps the keys of the replicated services placed by consistent hashing on
+// the replicas the hash ring places them on. Once the participants of the services stop
+// changing, it moves the keys of the services this node leads to their new replicas.
+type KeyRebalancer struct {
+	sm    *ServiceManager
+	vnic  ifs.IVNic
+	timer *time.Timer
+	mtx   *sync.Mutex
+}
+
+// NewKeyRebalancer creates the key rebalancer of the service manager. It starts once a
+// replicated service is activated.
+func NewKeyRebalancer(sm *ServiceManager) *KeyRebalancer {
+	return &KeyRebalancer{sm: sm, mtx: &sync.Mutex{}}
+}
+
+// start sets the vnic the keys are moved with, once a replicated service is activated.
+func (this *KeyRebalancer) start(vnic ifs.IVNic) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.vnic = vnic
+}
+
+// requestRebalance schedules moving the keys once the participants stop changing, and the
+// elections the change triggered settled.
+func (this *KeyRebalancer) requestRebalance() {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if this.vnic == nil {
+		return
+	}
+	if this.timer != nil {
+		this.timer.Stop()
+	}
+	this.timer = time.AfterFunc(this.sm.balancer.delay(), func() {
+		this.mtx.Lock()
+		vnic := this.vnic
+		this.timer = nil
+		this.mtx.Unlock()
+		this.Rebalance(vnic)
+	})
+}
+
+// Rebalance moves the keys of the replicated services placed by consistent hashing that
+// this node leads to the replicas the hash ring places them on. Returns the number of keys
+// moved.
+func (this *KeyRebalancer) Rebalance(vnic ifs.IVNic) int {
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	moved := 0
+	this.sm.slas.Range(func(key, value interface{}) bool {
+		sla := value.(*ifs.ServiceLevelAgreement)
+		if !sla.Replication() {
+			return true
+		}
+		placement, _ := this.sm.Agreement(sla.ServiceName(), sla.ServiceArea()).ReplicaPlacement()
+		if placement != agreement.PlacementConsistentHash || this.sm.GetLeader(sla.ServiceName(), sla.ServiceArea()) != localUuid {
+			return true
+		}
+		report, err := this.sm.trManager.RebalanceKeys(sla.ServiceName(), sla.ServiceArea(), vnic)
+		if err != nil {
+			vnic.Resources().Logger().Error("KeyRebalancer: ", err.Error())
+			return true
+		}
+		moved += report.Moved
+		return true
+	})
+	return moved
+}
+
+// RebalanceKeys moves the keys of a replicated service placed by consistent hashing to the
+// replicas the hash ring places them on. This node must be the leader of the service.
+func (this *ServiceManager) RebalanceKeys(serviceName string, serviceArea byte, vnic ifs.IVNic) (*states.RebalanceReport, error) {
+	return this.trManager.RebalanceKeys(serviceName, serviceArea, vnic)
+}
+
+// Rebalanced returns the report of the last time this node moved the keys of a service to
+// their replicas, nil if it never did.
+func (this *ServiceManager) Rebalanced(serviceName string, serviceArea byte) *states.RebalanceReport {
+	return this.trManager.Rebalanced(serviceName, serviceArea)
+}
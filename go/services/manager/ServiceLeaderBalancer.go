@@ -0,0 +1,270 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/election"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// balanceTimeouts is the number of election timeouts the balancer waits after the last
+// change of the participants before it balances, so the elections the change triggered
+// can settle first.
+const balanceTimeouts = 2
+
+// balancedService is a service, or group, whose leadership the balancer places.
+type balancedService struct {
+	serviceName  string // The name of a service, for resigning and handing off its transactions
+	serviceArea  byte
+	electionName string // The name the election is keyed by, the service's group if it has one
+	electionArea byte
+}
+
+// LeadershipBalancer spreads the leadership of the services this node runs across their
+// participants. It plans a preferred leader for every service and installs it on the
+// service's election strategy. A leader that is not the preferred one resigns, handing off
+// its queued transactions, so the preferred node gets elected.
+//
+// The plan of a service depends only on the service's election key, its participants and
+// the weights they announced with their registrations, which all the participants of the
+// service share. Every participant is scored by rendezvous hashing of the key and its UUID,
+// scaled by its weight, and the highest score leads, so all the participants plan the same
+// leader and the leaderships spread across the nodes in proportion to their weights.
+// The planned leader stays preferred until the plan changes, so it is elected again after
+// a transfer or a failover.
+type LeadershipBalancer struct {
+	sm      *ServiceManager
+	vnic    ifs.IVNic
+	enabled bool
+	planned map[string]string
+	timer   *time.Timer
+	mtx     *sync.Mutex
+}
+
+// NewLeadershipBalancer creates a disabled balancer for the service manager.
+func NewLeadershipBalancer(sm *ServiceManager) *LeadershipBalancer {
+	return &LeadershipBalancer{sm: sm, planned: make(map[string]string), mtx: &sync.Mutex{}}
+}
+
+// Enable starts balancing the leadership whenever nodes join or leave the services.
+func (this *LeadershipBalancer) Enable(vnic ifs.IVNic) {
+	this.mtx.Lock()
+	this.vnic = vnic
+	this.enabled = true
+	this.mtx.Unlock()
+	this.requestBalance()
+}
+
+// Disable stops balancing, the preferred leaders already installed are kept.
+func (this *LeadershipBalancer) Disable() {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.enabled = false
+	if this.timer != nil {
+		this.timer.Stop()
+		this.timer = nil
+	}
+}
+
+// SetWeight sets the share of the leaderships this node should take, relative to the other
+// nodes, and announces it to the participants of the services it runs. Nodes have weight 1
+// by default, a node with weight 0 leads no service.
+func (this *LeadershipBalancer) SetWeight(weight int, vnic ifs.IVNic) {
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	if !this.sm.participantRegistry.setWeight(localUuid, weight) {
+		return
+	}
+	announcement := this.sm.participantRegistry.announcement(localUuid)
+	for _, s := range this.services() {
+		vnic.Multicast(s.serviceName, s.serviceArea, ifs.ServiceRegister, announcement)
+	}
+	this.requestBalance()
+}
+
+// requestBalance schedules balancing once the participants stop changing.
+func (this *LeadershipBalancer) requestBalance() {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if !this.enabled {
+		return
+	}
+	if this.timer != nil {
+		this.timer.Stop()
+	}
+	this.timer = time.AfterFunc(this.delay(), func() {
+		this.mtx.Lock()
+		vnic := this.vnic
+		this.timer = nil
+		this.mtx.Unlock()
+		this.Balance(vnic)
+	})
+}
+
+// delay returns the time to wait before balancing, by the longest election timeout of
+// the services this node runs.
+func (this *LeadershipBalancer) delay() time.Duration {
+	longest := agreement.DefaultElectionTimeout
+	for _, s := range this.services() {
+		timeout := this.sm.timingsOf(s.electionName, s.electionArea).ElectionTimeout
+		if timeout > longest {
+			longest = timeout
+		}
+	}
+	return balanceTimeouts * longest
+}
+
+// Balance plans the preferred leader of every service, installs it and resigns the
+// leaderships this node holds that should move. Returns the number of resigned leaderships.
+func (this *LeadershipBalancer) Balance(vnic ifs.IVNic) int {
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	services := this.services()
+	plan := this.plan(services)
+	this.mtx.Lock()
+	this.planned = make(map[string]string, len(plan))
+	for key, target := range plan {
+		this.planned[key] = target
+	}
+	this.mtx.Unlock()
+	moved := 0
+	for _, s := range services {
+		key := makeServiceKey(s.electionName, s.electionArea)
+		target, ok := plan[key]
+		if !ok {
+			continue
+		}
+		//The services of a group share a single leadership
+		delete(plan, key)
+		this.sm.prefer(s.electionName, s.electionArea, target)
+		leader := this.sm.leaderElection.GetLeader(s.electionName, s.electionArea)
+		if leader != localUuid || target == localUuid {
+			continue
+		}
+		vnic.Resources().Logger().Info("LeadershipBalancer: moving leadership of ", s.electionName,
+			" area ", s.electionArea, " to ", target)
+		_, err := this.sm.ResignLeadership(s.serviceName, s.serviceArea, vnic)
+		if err != nil {
+			vnic.Resources().Logger().Error("LeadershipBalancer: ", err.Error())
+			continue
+		}
+		moved++
+	}
+	return moved
+}
+
+// Plan returns the preferred leader of every service this node runs, keyed by the name
+// and area of its election.
+func (this *LeadershipBalancer) Plan() map[string]string {
+	return this.plan(this.services())
+}
+
+// plannedLeader returns the leader the last balancing planned for a service, or an empty
+// UUID if it planned none.
+func (this *LeadershipBalancer) plannedLeader(electionName string, electionArea byte) string {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return this.planned[makeServiceKey(electionName, electionArea)]
+}
+
+// plan assigns every service to the participant with the highest rendezvous score for
+// the service's election key. Ties, which are as unlikely as hash collisions, go to the
+// higher UUID.
+func (this *LeadershipBalancer) plan(services []*balancedService) map[string]string {
+	result := make(map[string]string)
+	for _, s := range services {
+		key := makeServiceKey(s.electionName, s.electionArea)
+		if _, ok := result[key]; ok {
+			continue
+		}
+		best := ""
+		bestScore := 0.0
+		for node := range this.sm.participantRegistry.GetParticipants(s.electionName, s.electionArea) {
+			weight := this.sm.participantRegistry.Weight(node)
+			if weight <= 0 {
+				continue
+			}
+			score := rendezvousScore(key, node, weight)
+			if best == "" || score > bestScore || (score == bestScore && node > best) {
+				best = node
+				bestScore = score
+			}
+		}
+		if best != "" {
+			result[key] = best
+		}
+	}
+	return result
+}
+
+// rendezvousScore returns the weighted rendezvous hashing score of a node for a key. The
+// hash of the two is mapped to a uniform u in (0,1) and the score is weight / -ln(u), so a
+// node has the highest score of the key in proportion to its weight.
+func rendezvousScore(key, node string, weight int) float64 {
+	u := (float64(hashOf(key+"/"+node)>>11) + 0.5) / float64(uint64(1)<<53)
+	return float64(weight) / -math.Log(u)
+}
+
+// services returns the elected services this node runs, sorted by the key of their
+// election. The services whose agreement opts out of balancing are left out.
+func (this *LeadershipBalancer) services() []*balancedService {
+	result := make([]*balancedService, 0)
+	this.sm.slas.Range(func(key, value interface{}) bool {
+		sla := value.(*ifs.ServiceLevelAgreement)
+		if !sla.Stateful() || !this.sm.Agreement(sla.ServiceName(), sla.ServiceArea()).LeadershipBalanced() {
+			return true
+		}
+		s := &balancedService{serviceName: sla.ServiceName(), serviceArea: sla.ServiceArea()}
+		s.electionName, s.electionArea = this.sm.resolveGroup(s.serviceName, s.serviceArea)
+		result = append(result, s)
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		ki := makeServiceKey(result[i].electionName, result[i].electionArea)
+		kj := makeServiceKey(result[j].electionName, result[j].electionArea)
+		if ki != kj {
+			return ki < kj
+		}
+		return result[i].serviceName < result[j].serviceName
+	})
+	return result
+}
+
+// prefer installs the preferred leader on the election strategy of a service, wrapping
+// the strategy it has with a preferred strategy the first time. A preference other than
+// the planned one lasts until the node takes over, see tookOver.
+func (this *ServiceManager) prefer(electionName string, electionArea byte, node string) {
+	a := this.Agreement(electionName, electionArea)
+	strategy := a.ElectionStrategy()
+	preferred, ok := strategy.(*election.PreferredStrategy)
+	if ok {
+		preferred.SetPreferred(node)
+		return
+	}
+	a.SetElectionStrategy(election.NewPreferredStrategy(node, strategy))
+}
+
+// tookOver restores the planned leader of a service as its preferred one once the node
+// preferred by a transfer leads it, or gave up taking over. A service with no planned
+// leader is ranked by its own strategy again.
+func (this *ServiceManager) tookOver(electionName string, electionArea byte, node string) {
+	preferred, ok := this.Agreement(electionName, electionArea).ElectionStrategy().(*election.PreferredStrategy)
+	if ok && node != "" && preferred.Preferred() == node {
+		preferred.SetPreferred(this.balancer.plannedLeader(electionName, electionArea))
+	}
+}
@@ -0,0 +1,118 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sync"
+
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// ILeaderAwareService is an optional interface for stateful service handlers running
+// leader only work, such as schedulers or pollers. The service manager discovers it on
+// activation and calls the handler when this node becomes the leader of the service, or
+// of its group, and when it loses the leadership. The callbacks of a service are called
+// in order, one at a time, never concurrently.
+type ILeaderAwareService interface {
+	OnBecomeLeader(vnic ifs.IVNic)
+	OnLoseLeadership(vnic ifs.IVNic)
+}
+
+// leadershipCallbacks follows the election events of a leader aware service and calls
+// its callbacks when this node gains or loses the leadership. It is an election listener,
+// so its events are delivered in order by a single goroutine. Once stopped, on the
+// deactivation of the service, it calls no callback anymore.
+type leadershipCallbacks struct {
+	handler      ILeaderAwareService
+	vnic         ifs.IVNic
+	electionName string
+	electionArea byte
+	localUuid    string
+	leading      bool
+	stopped      bool
+	mtx          sync.Mutex
+}
+
+// OnElectionEvent calls the callbacks of the service on a change of its leadership.
+func (this *leadershipCallbacks) OnElectionEvent(event *ElectionEvent) {
+	if event.ServiceName != this.electionName || event.ServiceArea != this.electionArea {
+		return
+	}
+	switch event.Type {
+	case LeaderElected:
+		this.lead(event.Leader == this.localUuid)
+	case LeaderResigned, LeaderDeposed, HeartbeatLost:
+		if event.Leader == this.localUuid {
+			this.lead(false)
+		}
+	}
+}
+
+// lead calls the callback for the change, if any, of this node's leadership.
+func (this *leadershipCallbacks) lead(leading bool) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if this.stopped || leading == this.leading {
+		return
+	}
+	this.leading = leading
+	if leading {
+		this.handler.OnBecomeLeader(this.vnic)
+	} else {
+		this.handler.OnLoseLeadership(this.vnic)
+	}
+}
+
+// stop tells the handler it lost the leadership, if it leads, and stops the callbacks.
+func (this *leadershipCallbacks) stop() {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if this.leading && !this.stopped {
+		this.leading = false
+		this.handler.OnLoseLeadership(this.vnic)
+	}
+	this.stopped = true
+}
+
+// watchLeadership subscribes the callbacks of a leader aware service handler to the
+// election events of the service, or of its group. If this node already leads it, the
+// handler is told right away.
+func (this *ServiceManager) watchLeadership(serviceName string, serviceArea byte, handler ifs.IServiceHandler, vnic ifs.IVNic) {
+	aware, ok := handler.(ILeaderAwareService)
+	if !ok {
+		return
+	}
+	gName, gArea := this.resolveGroup(serviceName, serviceArea)
+	callbacks := &leadershipCallbacks{handler: aware, vnic: vnic, electionName: gName, electionArea: gArea,
+		localUuid: vnic.Resources().SysConfig().LocalUuid}
+	_, loaded := this.leaderAware.LoadOrStore(cacheKey(serviceName, serviceArea), callbacks)
+	if loaded {
+		return
+	}
+	this.leaderElection.events.Subscribe(callbacks)
+	if this.leaderElection.IsLeader(gName, gArea, callbacks.localUuid) {
+		this.leaderElection.events.notify(callbacks, &ElectionEvent{Type: LeaderElected,
+			ServiceName: gName, ServiceArea: gArea, Leader: callbacks.localUuid})
+	}
+}
+
+// unwatchLeadership stops calling the callbacks of a deactivated service handler. A
+// handler leading the service is told it lost the leadership first.
+func (this *ServiceManager) unwatchLeadership(serviceName string, serviceArea byte) {
+	callbacks, ok := this.leaderAware.LoadAndDelete(cacheKey(serviceName, serviceArea))
+	if ok {
+		callbacks.(*leadershipCallbacks).stop()
+		this.leaderElection.events.Unsubscribe(callbacks.(*leadershipCallbacks))
+	}
+}
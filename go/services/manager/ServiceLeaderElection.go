@@ -20,15 +20,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/election"
 	"github.com/saichler/l8types/go/ifs"
 )
 
-// Election timing constants
-const (
-	electionTimeout  = 3 * time.Second // Time to wait for election responses
-	heartbeatTimeout = 5 * time.Second // Time before leader is considered dead
-	heartbeatPeriod  = 2 * time.Second // Interval between leader heartbeats
-)
+// abstainTimeouts is the number of election timeouts a resigned leader stays out of elections.
+const abstainTimeouts = 3
 
 // electionState represents the current state of a node in the election process.
 type electionState byte
@@ -42,25 +40,34 @@ const (
 
 // leaderInfo holds the election state and timing information for a service.
 type leaderInfo struct {
-	leaderUuid        string
-	lastHeartbeat     time.Time
-	state             electionState
-	electionTimer     *time.Timer
-	ctx               context.Context
-	cancel            context.CancelFunc
-	wg                sync.WaitGroup
-	electionRunning   bool // Prevents concurrent elections
-	heartbeatRunning  bool // Prevents concurrent heartbeat goroutines
-	monitorRunning    bool // Prevents concurrent monitor goroutines
-	mtx               sync.RWMutex
+	leaderUuid       string
+	lastLeader       string // The last known leader, kept while there is none
+	epoch            int64  // The highest epoch seen for the service
+	epochLeader      string // The leader that claimed the highest epoch
+	claims           map[string]int64
+	heartbeats       map[string]time.Time // The last heartbeat of every leader heard since the last announcement
+	lastAlarm        time.Time            // The last time a split brain was detected
+	lastHeartbeat    time.Time
+	state            electionState
+	electionTimer    *time.Timer
+	ctx              context.Context
+	cancel           context.CancelFunc
+	wg               sync.WaitGroup
+	electionRunning  bool      // Prevents concurrent elections
+	heartbeatRunning bool      // Prevents concurrent heartbeat goroutines
+	monitorRunning   bool      // Prevents concurrent monitor goroutines
+	abstainUntil     time.Time // A resigned leader stays out of elections until then
+	mtx              sync.RWMutex
 }
 
 // LeaderElection manages distributed leader election using a bully algorithm.
 // Each service can have its own leader, tracked separately by service key.
-// Higher UUID values have higher priority in elections.
+// The service's election strategy decides which node has priority, by default
+// higher UUID values have higher priority in elections.
 type LeaderElection struct {
 	leaders        sync.Map // key: serviceKey string -> *leaderInfo
 	serviceManager *ServiceManager
+	events         *ElectionEvents
 	ctx            context.Context
 	cancel         context.CancelFunc
 	wg             sync.WaitGroup
@@ -71,6 +78,7 @@ func NewLeaderElection(sm *ServiceManager) *LeaderElection {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &LeaderElection{
 		serviceManager: sm,
+		events:         NewElectionEvents(),
 		ctx:            ctx,
 		cancel:         cancel,
 	}
@@ -78,20 +86,22 @@ func NewLeaderElection(sm *ServiceManager) *LeaderElection {
 
 // handleElection routes election-related messages to the appropriate handler
 // based on the action type (request, response, announcement, heartbeat, etc.).
-func (le *LeaderElection) handleElection(action ifs.Action, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+// Announcements and heartbeats carry the term of the leader, election requests and
+// responses the highest term their sender knows of.
+func (le *LeaderElection) handleElection(action ifs.Action, pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
 	switch action {
 	case ifs.ElectionRequest:
-		return le.handleElectionRequest(vnic, msg)
+		return le.handleElectionRequest(pb, vnic, msg)
 	case ifs.ElectionResponse:
-		return le.handleElectionResponse(vnic, msg)
+		return le.handleElectionResponse(pb, vnic, msg)
 	case ifs.LeaderAnnouncement:
-		return le.handleLeaderAnnouncement(vnic, msg)
+		return le.handleLeaderAnnouncement(pb, vnic, msg)
 	case ifs.LeaderHeartbeat:
-		return le.handleLeaderHeartbeat(vnic, msg)
+		return le.handleLeaderHeartbeat(pb, vnic, msg)
 	case ifs.LeaderQuery:
 		return le.handleLeaderQuery(vnic, msg)
 	case ifs.LeaderResign:
-		return le.handleLeaderResign(vnic, msg)
+		return le.handleLeaderResign(pb, vnic, msg)
 	case ifs.LeaderChallenge:
 		return le.handleLeaderChallenge(vnic, msg)
 	}
@@ -99,8 +109,10 @@ func (le *LeaderElection) handleElection(action ifs.Action, vnic ifs.IVNic, msg
 }
 
 // handleElectionRequest processes an incoming election request from another node.
-// If this node has higher priority (higher UUID), it responds and starts its own election.
-func (le *LeaderElection) handleElectionRequest(vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+// If this node outranks the sender, it responds and starts its own election. If it
+// knows of a higher epoch than the sender, it responds with it, so the sender is elected
+// with an epoch higher than all the epochs the voters know of.
+func (le *LeaderElection) handleElectionRequest(pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
 	localUuid := vnic.Resources().SysConfig().LocalUuid
 	senderUuid := msg.Source()
 
@@ -117,20 +129,36 @@ func (le *LeaderElection) handleElectionRequest(vnic ifs.IVNic, msg *ifs.Message
 		return nil
 	}
 
-	// If sender has lower priority (higher UUID), respond that we're still alive
-	if senderUuid < localUuid {
+	// A resigned leader lets the others elect a new leader
+	if le.abstains(msg.ServiceName(), msg.ServiceArea()) {
+		return nil
+	}
+
+	info := le.getOrCreateLeaderInfo(makeServiceKey(msg.ServiceName(), msg.ServiceArea()))
+	info.mtx.Lock()
+	epoch, ok := epochOf(pb)
+	if ok {
+		claim(info, termLeaderOf(pb), epoch)
+	}
+	highest := termOf(info.epochLeader, info.epoch)
+	info.mtx.Unlock()
+
+	// If sender has lower priority, respond that we're still alive
+	if le.outranks(localUuid, senderUuid, msg.ServiceName(), msg.ServiceArea()) {
 		vnic.Resources().Logger().Debug("Responding to election request and starting own election")
-		vnic.Unicast(senderUuid, msg.ServiceName(), msg.ServiceArea(), ifs.ElectionResponse, nil)
+		vnic.Unicast(senderUuid, msg.ServiceName(), msg.ServiceArea(), ifs.ElectionResponse, highest)
 		// Start our own election since we have higher priority
 		go le.startElection(msg.ServiceName(), msg.ServiceArea(), vnic)
+	} else if highest.Epoch > epoch {
+		vnic.Unicast(senderUuid, msg.ServiceName(), msg.ServiceArea(), ifs.ElectionResponse, highest)
 	}
 
 	return nil
 }
 
-// handleElectionResponse handles responses from higher-priority nodes,
-// indicating this node should abort its election attempt.
-func (le *LeaderElection) handleElectionResponse(vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+// handleElectionResponse handles responses from higher-priority nodes, indicating this
+// node should abort its election attempt, and learns the highest epoch they carry.
+func (le *LeaderElection) handleElectionResponse(pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
 	key := makeServiceKey(msg.ServiceName(), msg.ServiceArea())
 	info := le.getLeaderInfo(key)
 	if info == nil {
@@ -141,9 +169,12 @@ func (le *LeaderElection) handleElectionResponse(vnic ifs.IVNic, msg *ifs.Messag
 	vnic.Resources().Logger().Debug("Election response from", msg.Source(), "for", msg.ServiceName(), "area", msg.ServiceArea())
 
 	info.mtx.Lock()
+	if epoch, ok := epochOf(pb); ok {
+		claim(info, termLeaderOf(pb), epoch)
+	}
 
 	// If we're in an election and received a response, a higher-priority node exists
-	if info.state == electing {
+	if info.state == electing && le.outranksIn(msg.Source(), vnic.Resources().SysConfig().LocalUuid, msg.ServiceName(), msg.ServiceArea(), info) {
 		vnic.Resources().Logger().Debug("Higher priority node exists, aborting election")
 		info.state = hasLeader
 		// Don't call timer.Stop() - just change state and the election goroutine will handle it
@@ -155,7 +186,8 @@ func (le *LeaderElection) handleElectionResponse(vnic ifs.IVNic, msg *ifs.Messag
 
 // handleLeaderAnnouncement processes a leader announcement, updating local state
 // and starting either heartbeat sending (if leader) or monitoring (if follower).
-func (le *LeaderElection) handleLeaderAnnouncement(vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+// The announcement of a leader with a stale epoch is ignored.
+func (le *LeaderElection) handleLeaderAnnouncement(pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
 	key := makeServiceKey(msg.ServiceName(), msg.ServiceArea())
 	info := le.getOrCreateLeaderInfo(key)
 
@@ -165,16 +197,31 @@ func (le *LeaderElection) handleLeaderAnnouncement(vnic ifs.IVNic, msg *ifs.Mess
 	vnic.Resources().Logger().Debug("Leader announcement from", senderUuid, "for", msg.ServiceName(), "area", msg.ServiceArea())
 
 	info.mtx.Lock()
-	// Accept the leader announcement
+	epoch, ok := epochOf(pb)
+	newTerm := info.leaderUuid != senderUuid || (ok && epoch > info.claims[senderUuid])
+	if ok && claim(info, senderUuid, epoch) {
+		info.mtx.Unlock()
+		vnic.Resources().Logger().Debug("Ignoring announcement of deposed leader", senderUuid, "for", msg.ServiceName(), "area", msg.ServiceArea())
+		return nil
+	}
+	if newTerm {
+		le.publish(LeaderElected, msg.ServiceName(), msg.ServiceArea(), senderUuid, senderUuid, info)
+	}
+	// Accept the leader announcement, the leaders heard before are gone
+	info.heartbeats = map[string]time.Time{senderUuid: time.Now()}
 	info.leaderUuid = senderUuid
+	info.lastLeader = senderUuid
 	info.lastHeartbeat = time.Now()
 
+	le.serviceManager.tookOver(msg.ServiceName(), msg.ServiceArea(), senderUuid)
+
 	if senderUuid == localUuid {
 		vnic.Resources().Logger().Debug("I am the leader")
 		info.state = isLeader
 		info.mtx.Unlock()
 		// Don't stop timers here - let startHeartbeat handle it
 		le.startHeartbeat(key, msg.ServiceName(), msg.ServiceArea(), vnic)
+		go le.serviceManager.onLeaderElected(msg.ServiceName(), msg.ServiceArea(), vnic)
 	} else {
 		vnic.Resources().Logger().Debug("Following leader:", senderUuid)
 		info.state = hasLeader
@@ -188,19 +235,46 @@ func (le *LeaderElection) handleLeaderAnnouncement(vnic ifs.IVNic, msg *ifs.Mess
 }
 
 // handleLeaderHeartbeat updates the last heartbeat timestamp from the leader,
-// keeping the leader-follower relationship alive.
-func (le *LeaderElection) handleLeaderHeartbeat(vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+// keeping the leader-follower relationship alive. The heartbeat of a leader with a
+// stale epoch is ignored, one of a leader with a newer epoch deposes this node.
+// Heartbeats of two leaders at once reveal a split brain, which is challenged.
+func (le *LeaderElection) handleLeaderHeartbeat(pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
 	key := makeServiceKey(msg.ServiceName(), msg.ServiceArea())
 	info := le.getLeaderInfo(key)
 	if info == nil {
 		return nil
 	}
+	localUuid := vnic.Resources().SysConfig().LocalUuid
 
 	info.mtx.Lock()
+	rival := le.detectSplitBrain(msg.ServiceName(), msg.ServiceArea(), msg.Source(), info)
+	epoch, ok := epochOf(pb)
+	if ok && claim(info, msg.Source(), epoch) {
+		info.mtx.Unlock()
+		le.challenge(msg.ServiceName(), msg.ServiceArea(), msg.Source(), rival, vnic)
+		return nil
+	}
+	deposed := info.state == isLeader && msg.Source() != localUuid
+	term := info.claims[localUuid]
+	if deposed {
+		info.state = hasLeader
+		le.publish(LeaderDeposed, msg.ServiceName(), msg.ServiceArea(), localUuid, msg.Source(), info)
+	}
+	if info.leaderUuid != msg.Source() {
+		le.publish(LeaderElected, msg.ServiceName(), msg.ServiceArea(), msg.Source(), msg.Source(), info)
+	}
 	info.leaderUuid = msg.Source()
+	info.lastLeader = msg.Source()
 	info.lastHeartbeat = time.Now()
 	info.mtx.Unlock()
 
+	le.serviceManager.tookOver(msg.ServiceName(), msg.ServiceArea(), msg.Source())
+	le.challenge(msg.ServiceName(), msg.ServiceArea(), msg.Source(), rival, vnic)
+	if deposed {
+		vnic.Resources().Logger().Debug("Deposed as leader of", msg.ServiceName(), "area", msg.ServiceArea(), "by", msg.Source())
+		le.startHeartbeatMonitor(key, msg.ServiceName(), msg.ServiceArea(), vnic)
+		go le.serviceManager.onDeposed(msg.ServiceName(), msg.ServiceArea(), term, msg.Source(), vnic)
+	}
 	return nil
 }
 
@@ -223,7 +297,8 @@ func (le *LeaderElection) handleLeaderQuery(vnic ifs.IVNic, msg *ifs.Message) if
 
 		if state == isLeader && leaderUuid == localUuid {
 			// Respond that we are the leader
-			vnic.Unicast(msg.Source(), msg.ServiceName(), msg.ServiceArea(), ifs.LeaderAnnouncement, nil)
+			vnic.Unicast(msg.Source(), msg.ServiceName(), msg.ServiceArea(), ifs.LeaderAnnouncement,
+				le.currentTerm(msg.ServiceName(), msg.ServiceArea(), localUuid))
 		}
 	}
 
@@ -231,14 +306,28 @@ func (le *LeaderElection) handleLeaderQuery(vnic ifs.IVNic, msg *ifs.Message) if
 }
 
 // handleLeaderResign handles a leader resignation, clearing the leader state
-// and triggering a new election.
-func (le *LeaderElection) handleLeaderResign(vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+// and triggering a new election. A leader transferring its leadership names its
+// successor, which is preferred in the election.
+func (le *LeaderElection) handleLeaderResign(pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+	// Skip self-multicast
+	if msg.Source() == vnic.Resources().SysConfig().LocalUuid {
+		return nil
+	}
+
+	successor := successorOf(pb)
+	if successor != "" {
+		vnic.Resources().Logger().Debug("Leadership of", msg.ServiceName(), "area", msg.ServiceArea(), "transferred to", successor)
+		le.serviceManager.prefer(msg.ServiceName(), msg.ServiceArea(), successor)
+	}
+
 	key := makeServiceKey(msg.ServiceName(), msg.ServiceArea())
 	info := le.getLeaderInfo(key)
 
 	if info != nil {
 		shouldStopTimers := false
 		info.mtx.Lock()
+		le.publish(LeaderResigned, msg.ServiceName(), msg.ServiceArea(), msg.Source(), msg.Source(), info)
+		delete(info.heartbeats, msg.Source())
 		if info.leaderUuid == msg.Source() {
 			info.leaderUuid = ""
 			info.state = idle
@@ -270,13 +359,15 @@ func (le *LeaderElection) handleLeaderChallenge(vnic ifs.IVNic, msg *ifs.Message
 
 	localUuid := vnic.Resources().SysConfig().LocalUuid
 	if info != nil {
-		info.mtx.RLock()
+		info.mtx.Lock()
 		state := info.state
-		info.mtx.RUnlock()
+		le.publish(LeaderChallenged, msg.ServiceName(), msg.ServiceArea(), info.leaderUuid, msg.Source(), info)
+		info.mtx.Unlock()
 
 		if state == isLeader {
 			// Respond with heartbeat to prove we're still the leader
-			vnic.Multicast(msg.ServiceName(), msg.ServiceArea(), ifs.LeaderHeartbeat, nil)
+			vnic.Multicast(msg.ServiceName(), msg.ServiceArea(), ifs.LeaderHeartbeat,
+				le.currentTerm(msg.ServiceName(), msg.ServiceArea(), localUuid))
 		}
 	} else if vnic.Resources().SysConfig().LocalUuid == localUuid {
 		// No leader info, start election
@@ -300,6 +391,11 @@ func (le *LeaderElection) startElection(serviceName string, serviceArea byte, vn
 		return
 	}
 
+	if le.abstains(serviceName, serviceArea) {
+		vnic.Resources().Logger().Debug("Resigned from", serviceName, "area", serviceArea, "- skipping election")
+		return
+	}
+
 	key := makeServiceKey(serviceName, serviceArea)
 	info := le.getOrCreateLeaderInfo(key)
 
@@ -318,6 +414,8 @@ func (le *LeaderElection) startElection(serviceName string, serviceArea byte, vn
 	info.state = electing
 	info.electionRunning = true
 	ctx := info.ctx
+	le.publish(ElectionStarted, serviceName, serviceArea, info.epochLeader, vnic.Resources().SysConfig().LocalUuid, info)
+	highest := termOf(info.epochLeader, info.epoch)
 	info.mtx.Unlock()
 
 	// Track goroutine
@@ -334,12 +432,13 @@ func (le *LeaderElection) startElection(serviceName string, serviceArea byte, vn
 	vnic.Resources().Logger().Debug("Starting election for", serviceName, "area", serviceArea)
 
 	// Send election request to all nodes
-	vnic.Multicast(serviceName, serviceArea, ifs.ElectionRequest, nil)
+	vnic.Multicast(serviceName, serviceArea, ifs.ElectionRequest, highest)
 
-	vnic.Resources().Logger().Debug("Waiting", electionTimeout, "for election responses")
+	timeout := le.serviceManager.timingsOf(serviceName, serviceArea).ElectionTimeout
+	vnic.Resources().Logger().Debug("Waiting", timeout, "for election responses")
 
 	// Wait for responses or context cancellation
-	timer := time.NewTimer(electionTimeout)
+	timer := time.NewTimer(timeout)
 	defer timer.Stop()
 
 	info.mtx.Lock()
@@ -358,15 +457,30 @@ func (le *LeaderElection) startElection(serviceName string, serviceArea byte, vn
 	currentState := info.state
 	vnic.Resources().Logger().Debug("Election timeout elapsed, state:", currentState)
 
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	if currentState == electing && !le.eligible(localUuid, serviceName, serviceArea, info) {
+		// Another node still holds the leadership, try again once it may have expired
+		vnic.Resources().Logger().Debug("Not eligible to lead", serviceName, "area", serviceArea, "yet")
+		info.state = idle
+		info.mtx.Unlock()
+		time.AfterFunc(timeout, func() {
+			le.startElection(serviceName, serviceArea, vnic)
+		})
+		return
+	}
+
 	if currentState == electing {
 		// No higher-priority node responded, we are the leader
-		localUuid := vnic.Resources().SysConfig().LocalUuid
 		info.state = isLeader
 		info.leaderUuid = localUuid
+		info.lastLeader = localUuid
+		epoch := nextEpoch(info)
+		claim(info, localUuid, epoch)
+		le.publish(LeaderElected, serviceName, serviceArea, localUuid, localUuid, info)
 		info.mtx.Unlock()
 
-		vnic.Resources().Logger().Debug("Elected as leader for", serviceName, "area", serviceArea)
-		vnic.Multicast(serviceName, serviceArea, ifs.LeaderAnnouncement, nil)
+		vnic.Resources().Logger().Debug("Elected as leader for", serviceName, "area", serviceArea, "epoch", epoch)
+		vnic.Multicast(serviceName, serviceArea, ifs.LeaderAnnouncement, termOf(localUuid, epoch))
 		le.startHeartbeat(key, serviceName, serviceArea, vnic)
 	} else {
 		vnic.Resources().Logger().Debug("Not becoming leader, state changed to:", currentState)
@@ -375,6 +489,7 @@ func (le *LeaderElection) startElection(serviceName string, serviceArea byte, vn
 }
 
 // startHeartbeat begins periodic heartbeat multicasts to maintain leader status.
+// The period follows the service's current agreement, looked up again on every tick.
 func (le *LeaderElection) startHeartbeat(key string, serviceName string, serviceArea byte, vnic ifs.IVNic) {
 	info := le.getLeaderInfo(key)
 	if info == nil {
@@ -412,7 +527,9 @@ func (le *LeaderElection) startHeartbeat(key string, serviceName string, service
 			info.mtx.Unlock()
 		}()
 
-		ticker := time.NewTicker(heartbeatPeriod)
+		a := le.serviceManager.Agreement(serviceName, serviceArea)
+		changed := a.ElectionTimingsChanged()
+		ticker := time.NewTicker(a.ElectionTimings().HeartbeatPeriod)
 		defer ticker.Stop()
 
 		for {
@@ -426,7 +543,18 @@ func (le *LeaderElection) startHeartbeat(key string, serviceName string, service
 					return
 				}
 
-				vnic.Multicast(serviceName, serviceArea, ifs.LeaderHeartbeat, nil)
+				//The service may have been activated again with a new agreement
+				if current := le.serviceManager.Agreement(serviceName, serviceArea); current != a {
+					a = current
+					changed = a.ElectionTimingsChanged()
+					ticker.Reset(a.ElectionTimings().HeartbeatPeriod)
+				}
+
+				vnic.Multicast(serviceName, serviceArea, ifs.LeaderHeartbeat,
+					le.currentTerm(serviceName, serviceArea, vnic.Resources().SysConfig().LocalUuid))
+			case <-changed:
+				changed = a.ElectionTimingsChanged()
+				ticker.Reset(a.ElectionTimings().HeartbeatPeriod)
 			case <-ctx.Done():
 				return
 			}
@@ -435,6 +563,7 @@ func (le *LeaderElection) startHeartbeat(key string, serviceName string, service
 }
 
 // startHeartbeatMonitor watches for leader heartbeats and triggers election on timeout.
+// The timeout follows the service's current agreement, looked up again on every tick.
 func (le *LeaderElection) startHeartbeatMonitor(key string, serviceName string, serviceArea byte, vnic ifs.IVNic) {
 	info := le.getLeaderInfo(key)
 	if info == nil {
@@ -472,11 +601,18 @@ func (le *LeaderElection) startHeartbeatMonitor(key string, serviceName string,
 			info.mtx.Unlock()
 		}()
 
-		ticker := time.NewTicker(heartbeatTimeout)
+		a := le.serviceManager.Agreement(serviceName, serviceArea)
+		changed := a.ElectionTimingsChanged()
+		timeout := a.ElectionTimings().HeartbeatTimeout
+		ticker := time.NewTicker(timeout)
 		defer ticker.Stop()
 
 		for {
 			select {
+			case <-changed:
+				changed = a.ElectionTimingsChanged()
+				timeout = a.ElectionTimings().HeartbeatTimeout
+				ticker.Reset(timeout)
 			case <-ticker.C:
 				info.mtx.RLock()
 				lastHb := info.lastHeartbeat
@@ -487,9 +623,18 @@ func (le *LeaderElection) startHeartbeatMonitor(key string, serviceName string,
 					return
 				}
 
-				if time.Since(lastHb) > heartbeatTimeout {
+				//The service may have been activated again with a new agreement
+				if current := le.serviceManager.Agreement(serviceName, serviceArea); current != a {
+					a = current
+					changed = a.ElectionTimingsChanged()
+					timeout = a.ElectionTimings().HeartbeatTimeout
+					ticker.Reset(timeout)
+				}
+
+				if time.Since(lastHb) > timeout {
 					vnic.Resources().Logger().Debug("Leader heartbeat timeout for", serviceName, "area", serviceArea)
 					info.mtx.Lock()
+					le.publish(HeartbeatLost, serviceName, serviceArea, info.leaderUuid, vnic.Resources().SysConfig().LocalUuid, info)
 					info.state = idle
 					info.leaderUuid = ""
 					info.mtx.Unlock()
@@ -543,6 +688,8 @@ func (le *LeaderElection) getOrCreateLeaderInfo(key string) *leaderInfo {
 	newInfo := &leaderInfo{
 		state:         idle,
 		lastHeartbeat: time.Now(),
+		claims:        make(map[string]int64),
+		heartbeats:    make(map[string]time.Time),
 		ctx:           ctx,
 		cancel:        cancel,
 	}
@@ -564,6 +711,121 @@ func (le *LeaderElection) StartElectionForService(serviceName string, serviceAre
 	go le.startElection(serviceName, serviceArea, vnic)
 }
 
+// resign gives up the leadership of a service, if this node holds it, and multicasts the
+// resignation so the other nodes elect a new leader, the successor if one is named. This
+// node stays out of the elections for the abstain period. Returns false if this node was
+// not the leader.
+func (le *LeaderElection) resign(serviceName string, serviceArea byte, successor string, vnic ifs.IVNic) bool {
+	key := makeServiceKey(serviceName, serviceArea)
+	info := le.getLeaderInfo(key)
+	if info == nil {
+		return false
+	}
+
+	info.mtx.Lock()
+	if info.state != isLeader {
+		info.mtx.Unlock()
+		return false
+	}
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	le.publish(LeaderResigned, serviceName, serviceArea, localUuid, localUuid, info)
+	delete(info.heartbeats, localUuid)
+	info.state = idle
+	info.leaderUuid = ""
+	info.abstainUntil = time.Now().Add(le.serviceManager.abstainPeriodOf(serviceName, serviceArea))
+	info.mtx.Unlock()
+
+	le.stopTimers(info)
+	vnic.Resources().Logger().Debug("Resigning leadership of", serviceName, "area", serviceArea)
+	if successor != "" {
+		vnic.Multicast(serviceName, serviceArea, ifs.LeaderResign, termOf(successor, 0))
+	} else {
+		vnic.Multicast(serviceName, serviceArea, ifs.LeaderResign, nil)
+	}
+	return true
+}
+
+// awaitLeader waits up to the given timeout for a leader, other than this node, to be
+// elected for a service. Returns its UUID, or an empty string if none was elected.
+func (le *LeaderElection) awaitLeader(serviceName string, serviceArea byte, localUuid string, timeout time.Duration) string {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) && le.ctx.Err() == nil {
+		leader := le.GetLeader(serviceName, serviceArea)
+		if leader != "" && leader != localUuid {
+			return leader
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return ""
+}
+
+// publish publishes an election event of a service, tagged with the leader's epoch. The
+// caller holds the lock of the leader info.
+func (le *LeaderElection) publish(eventType ElectionEventType, serviceName string, serviceArea byte, leader, node string, info *leaderInfo) {
+	le.events.publish(eventType, serviceName, serviceArea, leader, info.claims[leader], node)
+}
+
+// timingsOf returns the election timings of a service on this node.
+func (this *ServiceManager) timingsOf(serviceName string, serviceArea byte) agreement.ElectionTimings {
+	return this.Agreement(serviceName, serviceArea).ElectionTimings()
+}
+
+// abstainPeriodOf returns the time a resigned leader of a service stays out of its elections.
+func (this *ServiceManager) abstainPeriodOf(serviceName string, serviceArea byte) time.Duration {
+	return abstainTimeouts * this.timingsOf(serviceName, serviceArea).ElectionTimeout
+}
+
+// candidacyOf returns what this node knows of a service's election. The caller holds the
+// read or write lock of the leader info, if any.
+func candidacyOf(serviceName string, serviceArea byte, info *leaderInfo, r ifs.IResources) *election.Candidacy {
+	c := &election.Candidacy{ServiceName: serviceName, ServiceArea: serviceArea, Resources: r}
+	if info != nil {
+		c.Leader = info.leaderUuid
+		if c.Leader == "" {
+			c.Leader = info.lastLeader
+		}
+		c.LastHeartbeat = info.lastHeartbeat
+	}
+	return c
+}
+
+// outranks returns true if, by the service's election strategy, the candidate should lead
+// the service rather than the other node.
+func (le *LeaderElection) outranks(candidate, other string, serviceName string, serviceArea byte) bool {
+	info := le.getLeaderInfo(makeServiceKey(serviceName, serviceArea))
+	if info == nil {
+		return le.outranksIn(candidate, other, serviceName, serviceArea, nil)
+	}
+	info.mtx.RLock()
+	defer info.mtx.RUnlock()
+	return le.outranksIn(candidate, other, serviceName, serviceArea, info)
+}
+
+// outranksIn is outranks for the given leader info. The caller holds its lock.
+func (le *LeaderElection) outranksIn(candidate, other string, serviceName string, serviceArea byte, info *leaderInfo) bool {
+	c := candidacyOf(serviceName, serviceArea, info, le.serviceManager.resources)
+	return le.serviceManager.Agreement(serviceName, serviceArea).ElectionStrategy().Outranks(candidate, other, c)
+}
+
+// eligible returns true if, by the service's election strategy, the node may become the
+// leader now. The caller holds the lock of the leader info.
+func (le *LeaderElection) eligible(node string, serviceName string, serviceArea byte, info *leaderInfo) bool {
+	c := candidacyOf(serviceName, serviceArea, info, le.serviceManager.resources)
+	return le.serviceManager.Agreement(serviceName, serviceArea).ElectionStrategy().Eligible(node, c)
+}
+
+// abstains returns true if this node resigned the leadership of a service and stays out
+// of its elections for now.
+func (le *LeaderElection) abstains(serviceName string, serviceArea byte) bool {
+	info := le.getLeaderInfo(makeServiceKey(serviceName, serviceArea))
+	if info == nil {
+		return false
+	}
+	info.mtx.RLock()
+	defer info.mtx.RUnlock()
+	return time.Now().Before(info.abstainUntil)
+}
+
 // GetLeader returns the UUID of the current leader for a service, or empty string if none.
 func (le *LeaderElection) GetLeader(serviceName string, serviceArea byte) string {
 	key := makeServiceKey(serviceName, serviceArea)
@@ -0,0 +1,105 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// termOf returns the term record of a leader and epoch, carried as an L8LeaderTerm by the
+// leader's announcements and heartbeats. Every election issues an epoch to the leader it
+// elects, one above the highest epoch the leader knows of. A candidate sends the highest
+// term it knows of on its election request, and the voters knowing of a higher one respond
+// with it, so the elected leader learns the epochs of the previous leaders from the voters.
+// Every node binds the epochs to the leaders that claimed them, and the leader carries its
+// epoch on the phase messages of its transactions. A leader whose epoch is lower than the
+// highest one a node has seen was deposed, and the node fences it off.
+func termOf(leader string, epoch int64) *l8svcs.L8LeaderTerm {
+	return &l8svcs.L8LeaderTerm{Leader: leader, Epoch: epoch}
+}
+
+// epochOf returns the epoch carried by an election message, false if it carries none.
+func epochOf(pb ifs.IElements) (int64, bool) {
+	if pb == nil {
+		return 0, false
+	}
+	term, ok := pb.Element().(*l8svcs.L8LeaderTerm)
+	if !ok || term == nil || term.Epoch == 0 {
+		return 0, false
+	}
+	return term.Epoch, true
+}
+
+// termLeaderOf returns the leader of the term carried by an election message.
+func termLeaderOf(pb ifs.IElements) string {
+	term, ok := pb.Element().(*l8svcs.L8LeaderTerm)
+	if !ok || term == nil {
+		return ""
+	}
+	return term.Leader
+}
+
+// nextEpoch returns a new epoch, higher than any epoch seen for the service. The caller
+// holds the lock of the leader info.
+func nextEpoch(info *leaderInfo) int64 {
+	return info.epoch + 1
+}
+
+// claim records the epoch claimed by a leader and returns true if it is stale, lower than
+// the highest epoch seen for the service. The caller holds the lock of the leader info.
+func claim(info *leaderInfo, leader string, epoch int64) bool {
+	if epoch > info.claims[leader] {
+		info.claims[leader] = epoch
+	}
+	if epoch > info.epoch {
+		info.epoch = epoch
+		info.epochLeader = leader
+	}
+	return epoch < info.epoch
+}
+
+// currentTerm returns the term record of this node's leadership of a service.
+func (le *LeaderElection) currentTerm(serviceName string, serviceArea byte, localUuid string) *l8svcs.L8LeaderTerm {
+	info := le.getLeaderInfo(makeServiceKey(serviceName, serviceArea))
+	if info == nil {
+		return termOf(localUuid, 0)
+	}
+	info.mtx.RLock()
+	defer info.mtx.RUnlock()
+	return termOf(localUuid, info.claims[localUuid])
+}
+
+// Epochs returns the epoch a node claimed for the leadership of a service, 0 if it did
+// not claim it, and the highest epoch seen for the service.
+func (le *LeaderElection) Epochs(serviceName string, serviceArea byte, node string) (int64, int64) {
+	info := le.getLeaderInfo(makeServiceKey(serviceName, serviceArea))
+	if info == nil {
+		return 0, 0
+	}
+	info.mtx.RLock()
+	defer info.mtx.RUnlock()
+	return info.claims[node], info.epoch
+}
+
+// Epoch returns the highest epoch seen for a service and the leader that claimed it.
+func (le *LeaderElection) Epoch(serviceName string, serviceArea byte) (int64, string) {
+	info := le.getLeaderInfo(makeServiceKey(serviceName, serviceArea))
+	if info == nil {
+		return 0, ""
+	}
+	info.mtx.RLock()
+	defer info.mtx.RUnlock()
+	return info.epoch, info.epochLeader
+}
@@ -0,0 +1,264 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"sync"
+	"time"
+)
+
+// leadershipHistoryLimit is the number of leadership terms kept per service.
+const leadershipHistoryLimit = 100
+
+// electionQueueLimit is the number of events queued for a listener that did not take
+// them yet, older events are dropped to make room for new ones.
+const electionQueueLimit = 1000
+
+// ElectionEventType is the kind of an election event.
+type ElectionEventType byte
+
+const (
+	// ElectionStarted is published when this node runs for the leadership.
+	ElectionStarted ElectionEventType = iota + 1
+	// LeaderElected is published when this node learns of a new leader, itself included.
+	LeaderElected
+	// HeartbeatLost is published when this node stops hearing the leader's heartbeats.
+	HeartbeatLost
+	// LeaderResigned is published when a leader, this node included, resigns.
+	LeaderResigned
+	// LeaderChallenged is published when a node challenges the leadership.
+	LeaderChallenged
+	// LeaderDeposed is published when this node learns it was deposed as the leader.
+	LeaderDeposed
+	// SplitBrain is published when this node hears the heartbeats of two leaders at once.
+	SplitBrain
+)
+
+// String returns the name of the event type.
+func (this ElectionEventType) String() string {
+	switch this {
+	case ElectionStarted:
+		return "ElectionStarted"
+	case LeaderElected:
+		return "LeaderElected"
+	case HeartbeatLost:
+		return "HeartbeatLost"
+	case LeaderResigned:
+		return "LeaderResigned"
+	case LeaderChallenged:
+		return "LeaderChallenged"
+	case LeaderDeposed:
+		return "LeaderDeposed"
+	case SplitBrain:
+		return "SplitBrain"
+	}
+	return "Unknown"
+}
+
+// ElectionEvent is an event of a service's leader election, as seen by this node, tagged
+// with the term it concerns, the leader and its epoch. Node is the node that caused it,
+// e.g. the candidate, the resigning leader or the challenger.
+type ElectionEvent struct {
+	Type        ElectionEventType
+	ServiceName string
+	ServiceArea byte
+	Leader      string
+	Epoch       int64
+	Node        string
+	Time        time.Time
+}
+
+// LeadershipTerm is a leadership of a service, as seen by this node. A term that is still
+// running has no End, the others tell the event that ended them.
+type LeadershipTerm struct {
+	Leader  string
+	Epoch   int64
+	Start   time.Time
+	End     time.Time
+	EndedBy ElectionEventType
+}
+
+// IElectionListener gets the election events of the services of this node. The events of
+// a listener are delivered in order, one at a time, and do not hold back the elections. A
+// listener falling behind by more than electionQueueLimit events loses the oldest ones.
+type IElectionListener interface {
+	OnElectionEvent(event *ElectionEvent)
+}
+
+// electionSubscriber queues the events of a listener for its delivery goroutine, and
+// counts the events dropped as the queue was full.
+type electionSubscriber struct {
+	listener IElectionListener
+	queue    []*ElectionEvent
+	dropped  int
+	closed   bool
+	cond     *sync.Cond
+}
+
+// ElectionEvents publishes the election events to the subscribed listeners and keeps a
+// bounded history of the leadership terms of every service.
+type ElectionEvents struct {
+	subscribers []*electionSubscriber
+	history     map[string][]*LeadershipTerm
+	mtx         *sync.Mutex
+}
+
+// NewElectionEvents creates an event stream with no listeners.
+func NewElectionEvents() *ElectionEvents {
+	return &ElectionEvents{history: make(map[string][]*LeadershipTerm), mtx: &sync.Mutex{}}
+}
+
+// Subscribe adds a listener getting the election events published from now on.
+func (this *ElectionEvents) Subscribe(listener IElectionListener) {
+	s := &electionSubscriber{listener: listener, cond: sync.NewCond(&sync.Mutex{})}
+	this.mtx.Lock()
+	this.subscribers = append(this.subscribers, s)
+	this.mtx.Unlock()
+	go s.deliver()
+}
+
+// Unsubscribe removes a listener, the events already queued for it are dropped.
+func (this *ElectionEvents) Unsubscribe(listener IElectionListener) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	for i, s := range this.subscribers {
+		if s.listener == listener {
+			this.subscribers = append(this.subscribers[:i], this.subscribers[i+1:]...)
+			s.close()
+			return
+		}
+	}
+}
+
+// notify queues an event to a single listener, without publishing it.
+func (this *ElectionEvents) notify(listener IElectionListener, event *ElectionEvent) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	for _, s := range this.subscribers {
+		if s.listener == listener {
+			s.push(event)
+			return
+		}
+	}
+}
+
+// Dropped returns the number of events a listener lost as it fell behind.
+func (this *ElectionEvents) Dropped(listener IElectionListener) int {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	for _, s := range this.subscribers {
+		if s.listener == listener {
+			s.cond.L.Lock()
+			defer s.cond.L.Unlock()
+			return s.dropped
+		}
+	}
+	return 0
+}
+
+// History returns the leadership terms of a service, oldest first.
+func (this *ElectionEvents) History(serviceName string, serviceArea byte) []*LeadershipTerm {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	terms := this.history[makeServiceKey(serviceName, serviceArea)]
+	result := make([]*LeadershipTerm, len(terms))
+	for i, term := range terms {
+		clone := *term
+		result[i] = &clone
+	}
+	return result
+}
+
+// publish records an event in the history of its service and queues it to the listeners.
+func (this *ElectionEvents) publish(eventType ElectionEventType, serviceName string, serviceArea byte, leader string, epoch int64, node string) {
+	event := &ElectionEvent{Type: eventType, ServiceName: serviceName, ServiceArea: serviceArea,
+		Leader: leader, Epoch: epoch, Node: node, Time: time.Now()}
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.record(event)
+	for _, s := range this.subscribers {
+		s.push(event)
+	}
+}
+
+// record starts a new term when a leader is elected, and ends the running term when its
+// leader is gone. The caller holds the lock.
+func (this *ElectionEvents) record(event *ElectionEvent) {
+	key := makeServiceKey(event.ServiceName, event.ServiceArea)
+	terms := this.history[key]
+	var current *LeadershipTerm
+	if len(terms) > 0 && terms[len(terms)-1].End.IsZero() {
+		current = terms[len(terms)-1]
+	}
+	switch event.Type {
+	case LeaderElected:
+		if current != nil {
+			if current.Leader == event.Leader && current.Epoch == event.Epoch {
+				return
+			}
+			current.End = event.Time
+			current.EndedBy = LeaderElected
+		}
+		terms = append(terms, &LeadershipTerm{Leader: event.Leader, Epoch: event.Epoch, Start: event.Time})
+		if len(terms) > leadershipHistoryLimit {
+			terms = terms[len(terms)-leadershipHistoryLimit:]
+		}
+		this.history[key] = terms
+	case HeartbeatLost, LeaderResigned, LeaderDeposed:
+		if current != nil && current.Leader == event.Leader {
+			current.End = event.Time
+			current.EndedBy = event.Type
+		}
+	}
+}
+
+// push queues an event for the listener, dropping the oldest queued event if it is full.
+func (this *electionSubscriber) push(event *ElectionEvent) {
+	this.cond.L.Lock()
+	defer this.cond.L.Unlock()
+	if len(this.queue) >= electionQueueLimit {
+		this.queue[0] = nil
+		this.queue = this.queue[1:]
+		this.dropped++
+	}
+	this.queue = append(this.queue, event)
+	this.cond.Signal()
+}
+
+// close stops the delivery of the listener's events.
+func (this *electionSubscriber) close() {
+	this.cond.L.Lock()
+	defer this.cond.L.Unlock()
+	this.closed = true
+	this.queue = nil
+	this.cond.Signal()
+}
+
+// deliver hands the queued events to the listener, in order, until it is closed.
+func (this *electionSubscriber) deliver() {
+	for {
+		this.cond.L.Lock()
+		for len(this.queue) == 0 && !this.closed {
+			this.cond.Wait()
+		}
+		if this.closed {
+			this.cond.L.Unlock()
+			return
+		}
+		event := this.queue[0]
+		this.queue = this.queue[1:]
+		this.cond.L.Unlock()
+		this.listener.OnElectionEvent(event)
+	}
+}
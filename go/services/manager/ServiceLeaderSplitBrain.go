@@ -0,0 +1,103 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// A partition lets each side elect its own leader and commit writes. Once it heals, the
+// nodes hear the heartbeats of both leaders within a heartbeat timeout, a split brain.
+// The node detecting it raises an alarm and challenges the leadership, so both leaders
+// multicast a heartbeat with their term, and the leader with the older epoch is deposed
+// by the heartbeat of the other one. The deposed leader resyncs the keys it set in its
+// deposed term from the new leader, on itself and on the participants that applied them,
+// and quarantines the writes that diverged from the new leader's state. By the service's
+// split brain policy they are kept for the operators or replayed on the new leader.
+
+// detectSplitBrain records the heartbeat of a leader and returns another leader heard
+// within the heartbeat timeout, if any, unless a split brain was already raised within
+// it. The caller holds the lock of the leader info.
+func (le *LeaderElection) detectSplitBrain(serviceName string, serviceArea byte, leader string, info *leaderInfo) string {
+	now := time.Now()
+	timeout := le.serviceManager.timingsOf(serviceName, serviceArea).HeartbeatTimeout
+	info.heartbeats[leader] = now
+	rival := ""
+	for other, last := range info.heartbeats {
+		if other == leader {
+			continue
+		}
+		if now.Sub(last) > timeout {
+			delete(info.heartbeats, other)
+			continue
+		}
+		rival = other
+	}
+	if rival == "" || now.Sub(info.lastAlarm) < timeout {
+		return ""
+	}
+	info.lastAlarm = now
+	le.publish(SplitBrain, serviceName, serviceArea, leader, rival, info)
+	return rival
+}
+
+// challenge raises the alarm of a split brain between the leader and the rival leader,
+// if any, and multicasts a challenge so the leaders prove their terms.
+func (le *LeaderElection) challenge(serviceName string, serviceArea byte, leader, rival string, vnic ifs.IVNic) {
+	if rival == "" {
+		return
+	}
+	vnic.Resources().Logger().Error("LeaderElection: split brain detected for ", serviceName, " area ", serviceArea,
+		", heard heartbeats of ", leader, " and ", rival)
+	vnic.Multicast(serviceName, serviceArea, ifs.LeaderChallenge, nil)
+}
+
+// onDeposed is invoked when this node learns it was deposed from its term of the given
+// epoch as the leader of a service or group, by the given leader. The keys the services
+// set in the term are resynced from the new leader, the writes that diverged from its
+// state are quarantined and replayed on it if the service's policy says so.
+func (this *ServiceManager) onDeposed(electionName string, electionArea byte, epoch int64, leader string, vnic ifs.IVNic) {
+	for _, s := range this.balancer.services() {
+		if s.electionName != electionName || s.electionArea != electionArea {
+			continue
+		}
+		if this.trManager.Quarantine(s.serviceName, s.serviceArea, epoch, leader, vnic) == 0 {
+			continue
+		}
+		if this.Agreement(s.serviceName, s.serviceArea).SplitBrainPolicy() == agreement.SplitBrainReplay {
+			this.trManager.Reconcile(s.serviceName, s.serviceArea, vnic)
+		}
+	}
+}
+
+// DivergentWrites returns the writes of a service this node committed as a deposed
+// leader, that are quarantined until they are reconciled or discarded.
+func (this *ServiceManager) DivergentWrites(serviceName string, serviceArea byte) []*states.DivergentWrite {
+	return this.trManager.DivergentWrites().Of(serviceName, serviceArea)
+}
+
+// ReconcileDivergentWrites replays the quarantined writes of a service on its leader,
+// returning how many of them it committed.
+func (this *ServiceManager) ReconcileDivergentWrites(serviceName string, serviceArea byte, vnic ifs.IVNic) int {
+	return this.trManager.Reconcile(serviceName, serviceArea, vnic)
+}
+
+// DiscardDivergentWrite drops a quarantined write of a service, keeping the new leader's state.
+func (this *ServiceManager) DiscardDivergentWrite(write *states.DivergentWrite) {
+	this.trManager.DivergentWrites().Remove(write)
+}
@@ -0,0 +1,110 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manager
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// A leader transferring its leadership names the successor on its resignation, as an
+// L8LeaderTerm whose leader is the successor and whose epoch is 0, so it is not taken
+// for a term. Every node receiving it prefers the successor in the service's
+// election strategy, so the successor outranks the other nodes and gets elected. The
+// preference is cleared once the successor took over, so it does not outlive the transfer.
+
+// successorOf returns the successor named by a resignation, or an empty string.
+func successorOf(pb ifs.IElements) string {
+	if pb == nil {
+		return ""
+	}
+	term, ok := pb.Element().(*l8svcs.L8LeaderTerm)
+	if !ok || term == nil || term.Epoch != 0 {
+		return ""
+	}
+	return term.Leader
+}
+
+// TransferLeadership hands this node's leadership of a service to the target node, e.g.
+// before maintenance. The transactions in flight are completed first, then this node
+// resigns naming the target as its successor, and the queued transactions are handed off
+// to the new leader. Returns the number of handed off transactions, or an error if this
+// node is not the leader, the target is not a participant of the service or the target
+// did not take over.
+func (this *ServiceManager) TransferLeadership(serviceName string, serviceArea byte, target string, vnic ifs.IVNic) (int, error) {
+	gName, gArea := this.resolveGroup(serviceName, serviceArea)
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	if !this.leaderElection.IsLeader(gName, gArea, localUuid) {
+		return 0, errors.New("TransferLeadership: this node is not the leader of " + serviceName +
+			" area " + strconv.Itoa(int(serviceArea)))
+	}
+	if target == localUuid {
+		return 0, errors.New("TransferLeadership: this node is already the leader of " + serviceName +
+			" area " + strconv.Itoa(int(serviceArea)))
+	}
+	if !this.participantRegistry.IsParticipant(gName, gArea, target) {
+		return 0, errors.New("TransferLeadership: " + target + " is not a participant of " + serviceName +
+			" area " + strconv.Itoa(int(serviceArea)))
+	}
+	this.prefer(gName, gArea, target)
+	handedOff := this.relinquish(serviceName, serviceArea, target, vnic)
+	leader := this.leaderElection.GetLeader(gName, gArea)
+	if leader != target {
+		this.tookOver(gName, gArea, target)
+		return handedOff, errors.New("TransferLeadership: " + target + " did not take over " + serviceName +
+			" area " + strconv.Itoa(int(serviceArea)) + ", the leader is " + leader)
+	}
+	vnic.Resources().Logger().Info("TransferLeadership: leadership of ", serviceName, " area ", serviceArea,
+		" transferred to ", target)
+	return handedOff, nil
+}
+
+// StepDown gives up this node's leadership of a service to whichever node the others
+// elect, e.g. before maintenance. Unlike ResignLeadership, it is an error if no other
+// node took over. Returns the new leader and the number of handed off transactions.
+func (this *ServiceManager) StepDown(serviceName string, serviceArea byte, vnic ifs.IVNic) (string, int, error) {
+	handedOff, err := this.ResignLeadership(serviceName, serviceArea, vnic)
+	if err != nil {
+		return "", 0, err
+	}
+	leader := this.GetLeader(serviceName, serviceArea)
+	if leader == "" || leader == vnic.Resources().SysConfig().LocalUuid {
+		return leader, handedOff, errors.New("StepDown: no other node took over " + serviceName +
+			" area " + strconv.Itoa(int(serviceArea)))
+	}
+	return leader, handedOff, nil
+}
+
+// relinquish resigns this node's leadership of a service, naming the successor if any,
+// and hands the queued transactions off to the new leader. If no other node took over
+// once this node stopped abstaining, it runs for the leadership again and keeps its
+// queue. Returns the number of handed off transactions.
+func (this *ServiceManager) relinquish(serviceName string, serviceArea byte, successor string, vnic ifs.IVNic) int {
+	gName, gArea := this.resolveGroup(serviceName, serviceArea)
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	return this.trManager.HandOff(serviceName, serviceArea, vnic, func() string {
+		if !this.leaderElection.resign(gName, gArea, successor, vnic) {
+			return this.leaderElection.GetLeader(gName, gArea)
+		}
+		leader := this.leaderElection.awaitLeader(gName, gArea, localUuid, this.abstainPeriodOf(gName, gArea))
+		if leader == "" {
+			this.leaderElection.startElection(gName, gArea, vnic)
+			leader = this.leaderElection.GetLeader(gName, gArea)
+		}
+		return leader
+	})
+}
@@ -18,13 +18,20 @@ package manager
 
 import (
 	"bytes"
+	"errors"
 	"reflect"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/saichler/l8bus/go/overlay/health"
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/leadership"
 	"github.com/saichler/l8services/go/services/replication"
+	"github.com/saichler/l8services/go/services/saga"
+	"github.com/saichler/l8services/go/services/transaction/metrics"
 	"github.com/saichler/l8services/go/services/transaction/states"
+	"github.com/saichler/l8services/go/types/l8svcs"
 	"github.com/saichler/l8srlz/go/serialize/object"
 	"github.com/saichler/l8types/go/ifs"
 	"github.com/saichler/l8types/go/types/l8notify"
@@ -41,6 +48,11 @@ type ServiceManager struct {
 	leaderElection      *LeaderElection
 	participantRegistry *ParticipantRegistry
 	electionDebouncer   *ElectionDebouncer
+	balancer            *LeadershipBalancer
+	rebalancer          *KeyRebalancer
+	leaderAware         sync.Map // serviceKey → *leadershipCallbacks of the leader aware handlers
+	slas                sync.Map // serviceKey → *ifs.ServiceLevelAgreement the service was activated with
+	agreements          sync.Map // serviceKey → *agreement.Agreement of the service on this node
 	serviceToGroup      sync.Map // serviceKey → groupName string (only non-identity mappings)
 }
 
@@ -53,14 +65,41 @@ func NewServices(resources ifs.IResources) ifs.IServices {
 	sp.resources = resources
 	sp.trManager = states.NewTransactionManager(sp)
 	sp.leaderElection = NewLeaderElection(sp)
+	sp.trManager.SetEpochs(sp.epochs)
 	sp.participantRegistry = NewParticipantRegistry(sp.resolveGroup)
 	sp.electionDebouncer = NewElectionDebouncer(sp.leaderElection)
+	sp.balancer = NewLeadershipBalancer(sp)
+	sp.rebalancer = NewKeyRebalancer(sp)
+	sp.participantRegistry.onChange = sp.participantsChanged
 	_, err := sp.resources.Registry().Register(&l8notify.L8NotificationSet{})
 	if err != nil {
 		panic(err)
 	}
 	sp.resources.Registry().Register(&l8services.L8Transaction{})
+	sp.resources.Registry().Register(&l8svcs.L8ServiceMetrics{})
+	sp.resources.Registry().Register(&l8svcs.L8ServiceMetricsList{})
+	sp.resources.Registry().Register(&l8svcs.L8InDoubtQuery{})
+	sp.resources.Registry().Register(&l8svcs.L8DecisionQuery{})
+	sp.resources.Registry().Register(&l8svcs.L8TransactionStatusQuery{})
+	sp.resources.Registry().Register(&l8svcs.L8InDoubtTransaction{})
+	sp.resources.Registry().Register(&l8svcs.L8InDoubtTransactionList{})
+	sp.resources.Registry().Register(&l8svcs.L8InDoubtResolution{})
+	sp.resources.Registry().Register(&l8svcs.L8Revisioned{})
+	sp.resources.Registry().Register(&l8svcs.L8ConsistentRead{})
+	sp.resources.Registry().Register(&l8svcs.L8IdempotentRequest{})
+	sp.resources.Registry().Register(&l8svcs.L8BatchStep{})
+	sp.resources.Registry().Register(&l8svcs.L8Phase{})
+	sp.resources.Registry().Register(&l8svcs.L8LeaderTerm{})
+	sp.resources.Registry().Register(&l8svcs.L8LeaderWeight{})
+	sp.resources.Registry().Register(&l8svcs.L8LeadershipRequest{})
+	sp.resources.Registry().Register(&l8svcs.L8SyncElement{})
+	sp.resources.Registry().Register(&l8svcs.L8Resync{})
+	sp.resources.Registry().Register(&l8svcs.L8KeyMove{})
+	sp.resources.Registry().Register(&l8svcs.L8Rebalance{})
 	sp.resources.Registry().Register(&replication.ReplicationService{})
+	sp.resources.Registry().Register(&metrics.TransactionMetricsService{})
+	sp.resources.Registry().Register(&leadership.LeadershipAdminService{})
+	sp.resources.Registry().Register(&saga.SagaService{})
 	return sp
 }
 
@@ -88,12 +127,12 @@ func (this *ServiceManager) Handle(pb ifs.IElements, action ifs.Action, msg *ifs
 	// Handle participant registry actions
 	if action >= ifs.ServiceRegister && action <= ifs.ServiceQuery {
 		vnic.Resources().Logger().Debug("Routing to participant registry, action:", action)
-		return this.participantRegistry.handleRegistry(action, vnic, msg)
+		return this.participantRegistry.handleRegistry(action, pb, vnic, msg)
 	}
 
 	// Handle leader election actions
 	if action >= ifs.ElectionRequest && action <= ifs.LeaderChallenge {
-		return this.leaderElection.handleElection(action, vnic, msg)
+		return this.leaderElection.handleElection(action, pb, vnic, msg)
 	}
 
 	if msg.Action() == ifs.EndPoints {
@@ -101,6 +140,14 @@ func (this *ServiceManager) Handle(pb ifs.IElements, action ifs.Action, msg *ifs
 		return nil
 	}
 
+	// A decision query asks the node that coordinated a transaction for its outcome, a
+	// multi-service transaction may be coordinated by a node that does not run the service
+	if action == ifs.GET && pb != nil {
+		if query, ok := pb.Element().(*l8svcs.L8DecisionQuery); ok {
+			return this.trManager.Decision(query)
+		}
+	}
+
 	h, ok := this.services.get(msg.ServiceName(), msg.ServiceArea())
 	if !ok {
 		hp := health.HealthOf(vnic.Resources().SysConfig().LocalUuid, vnic.Resources())
@@ -116,6 +163,40 @@ func (this *ServiceManager) Handle(pb ifs.IElements, action ifs.Action, msg *ifs
 		return h.Failed(pb, vnic, msg)
 	}
 
+	// A GET of a status query looks up the status of a transaction, the in-doubt
+	// queries resolve the transactions a failed leader left behind
+	if action == ifs.GET && h.TransactionConfig() != nil {
+		switch query := pb.Element().(type) {
+		case *l8svcs.L8TransactionStatusQuery:
+			return this.trManager.Status(query, msg, vnic)
+		case *l8svcs.L8InDoubtQuery:
+			return this.trManager.InDoubt(msg, vnic)
+		case *l8svcs.L8Resync:
+			return this.trManager.Snapshot(query, msg, vnic)
+		}
+	}
+
+	// A PUT of a resync writes the leader's state of the keys a deposed leader diverged on,
+	// a PUT of a resolution runs the new leader's decision on an in-doubt transaction and
+	// a PUT of a batch step runs a phase of an operation of a multi-service transaction
+	if action == ifs.PUT && h.TransactionConfig() != nil {
+		switch state := pb.Element().(type) {
+		case *l8svcs.L8Resync:
+			return this.trManager.Resync(state, msg, vnic)
+		case *l8svcs.L8InDoubtResolution:
+			return this.trManager.Resolve(state, msg, vnic)
+		case *l8svcs.L8BatchStep:
+			return this.trManager.Step(state, msg, vnic)
+		}
+	}
+
+	// A rebalance moves keys of a replicated service between its replicas
+	if h.TransactionConfig() != nil && h.TransactionConfig().Replication() {
+		if rebalance, ok := pb.Element().(*l8svcs.L8Rebalance); ok {
+			return this.trManager.Rebalance(action, rebalance, msg.ServiceName(), msg.ServiceArea(), vnic)
+		}
+	}
+
 	isStartTransaction := h.TransactionConfig() != nil && msg.Action() < ifs.ElectionRequest && this.GetLeader(msg.ServiceName(), msg.ServiceArea()) != ""
 	if isStartTransaction {
 		if msg.Tr_State() == ifs.NotATransaction {
@@ -160,7 +241,13 @@ func (this *ServiceManager) TransactionHandle(pb ifs.IElements, action ifs.Actio
 	if h == nil {
 		this.resources.Logger().Debug("Transaction Handle: No handler for service "+msg.ServiceName(), "-", msg.ServiceArea())
 	}
-	resp := this.handle(h, pb, action, msg, vnic)
+	var resp ifs.IElements
+	restorer, ok := h.(states.IRestorableService)
+	if ok && msg.Tr_State() == ifs.Rollback {
+		resp = restorer.Restore(pb, action, vnic)
+	} else {
+		resp = this.handle(h, pb, action, msg, vnic)
+	}
 	if resp == nil {
 		panic("Transaction Handler " + reflect.ValueOf(h).Elem().Type().Name() + " action " + strconv.Itoa(int(action)) + " resp is nil")
 	}
@@ -171,6 +258,175 @@ func (this *ServiceManager) TransactionHandle(pb ifs.IElements, action ifs.Actio
 	return resp
 }
 
+// Read sends a GET to a transactional service with the given consistency. For a session
+// read, sessionTrId is the id of the caller's last transaction on the service.
+func (this *ServiceManager) Read(serviceName string, serviceArea byte, filter interface{},
+	consistency agreement.ReadConsistency, sessionTrId string, vnic ifs.IVNic) ifs.IElements {
+	return this.trManager.Read(serviceName, serviceArea, filter, consistency, sessionTrId, vnic)
+}
+
+// SetTransactionLog sets the write-ahead log of the transaction manager and replays
+// its pending entries. Transactions that this node coordinated as a leader are
+// resolved once it is elected leader of their service again.
+func (this *ServiceManager) SetTransactionLog(trLog states.ITransactionLog, vnic ifs.IVNic) error {
+	err := this.trManager.SetTransactionLog(trLog, vnic)
+	if err != nil {
+		return err
+	}
+	this.trManager.Recover(vnic)
+	return nil
+}
+
+// ServiceLevelAgreement returns the SLA a service was activated with on this node.
+func (this *ServiceManager) ServiceLevelAgreement(serviceName string, serviceArea byte) (*ifs.ServiceLevelAgreement, bool) {
+	sla, ok := this.slas.Load(cacheKey(serviceName, serviceArea))
+	if !ok {
+		return nil, false
+	}
+	return sla.(*ifs.ServiceLevelAgreement), true
+}
+
+// Agreement returns this node's Agreement of a service, creating a default one on first use.
+// It is kept until the service is deactivated on this node.
+func (this *ServiceManager) Agreement(serviceName string, serviceArea byte) *agreement.Agreement {
+	key := cacheKey(serviceName, serviceArea)
+	existing, ok := this.agreements.Load(key)
+	if ok {
+		return existing.(*agreement.Agreement)
+	}
+	actual, _ := this.agreements.LoadOrStore(key, agreement.NewAgreement())
+	return actual.(*agreement.Agreement)
+}
+
+// RunMultiServiceTransaction commits an ordered batch of operations, spanning one or
+// more transactional services, atomically, coordinated by this node. A batch run again
+// with the same non empty idempotency key gets the original batch's result.
+func (this *ServiceManager) RunMultiServiceTransaction(ops []*states.TransactionOperation, key string, vnic ifs.IVNic) *l8services.L8Transaction {
+	return this.trManager.RunMulti(ops, key, vnic)
+}
+
+// SetTransactionHistoryLimits sets how many recent transactions the leader keeps
+// for status lookups, and for how long.
+func (this *ServiceManager) SetTransactionHistoryLimits(maxSize int, ttl time.Duration) {
+	this.trManager.History().SetLimits(maxSize, ttl)
+}
+
+// LaggingPeers returns the peers that missed transactions of a service this node committed
+// by quorum, in commit order.
+func (this *ServiceManager) LaggingPeers(serviceName string, serviceArea byte) []*states.LaggingPeer {
+	return this.trManager.LaggingPeers().Of(serviceName, serviceArea)
+}
+
+// SetLaggingPeersLimit sets how many records of lagging peers the leader keeps per service.
+func (this *ServiceManager) SetLaggingPeersLimit(limit int) {
+	this.trManager.LaggingPeers().SetLimit(limit)
+}
+
+// RepairLaggingPeers replays the missed transactions of a service on its lagging peers,
+// returning how many of them were caught up.
+func (this *ServiceManager) RepairLaggingPeers(serviceName string, serviceArea byte, vnic ifs.IVNic) int {
+	return this.trManager.Repair(serviceName, serviceArea, vnic)
+}
+
+// SweepPreCommit resolves with the leader the stale pre-commit entries this node holds as
+// a participant of a service, without waiting for the background sweeper.
+func (this *ServiceManager) SweepPreCommit(serviceName string, serviceArea byte, vnic ifs.IVNic) int {
+	return this.trManager.SweepPreCommit(serviceName, serviceArea, vnic)
+}
+
+// TransactionMetrics returns the transaction metrics of a service on this node.
+func (this *ServiceManager) TransactionMetrics(serviceName string, serviceArea byte) *l8svcs.L8ServiceMetrics {
+	return this.trManager.Metrics(serviceName, serviceArea)
+}
+
+// AllTransactionMetrics returns the transaction metrics of every service seen on this node.
+func (this *ServiceManager) AllTransactionMetrics() []*l8svcs.L8ServiceMetrics {
+	return this.trManager.AllMetrics()
+}
+
+// SetTransactionTracer plugs in a tracer getting a span around every transaction phase.
+func (this *ServiceManager) SetTransactionTracer(tracer states.ITransactionTracer) {
+	this.trManager.SetTracer(tracer)
+}
+
+// onLeaderElected is invoked when this node becomes the leader of a service or group,
+// resolving any logged transactions it now coordinates and the transactions a failed
+// leader left in doubt on the participants of a transactional service.
+func (this *ServiceManager) onLeaderElected(serviceName string, serviceArea byte, vnic ifs.IVNic) {
+	vnic.Resources().Logger().Debug("Elected leader for ", serviceName, " area ", serviceArea)
+	this.trManager.Recover(vnic)
+	h, ok := this.services.get(serviceName, serviceArea)
+	if ok && h.TransactionConfig() != nil {
+		this.trManager.ResolveInDoubt(serviceName, serviceArea, vnic)
+	}
+}
+
+// ResignLeadership gracefully gives up this node's leadership of a service. The transactions
+// in flight are completed first, then the other nodes elect a new leader, without this node,
+// and the queued transactions are handed off to it. If no other node took over once this
+// node stopped abstaining, it runs for the leadership again and keeps its queue. Returns
+// the number of handed off transactions, or an error if this node is not the leader.
+func (this *ServiceManager) ResignLeadership(serviceName string, serviceArea byte, vnic ifs.IVNic) (int, error) {
+	gName, gArea := this.resolveGroup(serviceName, serviceArea)
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	if !this.leaderElection.IsLeader(gName, gArea, localUuid) {
+		return 0, errors.New("ResignLeadership: this node is not the leader of " + serviceName +
+			" area " + strconv.Itoa(int(serviceArea)))
+	}
+	return this.relinquish(serviceName, serviceArea, "", vnic), nil
+}
+
+// SetElectionTimings sets the timings of the leader election of a service, or of its
+// group, or returns an error if they are not valid. The running elections, heartbeats
+// and heartbeat monitors of the service pick them up without a restart.
+func (this *ServiceManager) SetElectionTimings(serviceName string, serviceArea byte, timings agreement.ElectionTimings) error {
+	gName, gArea := this.resolveGroup(serviceName, serviceArea)
+	return this.Agreement(gName, gArea).SetElectionTimings(timings)
+}
+
+// ElectionTimings returns the timings of the leader election of a service, or of its group.
+func (this *ServiceManager) ElectionTimings(serviceName string, serviceArea byte) agreement.ElectionTimings {
+	gName, gArea := this.resolveGroup(serviceName, serviceArea)
+	return this.timingsOf(gName, gArea)
+}
+
+// SubscribeElectionEvents adds a listener getting the election events of the services of
+// this node. The events of a service in a group carry the name of the group.
+func (this *ServiceManager) SubscribeElectionEvents(listener IElectionListener) {
+	this.leaderElection.events.Subscribe(listener)
+}
+
+// UnsubscribeElectionEvents removes a listener of the election events.
+func (this *ServiceManager) UnsubscribeElectionEvents(listener IElectionListener) {
+	this.leaderElection.events.Unsubscribe(listener)
+}
+
+// DroppedElectionEvents returns the number of election events a listener lost as it fell
+// behind the events published.
+func (this *ServiceManager) DroppedElectionEvents(listener IElectionListener) int {
+	return this.leaderElection.events.Dropped(listener)
+}
+
+// LeadershipHistory returns the last leadership terms of a service, or of its group, as
+// seen by this node, oldest first.
+func (this *ServiceManager) LeadershipHistory(serviceName string, serviceArea byte) []*LeadershipTerm {
+	gName, gArea := this.resolveGroup(serviceName, serviceArea)
+	return this.leaderElection.events.History(gName, gArea)
+}
+
+// LeadershipBalancer returns the balancer spreading the leadership of the services across
+// their participants. It is disabled until enabled.
+func (this *ServiceManager) LeadershipBalancer() *LeadershipBalancer {
+	return this.balancer
+}
+
+// participantsChanged balances the leaderships and moves the keys of the replicated
+// services once nodes joined or left the services.
+func (this *ServiceManager) participantsChanged() {
+	this.balancer.requestBalance()
+	this.rebalancer.requestRebalance()
+}
+
 // onNodeDelete handles cleanup when a node is removed from the cluster,
 // unregistering the node from all service participant lists.
 func (this *ServiceManager) onNodeDelete(uuid string) {
@@ -238,6 +494,20 @@ func (this *ServiceManager) IsLeader(serviceName string, serviceArea byte, uuid
 	return this.leaderElection.IsLeader(gName, gArea, uuid)
 }
 
+// Epoch returns the highest leadership epoch seen for the service and area, and the
+// leader that claimed it.
+func (this *ServiceManager) Epoch(serviceName string, serviceArea byte) (int64, string) {
+	gName, gArea := this.resolveGroup(serviceName, serviceArea)
+	return this.leaderElection.Epoch(gName, gArea)
+}
+
+// epochs returns the epoch a node claimed for the leadership of the service and area,
+// and the highest epoch seen for it.
+func (this *ServiceManager) epochs(serviceName string, serviceArea byte, node string) (int64, int64) {
+	gName, gArea := this.resolveGroup(serviceName, serviceArea)
+	return this.leaderElection.Epochs(gName, gArea, node)
+}
+
 // RegisterParticipant adds a node to the participant list for a service and area.
 func (this *ServiceManager) RegisterParticipant(serviceName string, serviceArea byte, uuid string) {
 	gName, gArea := this.resolveGroup(serviceName, serviceArea)
@@ -269,8 +539,25 @@ func (this *ServiceManager) ParticipantCount(serviceName string, serviceArea byt
 }
 
 // RoundRobinParticipants selects participants in round-robin fashion for load distribution.
-// Returns a map of selected participant UUIDs to their replica numbers.
+// Returns a map of selected participant UUIDs to their replica numbers, skipping the
+// participants excluded as unhealthy.
 func (this *ServiceManager) RoundRobinParticipants(serviceName string, serviceArea byte, replications int) map[string]byte {
 	gName, gArea := this.resolveGroup(serviceName, serviceArea)
-	return this.participantRegistry.RoundRobinParticipants(gName, gArea, replications)
+	health := this.trManager.ParticipantHealth()
+	return this.participantRegistry.RoundRobinParticipants(gName, gArea, replications, func(node string) bool {
+		return health.Excluded(node, nil)
+	})
+}
+
+// ParticipantHealth returns the health of the participants of the transactions this node
+// coordinated, sorted by node.
+func (this *ServiceManager) ParticipantHealth() []*states.ParticipantScore {
+	return this.trManager.ParticipantHealth().Scores()
+}
+
+// ExcludeParticipant excludes a participant from the transactions this node coordinates
+// and from its replica picks for the given period, after which it is probed on the
+// service and re-admitted if it answers.
+func (this *ServiceManager) ExcludeParticipant(serviceName string, serviceArea byte, node string, period time.Duration) {
+	this.trManager.ParticipantHealth().Exclude(serviceName, serviceArea, node, period)
 }
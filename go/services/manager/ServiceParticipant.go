@@ -16,14 +16,17 @@ package manager
 import (
 	"sync"
 
+	"github.com/saichler/l8services/go/types/l8svcs"
 	"github.com/saichler/l8types/go/ifs"
 )
 
 // participantSet holds the set of participant UUIDs for a service
-// and tracks round-robin usage for load distribution.
+// and tracks round-robin usage for load distribution, or the hash ring
+// of the participants for consistent hash placement.
 type participantSet struct {
 	uuids  map[string]struct{}
 	rrUsed map[string]struct{}
+	ring   *hashRing
 	mtx    sync.RWMutex
 }
 
@@ -34,7 +37,9 @@ type GroupResolver func(serviceName string, serviceArea byte) (string, byte)
 // It tracks which nodes are participating in each service for coordination and routing.
 type ParticipantRegistry struct {
 	participants  sync.Map // key: serviceKey string -> *participantSet
+	weights       sync.Map // key: node UUID string -> int, the leadership weight the node announced
 	groupResolver GroupResolver
+	onChange      func() // Called when a remote node joins or leaves a service, or changes its weight
 }
 
 // NewParticipantRegistry creates a new empty ParticipantRegistry.
@@ -43,10 +48,10 @@ func NewParticipantRegistry(resolver GroupResolver) *ParticipantRegistry {
 }
 
 // handleRegistry routes participant registry messages to appropriate handlers.
-func (pr *ParticipantRegistry) handleRegistry(action ifs.Action, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+func (pr *ParticipantRegistry) handleRegistry(action ifs.Action, pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
 	switch action {
 	case ifs.ServiceRegister:
-		return pr.handleServiceRegister(vnic, msg)
+		return pr.handleServiceRegister(pb, vnic, msg)
 	case ifs.ServiceUnregister:
 		return pr.handleServiceUnregister(vnic, msg)
 	case ifs.ServiceQuery:
@@ -64,8 +69,9 @@ func (pr *ParticipantRegistry) resolveGroupKey(msg *ifs.Message) string {
 	return makeServiceKey(msg.ServiceName(), msg.ServiceArea())
 }
 
-// handleServiceRegister adds the message source as a participant for the service.
-func (pr *ParticipantRegistry) handleServiceRegister(vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+// handleServiceRegister adds the message source as a participant for the service, with
+// the leadership weight it announced.
+func (pr *ParticipantRegistry) handleServiceRegister(pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
 	localUuid := vnic.Resources().SysConfig().LocalUuid
 
 	// Skip self-multicast — already registered locally in triggerElections
@@ -79,10 +85,22 @@ func (pr *ParticipantRegistry) handleServiceRegister(vnic ifs.IVNic, msg *ifs.Me
 	vnic.Resources().Logger().Debug("Registering participant", msg.Source(), "for", msg.ServiceName(), "area", msg.ServiceArea())
 
 	ps.mtx.Lock()
+	_, known := ps.uuids[msg.Source()]
 	ps.uuids[msg.Source()] = struct{}{}
 	ps.mtx.Unlock()
 
+	reweighted := false
+	if pb != nil {
+		weight, ok := pb.Element().(*l8svcs.L8LeaderWeight)
+		if ok && weight != nil {
+			reweighted = pr.setWeight(msg.Source(), int(weight.Weight))
+		}
+	}
+
 	vnic.Resources().Logger().Debug("Registered participant", msg.Source(), "for", msg.ServiceName(), "area", msg.ServiceArea())
+	if !known || reweighted {
+		pr.changed()
+	}
 	return nil
 }
 
@@ -101,6 +119,7 @@ func (pr *ParticipantRegistry) handleServiceUnregister(vnic ifs.IVNic, msg *ifs.
 	ps.mtx.Unlock()
 
 	vnic.Resources().Logger().Debug("Unregistered participant", msg.Source(), "for", msg.ServiceName(), "area", msg.ServiceArea())
+	pr.changed()
 	return nil
 }
 
@@ -126,7 +145,7 @@ func (pr *ParticipantRegistry) handleServiceQuery(vnic ifs.IVNic, msg *ifs.Messa
 		if isParticipant {
 			vnic.Resources().Logger().Debug("Responding to query, I am a participant")
 			// Respond that we are a participant
-			vnic.Unicast(msg.Source(), msg.ServiceName(), msg.ServiceArea(), ifs.ServiceRegister, nil)
+			vnic.Unicast(msg.Source(), msg.ServiceName(), msg.ServiceArea(), ifs.ServiceRegister, pr.announcement(localUuid))
 		}
 	}
 
@@ -158,7 +177,8 @@ func (pr *ParticipantRegistry) UnregisterParticipant(serviceName string, service
 
 // RoundRobinParticipants selects participants using round-robin for even distribution.
 // Resets the usage tracking when all participants have been used in the current cycle.
-func (pr *ParticipantRegistry) RoundRobinParticipants(serviceName string, serviceArea byte, replications int) map[string]byte {
+// Participants the excluded func reports are skipped, unless all of them would be.
+func (pr *ParticipantRegistry) RoundRobinParticipants(serviceName string, serviceArea byte, replications int, excluded func(string) bool) map[string]byte {
 	key := makeServiceKey(serviceName, serviceArea)
 	ps := pr.getParticipantSet(key)
 	if ps == nil {
@@ -168,7 +188,17 @@ func (pr *ParticipantRegistry) RoundRobinParticipants(serviceName string, servic
 	ps.mtx.Lock()
 	defer ps.mtx.Unlock()
 
-	totalParticipants := len(ps.uuids)
+	candidates := make(map[string]struct{}, len(ps.uuids))
+	for uuid := range ps.uuids {
+		if excluded == nil || !excluded(uuid) {
+			candidates[uuid] = struct{}{}
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = ps.uuids
+	}
+
+	totalParticipants := len(candidates)
 	if totalParticipants == 0 {
 		return map[string]byte{}
 	}
@@ -179,7 +209,13 @@ func (pr *ParticipantRegistry) RoundRobinParticipants(serviceName string, servic
 	}
 
 	// If all participants have been used, reset for new cycle
-	if len(ps.rrUsed) >= totalParticipants {
+	used := 0
+	for uuid := range ps.rrUsed {
+		if _, ok := candidates[uuid]; ok {
+			used++
+		}
+	}
+	if used >= totalParticipants {
 		ps.rrUsed = make(map[string]struct{})
 	}
 
@@ -188,7 +224,7 @@ func (pr *ParticipantRegistry) RoundRobinParticipants(serviceName string, servic
 	// If replications >= total participants, return all
 	if replications >= totalParticipants {
 		result := make(map[string]byte, totalParticipants)
-		for uuid := range ps.uuids {
+		for uuid := range candidates {
 			result[uuid] = replica
 			replica++
 		}
@@ -197,7 +233,7 @@ func (pr *ParticipantRegistry) RoundRobinParticipants(serviceName string, servic
 
 	// Select participants that haven't been used yet in this cycle
 	result := make(map[string]byte, replications)
-	for uuid := range ps.uuids {
+	for uuid := range candidates {
 		if len(result) >= replications {
 			break
 		}
@@ -270,6 +306,38 @@ func (pr *ParticipantRegistry) UnregisterParticipantFromAll(uuid string) {
 		ps.mtx.Unlock()
 		return true
 	})
+	pr.changed()
+}
+
+// Weight returns the leadership weight a node announced, 1 if it announced none.
+func (pr *ParticipantRegistry) Weight(uuid string) int {
+	weight, ok := pr.weights.Load(uuid)
+	if !ok {
+		return 1
+	}
+	return weight.(int)
+}
+
+// setWeight records the leadership weight of a node, returns true if it changed.
+func (pr *ParticipantRegistry) setWeight(uuid string, weight int) bool {
+	old, ok := pr.weights.Swap(uuid, weight)
+	if ok {
+		return old.(int) != weight
+	}
+	return weight != 1
+}
+
+// announcement returns the leadership weight of the local node, which it sends with its
+// registrations so all the participants of its services plan with the same weights.
+func (pr *ParticipantRegistry) announcement(localUuid string) *l8svcs.L8LeaderWeight {
+	return &l8svcs.L8LeaderWeight{Weight: int32(pr.Weight(localUuid))}
+}
+
+// changed notifies that the participants of the services changed.
+func (pr *ParticipantRegistry) changed() {
+	if pr.onChange != nil {
+		pr.onChange()
+	}
 }
 
 // getParticipantSet retrieves the participant set for a key, or nil if not found.
@@ -20,6 +20,7 @@ import (
 	"errors"
 
 	"github.com/saichler/l8bus/go/overlay/protocol"
+	"github.com/saichler/l8services/go/services/agreement"
 	"github.com/saichler/l8services/go/services/dcache"
 	"github.com/saichler/l8types/go/ifs"
 	"github.com/saichler/l8types/go/types/l8services"
@@ -45,6 +46,7 @@ func (this *ReplicationService) Activate(sla *ifs.ServiceLevelAgreement, vnic if
 	vnic.Resources().Introspector().Decorators().AddPrimaryKeyDecorator(&l8services.L8ReplicationIndex{}, "ServiceName", "ServiceArea")
 	this.cache = dcache.NewDistributedCache(sla.ServiceName(), sla.ServiceArea(), &l8services.L8ReplicationIndex{},
 		nil, vnic, vnic.Resources())
+	agreement.Of(sla, vnic.Resources()).SetLeadershipBalanced(false)
 	return nil
 }
 
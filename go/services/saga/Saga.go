@@ -0,0 +1,132 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package saga provides saga orchestration for workflows that span services which are
+// not transactional. A saga is a sequence of service calls, each with an optional
+// compensating call. When a step fails, the compensations of the steps that already
+// completed run in reverse order. Every saga is kept, with its steps and their progress,
+// as an L8Saga in a distributed cache, so the leader of the saga service can resume a
+// saga its owner left unfinished, even if it never saw the saga before.
+package saga
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/types/l8services"
+	"google.golang.org/protobuf/proto"
+)
+
+// SagaCall is a single service call, sending the element with the action. The element
+// is a protobuf message of a type registered on the nodes that may resume the saga.
+type SagaCall struct {
+	ServiceName string
+	ServiceArea byte
+	Action      ifs.Action
+	Element     interface{}
+}
+
+// SagaStep is a step of a saga, its call and the call compensating it. A step without a
+// compensation is not undone when a later step fails.
+type SagaStep struct {
+	Call         *SagaCall
+	Compensation *SagaCall
+}
+
+// Saga is a workflow of steps, run in order. Its calls may be retried when the saga is
+// resumed by another node, so they should be idempotent.
+type Saga struct {
+	Id    string
+	Steps []*SagaStep
+}
+
+// NewSaga creates a saga with a new id and the given steps.
+func NewSaga(steps ...*SagaStep) *Saga {
+	return &Saga{Id: ifs.NewUuid(), Steps: steps}
+}
+
+// Record returns the progress record of the saga, with the elements of its calls
+// serialized, as it is kept in the saga service's cache before it runs.
+func (this *Saga) Record() (*l8svcs.L8Saga, error) {
+	now := time.Now().UnixMilli()
+	record := &l8svcs.L8Saga{Id: this.Id, State: int32(ifs.Running), Created: now, Running: now,
+		Steps: make([]*l8svcs.L8SagaStep, 0, len(this.Steps))}
+	for i, step := range this.Steps {
+		if step == nil || step.Call == nil {
+			return nil, errors.New("Step " + strconv.Itoa(i) + " has no call")
+		}
+		c, err := callOf(step.Call)
+		if err != nil {
+			return nil, errors.New("Step " + strconv.Itoa(i) + ": " + err.Error())
+		}
+		recorded := &l8svcs.L8SagaStep{Call: c}
+		if step.Compensation != nil {
+			recorded.Compensation, err = callOf(step.Compensation)
+			if err != nil {
+				return nil, errors.New("Compensation of step " + strconv.Itoa(i) + ": " + err.Error())
+			}
+		}
+		record.Steps = append(record.Steps, recorded)
+	}
+	return record, nil
+}
+
+// callOf serializes a call with its element's type name, to instantiate it again.
+func callOf(c *SagaCall) (*l8svcs.L8SagaCall, error) {
+	pb, ok := c.Element.(proto.Message)
+	if !ok || pb == nil {
+		return nil, errors.New("element of the call of " + c.ServiceName + " is not a protobuf message")
+	}
+	data, err := proto.Marshal(pb)
+	if err != nil {
+		return nil, err
+	}
+	return &l8svcs.L8SagaCall{ServiceName: c.ServiceName, ServiceArea: int32(c.ServiceArea), Action: int32(c.Action),
+		ElementType: reflect.ValueOf(pb).Elem().Type().Name(), ElementData: data}, nil
+}
+
+// call sends a recorded service call and returns its error, including the error of a
+// transaction that failed.
+func call(c *l8svcs.L8SagaCall, vnic ifs.IVNic) error {
+	info, err := vnic.Resources().Registry().Info(c.ElementType)
+	if err != nil {
+		return err
+	}
+	elem, err := info.NewInstance()
+	if err != nil {
+		return err
+	}
+	err = proto.Unmarshal(c.ElementData, elem.(proto.Message))
+	if err != nil {
+		return err
+	}
+	serviceArea := byte(c.ServiceArea)
+	timeout := agreement.For(vnic.Resources(), c.ServiceName, serviceArea).TransactionTimeout()
+	resp := vnic.Request("", c.ServiceName, serviceArea, ifs.Action(c.Action), elem, int(timeout/time.Second))
+	if resp == nil {
+		return errors.New("No response from " + c.ServiceName + " area " + strconv.Itoa(int(c.ServiceArea)))
+	}
+	if resp.Error() != nil {
+		return resp.Error()
+	}
+	tr, ok := resp.Element().(*l8services.L8Transaction)
+	if ok && tr != nil && tr.State == int32(ifs.Failed) {
+		return errors.New(tr.ErrMsg)
+	}
+	return nil
+}
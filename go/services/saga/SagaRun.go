@@ -0,0 +1,221 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package saga
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// Run records a saga and runs its steps in order. If a step fails, the compensations
+// of the steps that completed run in reverse order. Returns the saga's final progress
+// record, Committed if all the steps completed and Failed otherwise, with the error of
+// the failed step and of any compensation that failed.
+func (this *SagaService) Run(saga *Saga) *l8svcs.L8Saga {
+	record, err := saga.Record()
+	if err != nil {
+		now := time.Now().UnixMilli()
+		return &l8svcs.L8Saga{Id: saga.Id, State: int32(ifs.Failed), ErrMsg: err.Error(), Created: now, End: now}
+	}
+	if !this.claim(saga.Id) {
+		return this.recordOf(saga.Id)
+	}
+	defer this.release(saga.Id)
+
+	this.save(record)
+	this.forward(record, 0)
+	return record
+}
+
+// forward runs the saga's steps from the given one, compensating if a step fails.
+func (this *SagaService) forward(record *l8svcs.L8Saga, from int) {
+	for i := from; i < len(record.Steps); i++ {
+		step := record.Steps[i]
+		step.Running = time.Now().UnixMilli()
+		this.touch(record)
+		err := call(step.Call, this.vnic)
+		step.End = time.Now().UnixMilli()
+		if err != nil {
+			step.State = int32(ifs.Failed)
+			step.ErrMsg = err.Error()
+			record.State = int32(ifs.Rollback)
+			record.ErrMsg = "Step " + strconv.Itoa(i) + " failed: " + err.Error()
+			this.save(record)
+			this.vnic.Resources().Logger().Info("SagaService: ", record.Id, " ", record.ErrMsg, ", compensating")
+			this.compensate(record, i-1)
+			return
+		}
+		step.State = int32(ifs.Committed)
+		this.save(record)
+	}
+	record.State = int32(ifs.Committed)
+	record.End = time.Now().UnixMilli()
+	this.save(record)
+}
+
+// compensate runs the compensations of the completed steps, from the given one back to
+// the first. A failed compensation is recorded and the others still run.
+func (this *SagaService) compensate(record *l8svcs.L8Saga, from int) {
+	for i := from; i >= 0; i-- {
+		step := record.Steps[i]
+		if step.State != int32(ifs.Committed) || step.Compensation == nil {
+			continue
+		}
+		this.touch(record)
+		err := call(step.Compensation, this.vnic)
+		if err != nil {
+			step.State = int32(ifs.Failed)
+			step.ErrMsg = "Compensation failed: " + err.Error()
+			record.ErrMsg = record.ErrMsg + "; compensation of step " + strconv.Itoa(i) + " failed: " + err.Error()
+			this.vnic.Resources().Logger().Error("SagaService: ", record.Id, " compensation of step ", i, " failed: ", err.Error())
+		} else {
+			step.State = int32(ifs.Rollback)
+		}
+		step.End = time.Now().UnixMilli()
+		this.save(record)
+	}
+	record.State = int32(ifs.Failed)
+	record.End = time.Now().UnixMilli()
+	this.save(record)
+}
+
+// touch records that the saga is making progress, so it is not considered stalled.
+func (this *SagaService) touch(record *l8svcs.L8Saga) {
+	record.Running = time.Now().UnixMilli()
+	this.save(record)
+}
+
+// claim marks a saga as running on this node, returns false if it already is.
+func (this *SagaService) claim(sagaId string) bool {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if this.active[sagaId] {
+		return false
+	}
+	this.active[sagaId] = true
+	return true
+}
+
+// release marks a saga as no longer running on this node.
+func (this *SagaService) release(sagaId string) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	delete(this.active, sagaId)
+}
+
+// isActive returns true if the saga is running on this node.
+func (this *SagaService) isActive(sagaId string) bool {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return this.active[sagaId]
+}
+
+// Resume continues a saga that was left unfinished, from the first step that was not
+// recorded as completed, or goes on compensating it if it was failing. The saga's steps
+// are taken from its record, so any node with the saga service can resume it. Returns
+// false if the saga is unknown, finished or already running on this node.
+func (this *SagaService) Resume(sagaId string) bool {
+	record := this.recordOf(sagaId)
+	if record == nil {
+		return false
+	}
+	state := ifs.TransactionState(record.State)
+	if state != ifs.Running && state != ifs.Rollback {
+		return false
+	}
+	if !this.claim(sagaId) {
+		return false
+	}
+	defer this.release(sagaId)
+
+	this.vnic.Resources().Logger().Info("SagaService: resuming ", sagaId, " in state ", state.String())
+	if state == ifs.Rollback {
+		this.compensate(record, len(record.Steps)-1)
+		return true
+	}
+	from := 0
+	for from < len(record.Steps) && record.Steps[from].State == int32(ifs.Committed) {
+		from++
+	}
+	this.forward(record, from)
+	return true
+}
+
+// watchStalled is the background goroutine that, on the leader, resumes the sagas that
+// made no progress for the stall timeout and deletes the records of the sagas that
+// finished before the retention, until the service is deactivated. It checks every
+// watchTick, so a changed stall timeout or retention applies right away.
+func (this *SagaService) watchStalled(stop chan struct{}) {
+	ticker := time.NewTicker(watchTick)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		this.mtx.Lock()
+		stallTimeout, retention := this.stallTimeout, this.retention
+		this.mtx.Unlock()
+		period := stallTimeout
+		if retention < period {
+			period = retention
+		}
+		if time.Since(last) < period/2 {
+			continue
+		}
+		last = time.Now()
+
+		leader := this.vnic.Resources().Services().GetLeader(ServiceName, ServiceArea)
+		if leader != this.vnic.Resources().SysConfig().LocalUuid {
+			continue
+		}
+		stalled, expired := this.scan(stallTimeout, retention)
+		for _, sagaId := range expired {
+			this.cache.Delete(&l8svcs.L8Saga{Id: sagaId})
+		}
+		for _, sagaId := range stalled {
+			go this.Resume(sagaId)
+		}
+	}
+}
+
+// scan returns the sagas, not running on this node, that are unfinished and made no
+// progress for the stall timeout, and the finished sagas that ended before the retention.
+func (this *SagaService) scan(stallTimeout, retention time.Duration) ([]string, []string) {
+	stalled := make([]string, 0)
+	expired := make([]string, 0)
+	this.cache.Collect(func(elem interface{}) (bool, interface{}) {
+		record, ok := elem.(*l8svcs.L8Saga)
+		if !ok {
+			return false, nil
+		}
+		state := ifs.TransactionState(record.State)
+		if state != ifs.Running && state != ifs.Rollback {
+			if time.Since(time.UnixMilli(record.End)) > retention {
+				expired = append(expired, record.Id)
+			}
+			return false, nil
+		}
+		if time.Since(time.UnixMilli(record.Running)) > stallTimeout && !this.isActive(record.Id) {
+			stalled = append(stalled, record.Id)
+		}
+		return false, nil
+	})
+	return stalled, expired
+}
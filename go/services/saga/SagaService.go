@@ -0,0 +1,193 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package saga
+
+import (
+	"sync"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/dcache"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+	"google.golang.org/protobuf/proto"
+)
+
+// Service constants for the saga service registration.
+const (
+	ServiceType = "SagaService"
+	ServiceName = "Sagas"
+	ServiceArea = byte(0)
+)
+
+// defaultStallTimeout is the time without progress after which a saga is considered
+// abandoned by its owner. It should be longer than the longest call of a step.
+const defaultStallTimeout = 2 * time.Minute
+
+// defaultRetention is how long the record of a finished saga is kept for its status.
+const defaultRetention = 10 * time.Minute
+
+// watchTick is how often the leader checks if it is time to look for stalled sagas.
+const watchTick = time.Second
+
+// SagaService orchestrates sagas and keeps them in a distributed cache of L8Saga records,
+// keyed by the saga's id, with the steps, their calls and their progress. The service
+// should be activated as stateful on every node that may run or resume sagas, its leader
+// resumes the stalled sagas and deletes the records of the finished ones.
+type SagaService struct {
+	cache        ifs.IDistributedCache
+	vnic         ifs.IVNic
+	active       map[string]bool
+	stallTimeout time.Duration
+	retention    time.Duration
+	stop         chan struct{}
+	mtx          *sync.Mutex
+}
+
+// Activate creates the saga cache and starts watching for stalled sagas.
+func (this *SagaService) Activate(sla *ifs.ServiceLevelAgreement, vnic ifs.IVNic) error {
+	vnic.Resources().Registry().Register(&l8svcs.L8Saga{})
+	vnic.Resources().Introspector().Decorators().AddPrimaryKeyDecorator(&l8svcs.L8Saga{}, "Id")
+	this.cache = dcache.NewDistributedCache(sla.ServiceName(), sla.ServiceArea(), &l8svcs.L8Saga{},
+		nil, vnic, vnic.Resources())
+	this.vnic = vnic
+	this.active = make(map[string]bool)
+	this.stallTimeout = defaultStallTimeout
+	this.retention = defaultRetention
+	this.stop = make(chan struct{})
+	this.mtx = &sync.Mutex{}
+	agreement.Of(sla, vnic.Resources()).SetLeadershipBalanced(false)
+	go this.watchStalled(this.stop)
+	return nil
+}
+
+// DeActivate stops watching for stalled sagas.
+func (this *SagaService) DeActivate() error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if this.stop != nil {
+		close(this.stop)
+		this.stop = nil
+	}
+	return nil
+}
+
+// Post stores saga records, as synchronized from the other nodes.
+func (this *SagaService) Post(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	for _, elem := range pb.Elements() {
+		this.cache.Post(elem, pb.Notification())
+	}
+	return nil
+}
+
+// Put replaces saga records, as synchronized from the other nodes.
+func (this *SagaService) Put(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	for _, elem := range pb.Elements() {
+		this.cache.Put(elem, pb.Notification())
+	}
+	return nil
+}
+
+// Patch updates saga records, as synchronized from the other nodes.
+func (this *SagaService) Patch(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	for _, elem := range pb.Elements() {
+		this.cache.Patch(elem, pb.Notification())
+	}
+	return nil
+}
+
+// Delete removes saga records, as synchronized from the other nodes.
+func (this *SagaService) Delete(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	for _, elem := range pb.Elements() {
+		this.cache.Delete(elem, pb.Notification())
+	}
+	return nil
+}
+
+// Get returns the record of the saga with the id in the L8Saga filter.
+func (this *SagaService) Get(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	filter, ok := pb.Element().(*l8svcs.L8Saga)
+	if !ok {
+		return object.NewError("SagaService: expected an L8Saga filter")
+	}
+	record, err := this.cache.Get(filter)
+	if err != nil {
+		return object.NewError("SagaService: saga " + filter.Id + " was not found")
+	}
+	return object.New(nil, record)
+}
+
+// Failed handles message delivery failures (no-op for the saga service).
+func (this *SagaService) Failed(pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+	return nil
+}
+
+// TransactionConfig returns nil as the saga service doesn't use transactions.
+func (this *SagaService) TransactionConfig() ifs.ITransactionConfig {
+	return nil
+}
+
+// WebService returns nil as the saga service doesn't expose a web interface.
+func (this *SagaService) WebService() ifs.IWebService {
+	return nil
+}
+
+// SetStallTimeout sets the time without progress after which the leader resumes a saga.
+func (this *SagaService) SetStallTimeout(timeout time.Duration) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.stallTimeout = timeout
+}
+
+// SetRetention sets how long the record of a finished saga is kept before it is deleted.
+func (this *SagaService) SetRetention(retention time.Duration) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.retention = retention
+}
+
+// Status returns the record of a saga, with the progress of its steps, or nil if it is
+// unknown or its record was deleted after the retention.
+func (this *SagaService) Status(sagaId string) *l8svcs.L8Saga {
+	return this.recordOf(sagaId)
+}
+
+// recordOf returns a copy of a saga record, or nil if it is not in the cache.
+func (this *SagaService) recordOf(sagaId string) *l8svcs.L8Saga {
+	record, err := this.cache.Get(&l8svcs.L8Saga{Id: sagaId})
+	if err != nil || record == nil {
+		return nil
+	}
+	return proto.Clone(record.(*l8svcs.L8Saga)).(*l8svcs.L8Saga)
+}
+
+// save stores a copy of a saga record, synchronizing it to the other nodes, so the cached
+// one is never changed in place.
+func (this *SagaService) save(record *l8svcs.L8Saga) {
+	_, err := this.cache.Put(proto.Clone(record))
+	if err != nil {
+		this.vnic.Resources().Logger().Error("SagaService: failed to save progress of ", record.Id, " ", err.Error())
+	}
+}
+
+// Service returns the saga service from the resources, or nil if it was not activated.
+func Service(r ifs.IResources) *SagaService {
+	handler, ok := r.Services().ServiceHandler(ServiceName, ServiceArea)
+	if !ok {
+		return nil
+	}
+	sagaService, _ := handler.(*SagaService)
+	return sagaService
+}
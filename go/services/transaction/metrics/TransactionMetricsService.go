@@ -0,0 +1,114 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics provides a service for querying the transaction metrics of a node.
+// The metrics are collected by the node's TransactionManager, per transactional service,
+// and are answered as L8ServiceMetrics, so they can be queried from any node.
+package metrics
+
+import (
+	"errors"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// Service constants for the transaction metrics service registration.
+const (
+	ServiceType = "TransactionMetricsService"
+	ServiceName = "TrMetrics"
+	ServiceArea = byte(0)
+)
+
+// IMetricsProvider is implemented by the service manager holding the TransactionManager.
+type IMetricsProvider interface {
+	TransactionMetrics(serviceName string, serviceArea byte) *l8svcs.L8ServiceMetrics
+	AllTransactionMetrics() []*l8svcs.L8ServiceMetrics
+}
+
+// TransactionMetricsService answers GET requests with the transaction metrics of the node.
+// An L8ServiceMetrics filter with a service name returns that service's metrics, any
+// other filter returns the metrics of all the services, as an L8ServiceMetricsList.
+type TransactionMetricsService struct {
+	provider IMetricsProvider
+}
+
+// Activate binds the service to the node's service manager.
+func (this *TransactionMetricsService) Activate(sla *ifs.ServiceLevelAgreement, vnic ifs.IVNic) error {
+	provider, ok := vnic.Resources().Services().(IMetricsProvider)
+	if !ok {
+		return errors.New("TransactionMetricsService: services do not provide transaction metrics")
+	}
+	this.provider = provider
+	agreement.Of(sla, vnic.Resources()).SetLeadershipBalanced(false)
+	return nil
+}
+
+// DeActivate performs cleanup when the service is shut down.
+func (this *TransactionMetricsService) DeActivate() error {
+	return nil
+}
+
+// Post is not supported, metrics are read only.
+func (this *TransactionMetricsService) Post(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.NewError("TransactionMetricsService: metrics are read only")
+}
+
+// Put is not supported, metrics are read only.
+func (this *TransactionMetricsService) Put(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.NewError("TransactionMetricsService: metrics are read only")
+}
+
+// Patch is not supported, metrics are read only.
+func (this *TransactionMetricsService) Patch(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.NewError("TransactionMetricsService: metrics are read only")
+}
+
+// Delete is not supported, metrics are read only.
+func (this *TransactionMetricsService) Delete(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.NewError("TransactionMetricsService: metrics are read only")
+}
+
+// Get returns the metrics of the service in the filter, or of all the services.
+func (this *TransactionMetricsService) Get(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	if pb != nil {
+		filter, ok := pb.Element().(*l8svcs.L8ServiceMetrics)
+		if ok && filter.ServiceName != "" {
+			return object.New(nil, this.provider.TransactionMetrics(filter.ServiceName, byte(filter.ServiceArea)))
+		}
+	}
+	return object.New(nil, &l8svcs.L8ServiceMetricsList{List: this.provider.AllTransactionMetrics()})
+}
+
+// Failed handles message delivery failures (no-op for the metrics service).
+func (this *TransactionMetricsService) Failed(pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+	return nil
+}
+
+// TransactionConfig returns nil as the metrics service doesn't use transactions.
+func (this *TransactionMetricsService) TransactionConfig() ifs.ITransactionConfig {
+	return nil
+}
+
+// WebService returns nil as the metrics service doesn't expose a web interface.
+func (this *TransactionMetricsService) WebService() ifs.IWebService {
+	return nil
+}
+
+// Service returns the transaction metrics service handler from the resources.
+func Service(r ifs.IResources) ifs.IServiceHandler {
+	metricsService, _ := r.Services().ServiceHandler(ServiceName, ServiceArea)
+	return metricsService
+}
@@ -13,84 +13,91 @@
 
 // Package requests provides concurrent transaction request handling for
 // distributed transactions. It manages parallel requests to multiple peer
-// nodes and collects their responses synchronously.
+// nodes and collects their responses, up to the transaction's deadline.
 package requests
 
 import (
 	"sync"
+	"time"
 
 	"github.com/saichler/l8types/go/ifs"
 	"github.com/saichler/l8types/go/types/l8services"
 )
 
+// TimeoutError is the error recorded for a peer that did not respond before the deadline.
+const TimeoutError = "Timeout waiting for the peer's response"
+
 // Requests manages concurrent requests to peer nodes during a transaction.
-// It tracks pending requests and uses a condition variable to synchronize
-// waiting for all responses to complete.
+// It tracks pending requests and closes the done channel once all of them
+// completed, so waiting for the responses can be bounded by a deadline.
 type Requests struct {
-	cond    *sync.Cond
-	pending map[string]string
-	count   int
-	vnic    ifs.IVNic
+	mtx       *sync.Mutex
+	done      chan struct{}
+	pending   map[string]string
+	latencies map[string]time.Duration
+	count     int
+	vnic      ifs.IVNic
 }
 
 // NewRequest creates a new Requests instance for managing concurrent peer requests.
 func NewRequest(vnic ifs.IVNic) *Requests {
 	rq := &Requests{}
 	rq.pending = make(map[string]string)
-	rq.cond = sync.NewCond(&sync.Mutex{})
+	rq.latencies = make(map[string]time.Duration)
+	rq.mtx = &sync.Mutex{}
+	rq.done = make(chan struct{})
 	rq.vnic = vnic
 	return rq
 }
 
 // addOne registers a new pending request for the target node.
 func (this *Requests) addOne(target string) {
-	this.cond.L.Lock()
-	defer this.cond.L.Unlock()
-	this.pending[target] = ""
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.pending[target] = TimeoutError
 	this.count++
 }
 
 // reportError records an error for a target and decrements the pending count.
-// Broadcasts when all requests have completed.
+// Closes the done channel when all requests have completed.
 func (this *Requests) reportError(target string, err error) {
-	this.cond.L.Lock()
-	defer this.cond.L.Unlock()
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
 	this.pending[target] = err.Error()
-	this.count--
-	if this.count == 0 {
-		this.cond.Broadcast()
-	}
+	this.completeOne()
 }
 
 // reportResult records a transaction result and decrements the pending count.
-// If the transaction failed, stores the error message. Broadcasts when all complete.
+// If the transaction failed, stores the error message. Closes the done channel when all complete.
 func (this *Requests) reportResult(target string, tr *l8services.L8Transaction) {
-	this.cond.L.Lock()
-	defer this.cond.L.Unlock()
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
 	if tr == nil {
 		this.pending[target] = "Nil/Timeout Transaction"
 	} else if tr.State == int32(ifs.Failed) {
 		this.pending[target] = tr.ErrMsg
+	} else {
+		this.pending[target] = ""
 	}
+	this.completeOne()
+}
+
+// completeOne decrements the pending count, the caller holds the mutex.
+func (this *Requests) completeOne() {
 	this.count--
 	if this.count == 0 {
-		this.cond.Broadcast()
+		close(this.done)
 	}
 }
 
-// requestFromPeer sends a transaction request to a single peer node.
-// For replication, it clones the message and sets replica information.
-func (this *Requests) requestFromPeer(msg *ifs.Message, target string, isReplicate bool, replicateNum byte) {
-	this.addOne(target)
-
-	if isReplicate {
-		clone := msg.Clone()
-		clone.SetTr_Replica(replicateNum)
-		clone.SetTr_IsReplica(true)
-		msg = clone
-	}
-
+// requestFromPeer sends a transaction request to a single peer node. The message is the
+// peer's own clone, so a late response cannot race with the caller changing the original.
+func (this *Requests) requestFromPeer(msg *ifs.Message, target string) {
+	start := time.Now()
 	resp := this.vnic.Forward(msg, target)
+	this.mtx.Lock()
+	this.latencies[target] = time.Since(start)
+	this.mtx.Unlock()
 	if resp != nil && resp.Error() != nil {
 		this.vnic.Resources().Logger().Error(resp.Error())
 		this.reportError(target, resp.Error())
@@ -101,35 +108,72 @@ func (this *Requests) requestFromPeer(msg *ifs.Message, target string, isReplica
 	this.reportResult(target, tr)
 }
 
+// results returns a copy of the peers results, peers that did not respond yet have a
+// TimeoutError, and the response latency of the peers that did.
+func (this *Requests) results() (map[string]string, map[string]time.Duration) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	result := make(map[string]string, len(this.pending))
+	for target, errMsg := range this.pending {
+		result[target] = errMsg
+	}
+	latencies := make(map[string]time.Duration, len(this.latencies))
+	for target, latency := range this.latencies {
+		latencies[target] = latency
+	}
+	return result, latencies
+}
+
 // RequestFromPeers sends transaction requests to multiple peers concurrently and waits
-// for all responses. Returns success status and a map of peer UUIDs to error messages.
-func RequestFromPeers(msg *ifs.Message, targets map[string]byte, vnic ifs.IVNic, isReplicate bool) (bool, map[string]string) {
+// for all responses, or until the deadline if it is not zero. Returns success status and
+// a map of peer UUIDs to error messages, where a peer that did not respond in time has
+// a TimeoutError.
+func RequestFromPeers(msg *ifs.Message, targets map[string]byte, vnic ifs.IVNic, isReplicate bool, deadline time.Time) (bool, map[string]string) {
+	ok, pending, _ := RequestFromPeersTimed(msg, targets, vnic, isReplicate, deadline)
+	return ok, pending
+}
 
-	this := NewRequest(vnic)
+// RequestFromPeersTimed is RequestFromPeers that also returns the response latency of
+// every peer that responded before the deadline.
+func RequestFromPeersTimed(msg *ifs.Message, targets map[string]byte, vnic ifs.IVNic, isReplicate bool, deadline time.Time) (bool, map[string]string, map[string]time.Duration) {
 
-	this.cond.L.Lock()
-	defer this.cond.L.Unlock()
+	this := NewRequest(vnic)
+	if len(targets) == 0 {
+		return true, this.pending, this.latencies
+	}
 
+	//Register all the targets before sending, so a fast response cannot complete the wait early
+	for target := range targets {
+		this.addOne(target)
+	}
+	//Every peer gets its own clone, for replication with the replica information
 	for target, replica := range targets {
-		go this.requestFromPeer(msg, target, isReplicate, replica)
+		clone := msg.Clone()
+		if isReplicate {
+			clone.SetTr_Replica(replica)
+			clone.SetTr_IsReplica(true)
+		}
+		go this.requestFromPeer(clone, target)
 	}
 
-	if len(targets) > 0 {
-		this.cond.Wait()
+	if deadline.IsZero() {
+		<-this.done
+	} else {
+		timer := time.NewTimer(time.Until(deadline))
+		select {
+		case <-this.done:
+		case <-timer.C:
+			vnic.Resources().Logger().Warning("Transaction ", msg.Tr_Id(), " deadline expired waiting for peers")
+		}
+		timer.Stop()
 	}
 
-	ok := true
-	for _, e := range this.pending {
+	pending, latencies := this.results()
+	for _, e := range pending {
 		if e != "" {
-			ok = false
-			break
+			msg.SetTr_State(ifs.Failed)
+			return false, pending, latencies
 		}
 	}
-
-	if !ok {
-		msg.SetTr_State(ifs.Failed)
-		return false, this.pending
-	}
-
-	return true, this.pending
+	return true, pending, latencies
 }
@@ -0,0 +1,471 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/types/l8services"
+	"google.golang.org/protobuf/proto"
+)
+
+// recentWritesLimit is the number of transactions a leader remembers committing, per
+// service, so it can tell its divergent writes if it turns out it was deposed.
+const recentWritesLimit = 1000
+
+// resyncTimeout is the time in seconds to wait for the leader's state of the divergent
+// keys, and for a participant to write it.
+const resyncTimeout = 15
+
+// DivergentWrite is a transaction a leader committed in a term that, on the other side of
+// a partition, a leader with a newer epoch superseded, and whose keys the new leader does
+// not hold as it left them. The keys were resynced from the new leader, the write is
+// quarantined for the operators until it is reconciled or discarded.
+type DivergentWrite struct {
+	TrId        string
+	ServiceName string
+	ServiceArea byte
+	Action      ifs.Action
+	Epoch       int64 // The epoch of the leadership it was committed in
+	Keys        []string
+	Committed   time.Time
+	ErrMsg      string
+	msg         *ifs.Message
+	targets     map[string]byte // The participants that applied it
+}
+
+// DivergentWrites remembers, per service, the transactions this node recently committed
+// as the leader, with the epoch of its leadership, and quarantines the ones of a deposed
+// term that diverged from the new leader.
+type DivergentWrites struct {
+	recent      map[string][]*DivergentWrite
+	quarantined map[string][]*DivergentWrite
+	mtx         *sync.Mutex
+}
+
+// newDivergentWrites creates an empty divergent writes record.
+func newDivergentWrites() *DivergentWrites {
+	dw := &DivergentWrites{}
+	dw.recent = make(map[string][]*DivergentWrite)
+	dw.quarantined = make(map[string][]*DivergentWrite)
+	dw.mtx = &sync.Mutex{}
+	return dw
+}
+
+// committed remembers a transaction this node committed as the leader in the given epoch,
+// the keys it set and the targets that applied it.
+func (this *DivergentWrites) committed(msg *ifs.Message, epoch int64, keys []string, targets map[string]byte) {
+	clone := msg.Clone()
+	clone.SetTr_State(ifs.Created)
+	serviceKey := ServiceKey(msg.ServiceName(), msg.ServiceArea())
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	recent := append(this.recent[serviceKey], &DivergentWrite{
+		TrId:        msg.Tr_Id(),
+		ServiceName: msg.ServiceName(),
+		ServiceArea: msg.ServiceArea(),
+		Action:      msg.Action(),
+		Epoch:       epoch,
+		Keys:        keys,
+		Committed:   time.Now(),
+		msg:         clone,
+		targets:     targets,
+	})
+	if len(recent) > recentWritesLimit {
+		recent = recent[len(recent)-recentWritesLimit:]
+	}
+	this.recent[serviceKey] = recent
+}
+
+// term returns the transactions of a service committed in the given epoch or later, the
+// term this node was deposed from, and forgets all of them, as this node no longer leads
+// the service.
+func (this *DivergentWrites) term(serviceName string, serviceArea byte, epoch int64) []*DivergentWrite {
+	serviceKey := ServiceKey(serviceName, serviceArea)
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	result := make([]*DivergentWrite, 0)
+	for _, write := range this.recent[serviceKey] {
+		if write.Epoch >= epoch {
+			result = append(result, write)
+		}
+	}
+	delete(this.recent, serviceKey)
+	return result
+}
+
+// hold quarantines the writes.
+func (this *DivergentWrites) hold(writes []*DivergentWrite) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	for _, write := range writes {
+		serviceKey := ServiceKey(write.ServiceName, write.ServiceArea)
+		this.quarantined[serviceKey] = append(this.quarantined[serviceKey], write)
+	}
+}
+
+// Of returns the quarantined writes of a service, in commit order.
+func (this *DivergentWrites) Of(serviceName string, serviceArea byte) []*DivergentWrite {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	quarantined := this.quarantined[ServiceKey(serviceName, serviceArea)]
+	result := make([]*DivergentWrite, len(quarantined))
+	copy(result, quarantined)
+	return result
+}
+
+// Remove drops a quarantined write, once it was reconciled out of band or discarded.
+func (this *DivergentWrites) Remove(write *DivergentWrite) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	serviceKey := ServiceKey(write.ServiceName, write.ServiceArea)
+	list := this.quarantined[serviceKey]
+	for i, w := range list {
+		if w == write {
+			this.quarantined[serviceKey] = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(this.quarantined[serviceKey]) == 0 {
+		delete(this.quarantined, serviceKey)
+	}
+}
+
+// rejected records why the leader rejected a quarantined write.
+func (this *DivergentWrites) rejected(write *DivergentWrite, errMsg string) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	write.ErrMsg = errMsg
+}
+
+// DivergentWrites returns the record of the divergent writes of this node.
+func (this *TransactionManager) DivergentWrites() *DivergentWrites {
+	return this.divergent
+}
+
+// Quarantine resyncs the keys this node set, as the leader of a service, in the term of
+// the given epoch, from the new leader that deposed it. The keys whose state differs from
+// the new leader's are reverted to it on this node and on the participants that applied
+// the writes, and the writes that set them are quarantined. Returns the number of
+// quarantined transactions.
+func (this *TransactionManager) Quarantine(serviceName string, serviceArea byte, epoch int64, leader string, vnic ifs.IVNic) int {
+	writes := this.divergent.term(serviceName, serviceArea, epoch)
+	if len(writes) == 0 {
+		return 0
+	}
+	diverged := this.resync(serviceName, serviceArea, writes, leader, vnic)
+	this.divergent.hold(diverged)
+	if len(diverged) > 0 {
+		vnic.Resources().Logger().Error("DivergentWrites: quarantined ", len(diverged), " transactions of ", serviceName,
+			" area ", serviceArea, " committed by this node in its deposed term of epoch ", epoch)
+	}
+	return len(diverged)
+}
+
+// resync asks the leader for its state of the keys the writes set, writes the state of
+// the keys that differ on this node and on the targets of the writes, and returns the
+// writes that set them. If the leader's state is not available, all the writes are
+// returned and no key is resynced.
+func (this *TransactionManager) resync(serviceName string, serviceArea byte, writes []*DivergentWrite, leader string,
+	vnic ifs.IVNic) []*DivergentWrite {
+	service, ok := vnic.Resources().Services().ServiceHandler(serviceName, serviceArea)
+	//The keys of a replicated service are spread across the participants, not all on the leader
+	if !ok || service.TransactionConfig() == nil || service.TransactionConfig().Replication() {
+		return writes
+	}
+	query, err := this.resyncQueryOf(writes, service, vnic)
+	if err != nil {
+		vnic.Resources().Logger().Error("DivergentWrites: ", err.Error())
+		return writes
+	}
+	resp := vnic.Request(leader, serviceName, serviceArea, ifs.GET, query, resyncTimeout)
+	if resp == nil || resp.Error() != nil {
+		vnic.Resources().Logger().Error("DivergentWrites: failed to get the state of ", serviceName, " area ",
+			serviceArea, " from leader ", leader)
+		return writes
+	}
+	state, ok := resp.Element().(*l8svcs.L8Resync)
+	if !ok || state == nil {
+		return writes
+	}
+
+	changes := &l8svcs.L8Resync{}
+	diverged := make(map[string]bool)
+	for _, elem := range state.Elements {
+		local, err := localElement(service, elem, vnic)
+		if err != nil {
+			vnic.Resources().Logger().Error("DivergentWrites: ", err.Error())
+			continue
+		}
+		current, err := leaderElement(elem, vnic.Resources())
+		if err != nil {
+			vnic.Resources().Logger().Error("DivergentWrites: ", err.Error())
+			continue
+		}
+		if sameElement(local, current) {
+			continue
+		}
+		diverged[elem.Key] = true
+		changes.Elements = append(changes.Elements, elem)
+	}
+	if len(diverged) == 0 {
+		return []*DivergentWrite{}
+	}
+
+	result := make([]*DivergentWrite, 0)
+	targets := make(map[string]bool)
+	for _, write := range writes {
+		for _, key := range write.Keys {
+			if diverged[key] {
+				result = append(result, write)
+				for target := range write.targets {
+					targets[target] = true
+				}
+				break
+			}
+		}
+	}
+
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	applyResync(changes, serviceName, serviceArea, service, vnic)
+	for target := range targets {
+		if target == localUuid || target == leader {
+			continue
+		}
+		resp = vnic.Request(target, serviceName, serviceArea, ifs.PUT, changes, resyncTimeout)
+		if resp == nil || resp.Error() != nil {
+			vnic.Resources().Logger().Error("DivergentWrites: failed to resync ", serviceName, " area ", serviceArea,
+				" on ", target)
+		}
+	}
+	return result
+}
+
+// resyncQueryOf returns the query for the leader's state of the keys the writes set.
+func (this *TransactionManager) resyncQueryOf(writes []*DivergentWrite, service ifs.IServiceHandler, vnic ifs.IVNic) (*l8svcs.L8Resync, error) {
+	query := &l8svcs.L8Resync{}
+	seen := make(map[string]bool)
+	st := this.transactionsOf(writes[0].msg, vnic)
+	for _, write := range writes {
+		pb, err := st.preparedElementsOf(write.msg)
+		if err != nil {
+			return nil, err
+		}
+		for _, elem := range pb.Elements() {
+			if elem == nil {
+				continue
+			}
+			key := service.TransactionConfig().KeyOf(object.New(nil, elem), vnic.Resources())
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			syncElem, err := syncElementOf(key, elem)
+			if err != nil {
+				return nil, err
+			}
+			query.Elements = append(query.Elements, syncElem)
+		}
+	}
+	return query, nil
+}
+
+// Snapshot answers a resync query with this leader's state of the queried elements.
+func (this *TransactionManager) Snapshot(query *l8svcs.L8Resync, msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
+	if vnic.Resources().Services().GetLeader(msg.ServiceName(), msg.ServiceArea()) != vnic.Resources().SysConfig().LocalUuid {
+		return object.NewError("Resync: this node is not the leader of " + msg.ServiceName())
+	}
+	service, ok := vnic.Resources().Services().ServiceHandler(msg.ServiceName(), msg.ServiceArea())
+	if !ok {
+		return object.NewError("Resync: no handler for " + msg.ServiceName())
+	}
+	//Hold the transactions, so the state is the one of the last committed transaction
+	st := this.transactionsOf(msg, vnic)
+	st.runMtx.Lock()
+	defer st.runMtx.Unlock()
+	result := &l8svcs.L8Resync{}
+	for _, elem := range query.Elements {
+		current, err := localElement(service, elem, vnic)
+		if err != nil {
+			return object.NewError("Resync: " + err.Error())
+		}
+		if current == nil {
+			result.Elements = append(result.Elements, &l8svcs.L8SyncElement{Key: elem.Key, Absent: true,
+				ElementType: elem.ElementType, ElementData: elem.ElementData})
+			continue
+		}
+		syncElem, err := syncElementOf(elem.Key, current)
+		if err != nil {
+			return object.NewError("Resync: " + err.Error())
+		}
+		result.Elements = append(result.Elements, syncElem)
+	}
+	return object.New(nil, result)
+}
+
+// Resync writes the leader's state of the elements a deposed leader diverged on, as is.
+func (this *TransactionManager) Resync(state *l8svcs.L8Resync, msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
+	service, ok := vnic.Resources().Services().ServiceHandler(msg.ServiceName(), msg.ServiceArea())
+	if !ok {
+		return object.NewError("Resync: no handler for " + msg.ServiceName())
+	}
+	err := applyResync(state, msg.ServiceName(), msg.ServiceArea(), service, vnic)
+	if err != nil {
+		return object.NewError("Resync: " + err.Error())
+	}
+	return object.New(nil, state)
+}
+
+// applyResync writes the leader's state of the elements on this node, deleting the ones
+// absent on the leader. The writes bypass the checks of the regular writes, like the
+// writes of a rollback.
+func applyResync(state *l8svcs.L8Resync, serviceName string, serviceArea byte, service ifs.IServiceHandler, vnic ifs.IVNic) error {
+	restore := &ifs.Message{}
+	restore.SetServiceName(serviceName)
+	restore.SetServiceArea(serviceArea)
+	restore.SetTr_State(ifs.Rollback)
+	var result error
+	for _, elem := range state.Elements {
+		var resp ifs.IElements
+		if elem.Absent {
+			local, err := localElement(service, elem, vnic)
+			if err != nil || local == nil {
+				result = err
+				continue
+			}
+			resp = vnic.Resources().Services().TransactionHandle(object.New(nil, local), ifs.DELETE, restore, vnic)
+		} else {
+			current, err := leaderElement(elem, vnic.Resources())
+			if err != nil {
+				result = err
+				continue
+			}
+			resp = vnic.Resources().Services().TransactionHandle(object.New(nil, current), ifs.PUT, restore, vnic)
+		}
+		if resp != nil && resp.Error() != nil {
+			result = errors.New("failed to resync " + elem.Key + ": " + resp.Error().Error())
+		}
+	}
+	return result
+}
+
+// syncElementOf returns the element of the key, serialized as its registered type.
+func syncElementOf(key string, elem interface{}) (*l8svcs.L8SyncElement, error) {
+	pb, ok := elem.(proto.Message)
+	if !ok || pb == nil {
+		return nil, errors.New("element of " + key + " is not a protobuf message")
+	}
+	data, err := proto.Marshal(pb)
+	if err != nil {
+		return nil, err
+	}
+	return &l8svcs.L8SyncElement{Key: key, ElementType: reflect.ValueOf(pb).Elem().Type().Name(), ElementData: data}, nil
+}
+
+// decodeSyncElement decodes the element carried by an L8SyncElement as its registered type.
+func decodeSyncElement(elem *l8svcs.L8SyncElement, r ifs.IResources) (interface{}, error) {
+	info, err := r.Registry().Info(elem.ElementType)
+	if err != nil {
+		return nil, err
+	}
+	ins, err := info.NewInstance()
+	if err != nil {
+		return nil, err
+	}
+	pb, ok := ins.(proto.Message)
+	if !ok {
+		return nil, errors.New("element type " + elem.ElementType + " is not a protobuf message")
+	}
+	err = proto.Unmarshal(elem.ElementData, pb)
+	if err != nil {
+		return nil, err
+	}
+	return ins, nil
+}
+
+// leaderElement returns the leader's element of an L8SyncElement, nil if it is absent.
+func leaderElement(elem *l8svcs.L8SyncElement, r ifs.IResources) (interface{}, error) {
+	if elem.Absent {
+		return nil, nil
+	}
+	return decodeSyncElement(elem, r)
+}
+
+// localElement returns this node's element with the key of an L8SyncElement, nil if there
+// is none.
+func localElement(service ifs.IServiceHandler, elem *l8svcs.L8SyncElement, vnic ifs.IVNic) (interface{}, error) {
+	filter, err := decodeSyncElement(elem, vnic.Resources())
+	if err != nil {
+		return nil, err
+	}
+	resp := service.Get(object.New(nil, filter), vnic)
+	if resp == nil || resp.Element() == nil {
+		return nil, nil
+	}
+	return resp.Element(), nil
+}
+
+// sameElement returns true if both elements are absent or equal.
+func sameElement(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	pa, ok := a.(proto.Message)
+	if !ok {
+		return false
+	}
+	pb, ok := b.(proto.Message)
+	return ok && proto.Equal(pa, pb)
+}
+
+// Reconcile replays the quarantined writes of a service on its leader, in commit order,
+// as new transactions. The writes the leader committed are removed from quarantine, the
+// ones it rejected, e.g. on a version conflict, are kept with the leader's error.
+// Returns the number of reconciled writes.
+func (this *TransactionManager) Reconcile(serviceName string, serviceArea byte, vnic ifs.IVNic) int {
+	leader := vnic.Resources().Services().GetLeader(serviceName, serviceArea)
+	if leader == "" || leader == vnic.Resources().SysConfig().LocalUuid {
+		return 0
+	}
+	reconciled := 0
+	for _, write := range this.divergent.Of(serviceName, serviceArea) {
+		vnic.Resources().Logger().Info("DivergentWrites: replaying ", write.TrId, " on leader ", leader)
+		resp := vnic.Forward(write.msg.Clone(), leader)
+		if resp != nil && resp.Error() != nil {
+			this.divergent.rejected(write, resp.Error().Error())
+			continue
+		}
+		var tr *l8services.L8Transaction
+		if resp != nil {
+			tr, _ = resp.Element().(*l8services.L8Transaction)
+		}
+		if tr == nil || tr.State != int32(ifs.Committed) {
+			errMsg := "Leader " + leader + " did not commit the replayed transaction"
+			if tr != nil && tr.ErrMsg != "" {
+				errMsg = tr.ErrMsg
+			}
+			this.divergent.rejected(write, errMsg)
+			continue
+		}
+		this.divergent.Remove(write)
+		reconciled++
+	}
+	return reconciled
+}
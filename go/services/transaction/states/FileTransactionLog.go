@@ -0,0 +1,240 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultCompactSize is the size a transaction log file may grow to before it is compacted.
+const DefaultCompactSize = 16 * 1024 * 1024
+
+// FileTransactionLog is an ITransactionLog backed by an append-only file of
+// JSON lines. Every append is synced to disk before it returns, and the file
+// is compacted to the pending transactions when the log is opened and whenever
+// it grows past its compaction size.
+type FileTransactionLog struct {
+	path        string
+	file        *os.File
+	mtx         *sync.Mutex
+	size        int64
+	compacted   int64
+	compactSize int64
+}
+
+// NewFileTransactionLog opens, or creates, the transaction log file at path.
+func NewFileTransactionLog(path string) (*FileTransactionLog, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+	trLog := &FileTransactionLog{}
+	trLog.path = path
+	trLog.mtx = &sync.Mutex{}
+	trLog.compactSize = DefaultCompactSize
+	err = trLog.compact()
+	if err != nil {
+		return nil, err
+	}
+	err = trLog.open()
+	if err != nil {
+		return nil, err
+	}
+	return trLog, nil
+}
+
+// SetCompactSize sets the size the log file may grow to before it is compacted. A log
+// holding many pending transactions may grow to twice its size after the last compaction,
+// so it is not rewritten on every append.
+func (this *FileTransactionLog) SetCompactSize(size int64) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.compactSize = size
+}
+
+// open opens the log file for appending and takes its size as the compacted size.
+// The caller holds the mutex, or is the constructor.
+func (this *FileTransactionLog) open() error {
+	file, err := os.OpenFile(this.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	this.file = file
+	this.size = info.Size()
+	this.compacted = this.size
+	return nil
+}
+
+// Append writes the entry as a single line and syncs the file.
+func (this *FileTransactionLog) Append(entry *TransactionLogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if this.file == nil {
+		return errors.New("Transaction log " + this.path + " is closed")
+	}
+	n, err := this.file.Write(append(data, '\n'))
+	this.size += int64(n)
+	if err != nil {
+		return err
+	}
+	err = this.file.Sync()
+	if err != nil {
+		return err
+	}
+	if this.size < this.compactSize || this.size < 2*this.compacted {
+		return nil
+	}
+	err = this.rotate()
+	if err != nil {
+		return errors.New("Transaction log " + this.path + " was appended but failed to compact: " + err.Error())
+	}
+	return nil
+}
+
+// rotate compacts the log file while it is open and reopens it for appending.
+// The caller holds the mutex.
+func (this *FileTransactionLog) rotate() error {
+	err := this.file.Close()
+	this.file = nil
+	if err == nil {
+		err = this.compact()
+	}
+	e := this.open()
+	if err != nil {
+		return err
+	}
+	return e
+}
+
+// Pending reads the log and returns the transactions that did not reach a terminal state.
+func (this *FileTransactionLog) Pending() ([]*TransactionLogEntry, error) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return this.pending()
+}
+
+// Close closes the underlying file.
+func (this *FileTransactionLog) Close() error {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if this.file == nil {
+		return nil
+	}
+	err := this.file.Close()
+	this.file = nil
+	return err
+}
+
+// pending folds the log entries per transaction, in log order, and drops the terminal ones.
+func (this *FileTransactionLog) pending() ([]*TransactionLogEntry, error) {
+	file, err := os.Open(this.path)
+	if os.IsNotExist(err) {
+		return []*TransactionLogEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	order := make([]string, 0)
+	folded := make(map[string]*TransactionLogEntry)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		entry := &TransactionLogEntry{}
+		//A torn last line is expected after a crash, skip it
+		if json.Unmarshal(scanner.Bytes(), entry) != nil {
+			continue
+		}
+		existing, ok := folded[entry.key()]
+		if !ok {
+			folded[entry.key()] = entry
+			order = append(order, entry.key())
+			continue
+		}
+		existing.merge(entry)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]*TransactionLogEntry, 0)
+	for _, key := range order {
+		entry := folded[key]
+		if !entry.isTerminal() {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// compact rewrites the log file so it contains only the pending transactions.
+func (this *FileTransactionLog) compact() error {
+	entries, err := this.pending()
+	if err != nil {
+		return err
+	}
+	tmp := this.path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for _, entry := range entries {
+		data, e := json.Marshal(entry)
+		if e != nil {
+			file.Close()
+			return e
+		}
+		writer.Write(data)
+		writer.WriteByte('\n')
+	}
+	err = writer.Flush()
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, this.path)
+	if err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(this.path))
+}
+
+// syncDir flushes a directory, so a file renamed into it survives a power failure.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,240 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"container/list"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/saichler/l8bus/go/overlay/protocol"
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/types/l8services"
+	"google.golang.org/protobuf/proto"
+)
+
+// idempotencyEntry is the latest status of the transaction that claimed an idempotency key.
+// Its done channel is closed once the transaction finished.
+type idempotencyEntry struct {
+	key     string
+	tr      *l8services.L8Transaction
+	expires time.Time
+	elem    *list.Element
+	done    chan struct{}
+}
+
+// IdempotencyCache maps the idempotency keys of recent transactions, per service, to the
+// latest status of the transaction that first used them. It is kept by the leader.
+type IdempotencyCache struct {
+	byKey  map[string]*idempotencyEntry
+	byTrId map[string]*idempotencyEntry
+	order  *list.List
+	mtx    *sync.Mutex
+}
+
+// newIdempotencyCache creates an empty idempotency cache.
+func newIdempotencyCache() *IdempotencyCache {
+	cache := &IdempotencyCache{}
+	cache.byKey = make(map[string]*idempotencyEntry)
+	cache.byTrId = make(map[string]*idempotencyEntry)
+	cache.order = list.New()
+	cache.mtx = &sync.Mutex{}
+	return cache
+}
+
+// claim registers the key for a transaction. If the key was already claimed
+// within the window, it returns the entry of the original transaction and true. The
+// services have their own windows, so a key whose window passed may still be held behind
+// an older key with a longer window, it is dropped here.
+func (this *IdempotencyCache) claim(key string, tr *l8services.L8Transaction, window time.Duration) (*idempotencyEntry, bool) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.expire()
+	now := time.Now()
+	entry, ok := this.byKey[key]
+	if ok {
+		if !now.After(entry.expires) {
+			return entry, true
+		}
+		this.remove(entry)
+	}
+	entry = &idempotencyEntry{key: key, tr: tr, expires: now.Add(window), done: make(chan struct{})}
+	entry.elem = this.order.PushBack(entry)
+	this.byKey[key] = entry
+	this.byTrId[tr.Id] = entry
+	return nil, false
+}
+
+// outcome waits, up to the timeout, for the transaction of an entry to finish and returns
+// its latest status.
+func (this *IdempotencyCache) outcome(entry *idempotencyEntry, timeout time.Duration) *l8services.L8Transaction {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-entry.done:
+	case <-timer.C:
+	}
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return entry.tr
+}
+
+// update keeps the latest status of a transaction that claimed a key, and wakes up the
+// retries waiting for it once it committed or failed.
+func (this *IdempotencyCache) update(tr *l8services.L8Transaction) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	entry, ok := this.byTrId[tr.Id]
+	if ok {
+		entry.tr = tr
+		if tr.State == int32(ifs.Committed) || tr.State == int32(ifs.Failed) {
+			entry.finish()
+		}
+	}
+}
+
+// release drops the key claimed by a transaction that never got queued, so a later retry
+// runs it. The retries already waiting for it get its failure.
+func (this *IdempotencyCache) release(tr *l8services.L8Transaction) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	entry, ok := this.byTrId[tr.Id]
+	if !ok {
+		return
+	}
+	entry.tr = tr
+	entry.finish()
+	this.remove(entry)
+}
+
+// finish closes the done channel of an entry, once, the caller holds the mutex.
+func (this *idempotencyEntry) finish() {
+	select {
+	case <-this.done:
+	default:
+		close(this.done)
+	}
+}
+
+// expire removes the oldest keys whose window has passed, the caller holds the mutex.
+func (this *IdempotencyCache) expire() {
+	now := time.Now()
+	for front := this.order.Front(); front != nil; front = this.order.Front() {
+		entry := front.Value.(*idempotencyEntry)
+		if !now.After(entry.expires) {
+			return
+		}
+		this.remove(entry)
+	}
+}
+
+// remove drops an entry and its key, the caller holds the mutex.
+func (this *IdempotencyCache) remove(entry *idempotencyEntry) {
+	this.order.Remove(entry.elem)
+	delete(this.byKey, entry.key)
+	delete(this.byTrId, entry.tr.Id)
+}
+
+// NewIdempotentRequest wraps the elements of a write with the idempotency key the client
+// chose for it, so a retry sent with the same key is not applied again. The elements must
+// be protobuf messages of the same type.
+func NewIdempotentRequest(key string, elements ...interface{}) (*l8svcs.L8IdempotentRequest, error) {
+	if key == "" {
+		return nil, errors.New("idempotency key is empty")
+	}
+	request := &l8svcs.L8IdempotentRequest{Key: key, ElementData: make([][]byte, 0, len(elements))}
+	for _, element := range elements {
+		pb, ok := element.(proto.Message)
+		if !ok || pb == nil {
+			return nil, errors.New("element of the request is not a protobuf message")
+		}
+		data, err := proto.Marshal(pb)
+		if err != nil {
+			return nil, err
+		}
+		request.ElementType = reflect.ValueOf(pb).Elem().Type().Name()
+		request.ElementData = append(request.ElementData, data)
+	}
+	return request, nil
+}
+
+// idempotencyKeyOf returns the idempotency key a write was sent with, or an empty key if it
+// was not sent as an L8IdempotentRequest. The message's data is replaced by the wrapped
+// elements, so the participants apply them as a plain write.
+func idempotencyKeyOf(msg *ifs.Message, r ifs.IResources) (string, error) {
+	pb, err := protocol.ElementsOf(msg, r)
+	if err != nil {
+		return "", err
+	}
+	request, ok := pb.Element().(*l8svcs.L8IdempotentRequest)
+	if !ok {
+		return "", nil
+	}
+	info, err := r.Registry().Info(request.ElementType)
+	if err != nil {
+		return "", err
+	}
+	elems := make([]interface{}, 0, len(request.ElementData))
+	for _, data := range request.ElementData {
+		ins, err := info.NewInstance()
+		if err != nil {
+			return "", err
+		}
+		insPb, ok := ins.(proto.Message)
+		if !ok {
+			return "", errors.New("element type " + request.ElementType + " is not a protobuf message")
+		}
+		err = proto.Unmarshal(data, insPb)
+		if err != nil {
+			return "", err
+		}
+		elems = append(elems, ins)
+	}
+	var unwrapped ifs.IElements
+	if len(elems) == 1 {
+		unwrapped = object.New(nil, elems[0])
+	} else {
+		unwrapped = object.New(nil, elems)
+	}
+	data, err := unwrapped.Serialize()
+	if err != nil {
+		return "", err
+	}
+	encData, err := r.Security().Encrypt(data)
+	if err != nil {
+		return "", err
+	}
+	msg.SetData(encData)
+	return request.Key, nil
+}
+
+// duplicateOf returns the result of the original transaction if the message is a retry of
+// a write whose idempotency key was already used within the service's window. A retry of a
+// transaction that is still running waits for its result, up to the retry's timeout.
+func (this *TransactionManager) duplicateOf(msg *ifs.Message, key string, r ifs.IResources) (*l8services.L8Transaction, bool) {
+	if key == "" {
+		return nil, false
+	}
+	window := agreement.For(r, msg.ServiceName(), msg.ServiceArea()).IdempotencyWindow()
+	entry, ok := this.idempotency.claim(ServiceKey(msg.ServiceName(), msg.ServiceArea())+"/"+key, L8TransactionOf(msg), window)
+	if !ok {
+		return nil, false
+	}
+	return this.idempotency.outcome(entry, timeoutOf(msg, r)), true
+}
@@ -0,0 +1,368 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/replication"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+// rebalanceTimeout is the time in seconds to wait for a replica to copy, write or drop
+// the moved keys.
+const rebalanceTimeout = 30
+
+// IEnumerableService is an optional interface a replicated service handler implements to
+// list its elements, by their primary keys, so its keys can be moved between its replicas
+// when its participants change.
+type IEnumerableService interface {
+	All() map[string]interface{}
+}
+
+// KeyMove is a key of a replicated service placed on other replicas than the ones holding
+// it, with both by replica number. ErrMsg tells why the key was not moved.
+type KeyMove struct {
+	Key    string
+	From   map[string]byte
+	To     map[string]byte
+	ErrMsg string
+}
+
+// RebalanceReport is the result of moving the keys of a replicated service to the replicas
+// the hash ring places them on, out of the keys in its replication index.
+type RebalanceReport struct {
+	ServiceName string
+	ServiceArea byte
+	Keys        int
+	Moved       int
+	Moves       []*KeyMove
+	Time        time.Time
+}
+
+// RebalanceKeys moves the keys of a replicated service, placed by consistent hashing, to
+// the replicas the hash ring places them on with the service's current participants. This
+// node must be the service's leader. A live replica holding a moved key copies it to its
+// new replicas, then the old replicas drop it and the replication index is updated. The
+// transactions of the service wait until the keys moved. Returns the report of the moves,
+// which is kept as the service's last one.
+func (this *TransactionManager) RebalanceKeys(serviceName string, serviceArea byte, vnic ifs.IVNic) (*RebalanceReport, error) {
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	if vnic.Resources().Services().GetLeader(serviceName, serviceArea) != localUuid {
+		return nil, errors.New("Rebalance: this node is not the leader of " + serviceName)
+	}
+	service, ok := vnic.Resources().Services().ServiceHandler(serviceName, serviceArea)
+	if !ok || service.TransactionConfig() == nil || !service.TransactionConfig().Replication() {
+		return nil, errors.New("Rebalance: " + serviceName + " is not a replicated service")
+	}
+	placement, _ := agreement.For(vnic.Resources(), serviceName, serviceArea).ReplicaPlacement()
+	placer, ok := vnic.Resources().Services().(IHashPlacement)
+	if placement != agreement.PlacementConsistentHash || !ok {
+		return nil, errors.New("Rebalance: the replicas of " + serviceName + " are not placed by consistent hashing")
+	}
+	index := replication.ReplicationIndex(serviceName, serviceArea, vnic.Resources())
+	if index == nil {
+		return nil, errors.New("Rebalance: no replication index for " + serviceName)
+	}
+
+	probe := &ifs.Message{}
+	probe.SetServiceName(serviceName)
+	probe.SetServiceArea(serviceArea)
+	st := this.transactionsOf(probe, vnic)
+	st.runMtx.Lock()
+	defer st.runMtx.Unlock()
+
+	participants := vnic.Resources().Services().GetParticipants(serviceName, serviceArea)
+	replications := service.TransactionConfig().ReplicationCount()
+	report := &RebalanceReport{ServiceName: serviceName, ServiceArea: serviceArea, Keys: len(index.Keys), Time: time.Now()}
+	moves := make(map[string]*KeyMove)
+	bySource := make(map[string]*l8svcs.L8Rebalance)
+	keys := make([]string, 0, len(index.Keys))
+	for key := range index.Keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		from := replicasOf(index.Keys[key])
+		to := placer.HashParticipants(serviceName, serviceArea, key, replications)
+		if sameReplicas(from, to) {
+			continue
+		}
+		move := &KeyMove{Key: key, From: from, To: to}
+		report.Moves = append(report.Moves, move)
+		source := sourceOf(from, to, participants)
+		if source == "" {
+			move.ErrMsg = "no live replica holds the key"
+			continue
+		}
+		moves[key] = move
+		if bySource[source] == nil {
+			bySource[source] = &l8svcs.L8Rebalance{}
+		}
+		bySource[source].Moves = append(bySource[source].Moves, &l8svcs.L8KeyMove{Key: key,
+			From: locationOf(from), To: locationOf(to)})
+	}
+
+	//The holders copy the keys to their new replicas first, so a key is never without one
+	copied := make(map[string]bool)
+	for source, rebalance := range bySource {
+		resp := this.rebalanceOn(source, ifs.POST, rebalance, serviceName, serviceArea, vnic)
+		if resp != nil && resp.Error() == nil {
+			if done, ok := resp.Element().(*l8svcs.L8Rebalance); ok && done != nil {
+				for _, move := range done.Moves {
+					copied[move.Key] = true
+				}
+			}
+		}
+		for _, move := range rebalance.Moves {
+			if !copied[move.Key] {
+				moves[move.Key].ErrMsg = "failed to copy the key from " + source + " to its new replicas"
+			}
+		}
+	}
+
+	drops := make(map[string]*l8svcs.L8Rebalance)
+	for key := range copied {
+		for node := range moves[key].From {
+			_, stays := moves[key].To[node]
+			_, live := participants[node]
+			if stays || !live {
+				continue
+			}
+			if drops[node] == nil {
+				drops[node] = &l8svcs.L8Rebalance{}
+			}
+			drops[node].Moves = append(drops[node].Moves, &l8svcs.L8KeyMove{Key: key})
+		}
+	}
+	for node, rebalance := range drops {
+		resp := this.rebalanceOn(node, ifs.DELETE, rebalance, serviceName, serviceArea, vnic)
+		if resp == nil || resp.Error() != nil {
+			vnic.Resources().Logger().Error("Rebalance: failed to drop the moved keys of ", serviceName, " area ",
+				serviceArea, " from ", node)
+		}
+	}
+
+	for key := range copied {
+		index.Keys[key] = &l8services.L8ReplicationKey{Location: locationOf(moves[key].To)}
+		report.Moved++
+	}
+	if report.Moved > 0 {
+		replication.Service(vnic.Resources()).Put(object.New(nil, index), vnic)
+	}
+	vnic.Resources().Logger().Info("Rebalance: moved ", report.Moved, " of ", report.Keys, " keys of ", serviceName,
+		" area ", serviceArea, ", ", len(report.Moves)-report.Moved, " failed")
+	this.mtx.Lock()
+	this.rebalances[ServiceKey(serviceName, serviceArea)] = report
+	this.mtx.Unlock()
+	return report, nil
+}
+
+// Rebalanced returns the report of the last time the keys of a service were moved by this
+// node, nil if they never were.
+func (this *TransactionManager) Rebalanced(serviceName string, serviceArea byte) *RebalanceReport {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return this.rebalances[ServiceKey(serviceName, serviceArea)]
+}
+
+// rebalanceOn sends the moves of the keys to a replica, handling them here if it is this node.
+func (this *TransactionManager) rebalanceOn(node string, action ifs.Action, rebalance *l8svcs.L8Rebalance,
+	serviceName string, serviceArea byte, vnic ifs.IVNic) ifs.IElements {
+	if node == vnic.Resources().SysConfig().LocalUuid {
+		return this.Rebalance(action, rebalance, serviceName, serviceArea, vnic)
+	}
+	return vnic.Request(node, serviceName, serviceArea, action, rebalance, rebalanceTimeout)
+}
+
+// Rebalance handles the moves of keys of a replicated service on this replica. A POST
+// copies the keys this node holds to their new replicas and returns the moves of the keys
+// it copied, a PUT writes the copies on this node and a DELETE drops the keys from it. The
+// writes do not change the replication index, the leader updates it once the keys moved.
+func (this *TransactionManager) Rebalance(action ifs.Action, rebalance *l8svcs.L8Rebalance, serviceName string,
+	serviceArea byte, vnic ifs.IVNic) ifs.IElements {
+	service, ok := vnic.Resources().Services().ServiceHandler(serviceName, serviceArea)
+	if !ok || service.TransactionConfig() == nil {
+		return object.NewError("Rebalance: no transactional handler for " + serviceName)
+	}
+	switch action {
+	case ifs.POST:
+		return this.copyKeys(rebalance, serviceName, serviceArea, service, vnic)
+	case ifs.PUT:
+		return writeKeys(rebalance, service, vnic)
+	case ifs.DELETE:
+		return dropKeys(rebalance, service, vnic)
+	}
+	return object.NewError("Rebalance: unsupported action")
+}
+
+// copyKeys copies the elements of the moved keys this node holds to the new replicas of
+// each, and returns the moves of the keys copied to all of them.
+func (this *TransactionManager) copyKeys(rebalance *l8svcs.L8Rebalance, serviceName string, serviceArea byte,
+	service ifs.IServiceHandler, vnic ifs.IVNic) ifs.IElements {
+	elements, err := elementsByKey(service, vnic)
+	if err != nil {
+		return object.NewError("Rebalance: " + err.Error())
+	}
+	copies := make(map[string]*l8svcs.L8Rebalance)
+	failed := make(map[string]bool)
+	for _, move := range rebalance.Moves {
+		elem, ok := elements[move.Key]
+		if !ok {
+			failed[move.Key] = true
+			continue
+		}
+		syncElem, err := syncElementOf(move.Key, elem)
+		if err != nil {
+			vnic.Resources().Logger().Error("Rebalance: ", err.Error())
+			failed[move.Key] = true
+			continue
+		}
+		for node := range move.To {
+			if _, held := move.From[node]; held {
+				continue
+			}
+			if copies[node] == nil {
+				copies[node] = &l8svcs.L8Rebalance{}
+			}
+			copies[node].Moves = append(copies[node].Moves, &l8svcs.L8KeyMove{Key: move.Key, To: move.To, Element: syncElem})
+		}
+	}
+	for node, batch := range copies {
+		resp := this.rebalanceOn(node, ifs.PUT, batch, serviceName, serviceArea, vnic)
+		if resp == nil || resp.Error() != nil {
+			for _, move := range batch.Moves {
+				failed[move.Key] = true
+			}
+		}
+	}
+	result := &l8svcs.L8Rebalance{}
+	for _, move := range rebalance.Moves {
+		if !failed[move.Key] {
+			result.Moves = append(result.Moves, &l8svcs.L8KeyMove{Key: move.Key, From: move.From, To: move.To})
+		}
+	}
+	return object.New(nil, result)
+}
+
+// writeKeys writes the copies of the moved keys on this node.
+func writeKeys(rebalance *l8svcs.L8Rebalance, service ifs.IServiceHandler, vnic ifs.IVNic) ifs.IElements {
+	for _, move := range rebalance.Moves {
+		elem, err := decodeSyncElement(move.Element, vnic.Resources())
+		if err != nil {
+			return object.NewError("Rebalance: " + err.Error())
+		}
+		resp := service.Put(object.New(nil, elem), vnic)
+		if resp != nil && resp.Error() != nil {
+			return object.NewError("Rebalance: failed to write " + move.Key + ": " + resp.Error().Error())
+		}
+	}
+	return object.New(nil, rebalance)
+}
+
+// dropKeys drops the moved keys from this node.
+func dropKeys(rebalance *l8svcs.L8Rebalance, service ifs.IServiceHandler, vnic ifs.IVNic) ifs.IElements {
+	elements, err := elementsByKey(service, vnic)
+	if err != nil {
+		return object.NewError("Rebalance: " + err.Error())
+	}
+	for _, move := range rebalance.Moves {
+		elem, ok := elements[move.Key]
+		if !ok {
+			continue
+		}
+		resp := service.Delete(object.New(nil, elem), vnic)
+		if resp != nil && resp.Error() != nil {
+			return object.NewError("Rebalance: failed to drop " + move.Key + ": " + resp.Error().Error())
+		}
+	}
+	return object.New(nil, rebalance)
+}
+
+// elementsByKey returns the elements of the service on this node by their keys.
+func elementsByKey(service ifs.IServiceHandler, vnic ifs.IVNic) (map[string]interface{}, error) {
+	enumerable, ok := service.(IEnumerableService)
+	if !ok {
+		return nil, errors.New("the service does not list its elements")
+	}
+	result := make(map[string]interface{})
+	for _, elem := range enumerable.All() {
+		key := service.TransactionConfig().KeyOf(object.New(nil, elem), vnic.Resources())
+		if key != "" {
+			result[key] = elem
+		}
+	}
+	return result, nil
+}
+
+// replicasOf returns the replicas of a key in the replication index, by replica number.
+func replicasOf(location *l8services.L8ReplicationKey) map[string]byte {
+	result := make(map[string]byte)
+	if location == nil {
+		return result
+	}
+	for node, replica := range location.Location {
+		result[node] = byte(replica)
+	}
+	return result
+}
+
+// locationOf returns the replicas of a key as they are kept in the replication index.
+func locationOf(replicas map[string]byte) map[string]int32 {
+	result := make(map[string]int32, len(replicas))
+	for node, replica := range replicas {
+		result[node] = int32(replica)
+	}
+	return result
+}
+
+// sameReplicas returns true if both are the same nodes, whatever their replica numbers.
+func sameReplicas(a, b map[string]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for node := range a {
+		if _, ok := b[node]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sourceOf returns the live replica holding a key that copies it to its new replicas,
+// preferring one that keeps holding it. Returns an empty string if no live replica holds it.
+func sourceOf(from, to map[string]byte, participants map[string]byte) string {
+	nodes := make([]string, 0, len(from))
+	for node := range from {
+		if _, live := participants[node]; live {
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return ""
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		if _, stays := to[node]; stays {
+			return node
+		}
+	}
+	return nodes[0]
+}
@@ -0,0 +1,373 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"sync"
+	"time"
+
+	"github.com/saichler/l8bus/go/overlay/protocol"
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// DefaultLaggingLimit is the number of lagging peer records kept per service.
+const DefaultLaggingLimit = 1024
+
+// repairTick is how often the repairer checks if the repair interval of a service passed.
+const repairTick = time.Second
+
+// LaggingPeer is a target that missed a transaction committed by a quorum of its peers.
+// Superseded are the keys of the transaction that a newer committed transaction already
+// set on the peer, they are skipped when the transaction is replayed.
+type LaggingPeer struct {
+	TrId        string
+	ServiceName string
+	ServiceArea byte
+	Peer        string
+	Replica     byte
+	Keys        []string
+	Superseded  []string
+	ErrMsg      string
+	Time        time.Time
+	msg         *ifs.Message
+	isReplicate bool
+}
+
+// LaggingPeers records, per service, the peers that missed committed transactions,
+// in commit order, until a repair catches them up. Up to a limit of records are kept
+// per service, the oldest are dropped beyond it.
+type LaggingPeers struct {
+	peers   map[string][]*LaggingPeer
+	dropped map[string]int
+	limit   int
+	mtx     *sync.Mutex
+}
+
+// newLaggingPeers creates an empty lagging peers record.
+func newLaggingPeers() *LaggingPeers {
+	lp := &LaggingPeers{}
+	lp.peers = make(map[string][]*LaggingPeer)
+	lp.dropped = make(map[string]int)
+	lp.limit = DefaultLaggingLimit
+	lp.mtx = &sync.Mutex{}
+	return lp
+}
+
+// SetLimit sets how many lagging peer records are kept per service. Values lower than 1
+// are treated as 1.
+func (this *LaggingPeers) SetLimit(limit int) {
+	if limit < 1 {
+		limit = 1
+	}
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.limit = limit
+}
+
+// add records the targets that missed the committed transaction of the message. The keys
+// it set on the targets that applied it are superseded in their older records, and the
+// records left without keys are dropped. Returns the number of records dropped as the
+// service reached the limit, their peers need a full resync.
+func (this *LaggingPeers) add(msg *ifs.Message, keys []string, applied map[string]byte, missed map[string]byte,
+	isReplicate bool, errMsgs map[string]string) int {
+	serviceKey := ServiceKey(msg.ServiceName(), msg.ServiceArea())
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.supersede(serviceKey, keys, applied)
+	if len(missed) == 0 {
+		return 0
+	}
+	clone := msg.Clone()
+	clone.SetTr_State(ifs.Committed)
+	for peer, replica := range missed {
+		this.peers[serviceKey] = append(this.peers[serviceKey], &LaggingPeer{
+			TrId:        msg.Tr_Id(),
+			ServiceName: msg.ServiceName(),
+			ServiceArea: msg.ServiceArea(),
+			Peer:        peer,
+			Replica:     replica,
+			Keys:        keys,
+			ErrMsg:      errMsgs[peer],
+			Time:        time.Now(),
+			msg:         clone,
+			isReplicate: isReplicate,
+		})
+	}
+	dropped := len(this.peers[serviceKey]) - this.limit
+	if dropped <= 0 {
+		return 0
+	}
+	this.peers[serviceKey] = this.peers[serviceKey][dropped:]
+	this.dropped[serviceKey] += dropped
+	return dropped
+}
+
+// supersede marks the keys as superseded in the records of the peers that applied a newer
+// transaction on them, and drops the records all of whose keys are superseded. The caller
+// holds the mutex.
+func (this *LaggingPeers) supersede(serviceKey string, keys []string, applied map[string]byte) {
+	list := this.peers[serviceKey]
+	if len(list) == 0 || len(keys) == 0 || len(applied) == 0 {
+		return
+	}
+	newer := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		newer[key] = true
+	}
+	kept := list[:0]
+	for _, lp := range list {
+		if _, ok := applied[lp.Peer]; ok && len(lp.Keys) > 0 {
+			for _, key := range lp.Keys {
+				if newer[key] && !contains(lp.Superseded, key) {
+					lp.Superseded = append(lp.Superseded, key)
+				}
+			}
+			if len(lp.Superseded) == len(lp.Keys) {
+				continue
+			}
+		}
+		kept = append(kept, lp)
+	}
+	if len(kept) == 0 {
+		delete(this.peers, serviceKey)
+		return
+	}
+	this.peers[serviceKey] = kept
+}
+
+// contains returns true if the key is in the list.
+func contains(list []string, key string) bool {
+	for _, k := range list {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Of returns the lagging peers of a service, in commit order.
+func (this *LaggingPeers) Of(serviceName string, serviceArea byte) []*LaggingPeer {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	lagging := this.peers[ServiceKey(serviceName, serviceArea)]
+	result := make([]*LaggingPeer, len(lagging))
+	copy(result, lagging)
+	return result
+}
+
+// servicesOf returns a record of every service the peer lags on.
+func (this *LaggingPeers) servicesOf(peer string) []*LaggingPeer {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	result := make([]*LaggingPeer, 0)
+	for _, lagging := range this.peers {
+		for _, lp := range lagging {
+			if lp.Peer == peer {
+				result = append(result, lp)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Dropped returns how many lagging peer records of a service were dropped over the limit.
+func (this *LaggingPeers) Dropped(serviceName string, serviceArea byte) int {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return this.dropped[ServiceKey(serviceName, serviceArea)]
+}
+
+// Remove drops a lagging peer record, once it was repaired out of band.
+func (this *LaggingPeers) Remove(lagging *LaggingPeer) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	serviceKey := ServiceKey(lagging.ServiceName, lagging.ServiceArea)
+	list := this.peers[serviceKey]
+	for i, lp := range list {
+		if lp == lagging {
+			this.peers[serviceKey] = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(this.peers[serviceKey]) == 0 {
+		delete(this.peers, serviceKey)
+	}
+}
+
+// superseded returns the keys of the record superseded so far.
+func (this *LaggingPeers) superseded(lagging *LaggingPeer) []string {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	result := make([]string, len(lagging.Superseded))
+	copy(result, lagging.Superseded)
+	return result
+}
+
+// LaggingPeers returns the record of the peers that missed transactions this node committed.
+func (this *TransactionManager) LaggingPeers() *LaggingPeers {
+	return this.lagging
+}
+
+// Repair catches up the lagging peers of a service by replaying the transactions they
+// missed, in commit order, through prepare, commit and cleanup on each of them. The keys
+// a newer transaction already set on a peer are left out of the replay, and a record left
+// without keys is dropped. Once a replay on a peer fails, its later records wait for the
+// next repair, so a peer never applies them out of order. The service's transactions are
+// held during the repair. Returns the number of repaired records.
+func (this *TransactionManager) Repair(serviceName string, serviceArea byte, vnic ifs.IVNic) int {
+	lagging := this.lagging.Of(serviceName, serviceArea)
+	if len(lagging) == 0 {
+		return 0
+	}
+	probe := &ifs.Message{}
+	probe.SetServiceName(serviceName)
+	probe.SetServiceArea(serviceArea)
+	st := this.transactionsOf(probe, vnic)
+	st.runMtx.Lock()
+	defer st.runMtx.Unlock()
+
+	repaired := 0
+	failed := make(map[string]bool)
+	for _, lp := range lagging {
+		if failed[lp.Peer] {
+			continue
+		}
+		msg, ok := st.replayOf(lp, this.lagging.superseded(lp))
+		if !ok {
+			this.lagging.Remove(lp)
+			repaired++
+			continue
+		}
+		if !this.replay(msg, lp, vnic) {
+			failed[lp.Peer] = true
+			continue
+		}
+		this.lagging.Remove(lp)
+		repaired++
+	}
+	return repaired
+}
+
+// replay runs a missed transaction through prepare, commit and cleanup on its lagging peer.
+// Returns false, after rolling it back, if the peer did not apply it.
+func (this *TransactionManager) replay(msg *ifs.Message, lp *LaggingPeer, vnic ifs.IVNic) bool {
+	target := map[string]byte{lp.Peer: lp.Replica}
+	deadline := time.Now().Add(timeoutOf(msg, vnic.Resources()))
+	msg.SetTr_State(ifs.Running)
+	ok, _, _ := this.requestPhase(msg, target, vnic, lp.isReplicate, deadline)
+	if ok {
+		msg.SetTr_State(ifs.Committed)
+		ok, _, _ = this.requestPhase(msg, target, vnic, lp.isReplicate, deadline)
+	}
+	if !ok {
+		msg.SetTr_State(ifs.Rollback)
+		this.requestPhase(msg, target, vnic, lp.isReplicate, time.Now().Add(timeoutOf(msg, vnic.Resources())))
+		return false
+	}
+	msg.SetTr_State(ifs.Cleanup)
+	this.requestPhase(msg, target, vnic, lp.isReplicate, time.Now().Add(timeoutOf(msg, vnic.Resources())))
+	return true
+}
+
+// replayOf returns the message replaying a missed transaction, without the elements of
+// the superseded keys. Returns false if none of its elements is left to replay.
+func (this *ServiceTransactions) replayOf(lp *LaggingPeer, superseded []string) (*ifs.Message, bool) {
+	msg := lp.msg.Clone()
+	if len(superseded) == 0 {
+		return msg, true
+	}
+	service, ok := this.nic.Resources().Services().ServiceHandler(msg.ServiceName(), msg.ServiceArea())
+	if !ok || service.TransactionConfig() == nil {
+		return msg, true
+	}
+	pb, err := protocol.ElementsOf(msg, this.nic.Resources())
+	if err != nil {
+		return msg, true
+	}
+	elems := make([]interface{}, 0, len(pb.Elements()))
+	for _, elem := range pb.Elements() {
+		if elem == nil {
+			continue
+		}
+		key := service.TransactionConfig().KeyOf(object.New(nil, elem), this.nic.Resources())
+		if key != "" && contains(superseded, key) {
+			continue
+		}
+		elems = append(elems, elem)
+	}
+	if len(elems) == 0 {
+		return nil, false
+	}
+	var remaining ifs.IElements
+	if len(elems) == 1 {
+		remaining = object.New(nil, elems[0])
+	} else {
+		remaining = object.New(nil, elems)
+	}
+	data, err := remaining.Serialize()
+	if err != nil {
+		return msg, true
+	}
+	encData, err := this.nic.Resources().Security().Encrypt(data)
+	if err != nil {
+		return msg, true
+	}
+	msg.SetData(encData)
+	return msg, true
+}
+
+// repairLagging is the background goroutine that repairs the lagging peers of the service,
+// every repair interval of its agreement, while this node is its leader. Peers excluded as
+// unhealthy are left for after they are re-admitted. It stops when the service shuts down.
+func (this *ServiceTransactions) repairLagging() {
+	ticker := time.NewTicker(repairTick)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-this.stop:
+			return
+		case <-ticker.C:
+		}
+		interval := agreement.For(this.nic.Resources(), this.serviceName, this.serviceArea).RepairInterval()
+		if interval <= 0 || time.Since(last) < interval {
+			continue
+		}
+		last = time.Now()
+		if !this.hasRepairable() {
+			continue
+		}
+		if this.nic.Resources().Services().GetLeader(this.serviceName, this.serviceArea) != this.nic.Resources().SysConfig().LocalUuid {
+			continue
+		}
+		repaired := this.tm.Repair(this.serviceName, this.serviceArea, this.nic)
+		if repaired > 0 {
+			this.nic.Resources().Logger().Info("LaggingPeers: repaired ", repaired, " records of ", this.serviceName,
+				" area ", this.serviceArea)
+		}
+	}
+}
+
+// hasRepairable returns true if the service has a lagging peer that is not excluded as unhealthy.
+func (this *ServiceTransactions) hasRepairable() bool {
+	for _, lp := range this.tm.lagging.Of(this.serviceName, this.serviceArea) {
+		if !this.tm.health.Excluded(lp.Peer, nil) {
+			return true
+		}
+	}
+	return false
+}
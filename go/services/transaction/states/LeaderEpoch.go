@@ -0,0 +1,110 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"time"
+
+	"github.com/saichler/l8bus/go/overlay/protocol"
+	"github.com/saichler/l8services/go/services/transaction/requests"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// SetEpochs sets the function that returns the epoch a node claimed for the leadership
+// of a service, 0 if it did not claim it, and the highest epoch seen for the service.
+// The leader sends its epoch on the phase messages of its transactions, and the phase
+// messages of a stale epoch are rejected, so a deposed leader cannot change the state
+// of the participants.
+func (this *TransactionManager) SetEpochs(epochs func(serviceName string, serviceArea byte, node string) (int64, int64)) {
+	this.epochs = epochs
+}
+
+// fenced returns true if the node claimed the leadership of the message's service with
+// a stale epoch, i.e. it was deposed by a newer leader.
+func (this *TransactionManager) fenced(msg *ifs.Message, node string) bool {
+	if this.epochs == nil {
+		return false
+	}
+	claimed, highest := this.epochs(msg.ServiceName(), msg.ServiceArea(), node)
+	return claimed > 0 && claimed < highest
+}
+
+// stalePhase returns true if a phase message was sent with a stale epoch. The message is
+// restored to its original data. A message without an epoch, e.g. of a node that does
+// not send one, is stale if its source is a deposed leader.
+func (this *TransactionManager) stalePhase(msg *ifs.Message, vnic ifs.IVNic) bool {
+	pb, err := protocol.ElementsOf(msg, vnic.Resources())
+	if err != nil || pb == nil {
+		return this.fenced(msg, msg.Source())
+	}
+	phase, ok := pb.Element().(*l8svcs.L8Phase)
+	if !ok || phase == nil {
+		return this.fenced(msg, msg.Source())
+	}
+	msg.SetData(phase.Data)
+	if this.epochs == nil {
+		return false
+	}
+	_, highest := this.epochs(msg.ServiceName(), msg.ServiceArea(), msg.Source())
+	return phase.Epoch < highest
+}
+
+// WithEpoch returns a clone of a phase message that carries the given leader epoch.
+func WithEpoch(msg *ifs.Message, epoch int64, r ifs.IResources) (*ifs.Message, error) {
+	data, err := object.New(nil, &l8svcs.L8Phase{Epoch: epoch, Data: msg.Data()}).Serialize()
+	if err != nil {
+		return nil, err
+	}
+	encData, err := r.Security().Encrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	clone := msg.Clone()
+	clone.SetData(encData)
+	return clone, nil
+}
+
+// epochOf returns the epoch this node claimed for the leadership of the message's service,
+// 0 if it did not claim it.
+func (this *TransactionManager) epochOf(msg *ifs.Message, vnic ifs.IVNic) int64 {
+	if this.epochs == nil {
+		return 0
+	}
+	claimed, _ := this.epochs(msg.ServiceName(), msg.ServiceArea(), vnic.Resources().SysConfig().LocalUuid)
+	return claimed
+}
+
+// requestPhase sends a phase message to the targets like requests.RequestFromPeersTimed,
+// carrying the epoch of this node's leadership of the service. If the phase failed on a
+// target, the message is marked as failed.
+func (this *TransactionManager) requestPhase(msg *ifs.Message, targets map[string]byte, vnic ifs.IVNic,
+	isReplicate bool, deadline time.Time) (bool, map[string]string, map[string]time.Duration) {
+	phase := msg
+	claimed := this.epochOf(msg, vnic)
+	if claimed > 0 {
+		withEpoch, err := WithEpoch(msg, claimed, vnic.Resources())
+		if err != nil {
+			vnic.Resources().Logger().Error("LeaderEpoch: failed to add the epoch to ", msg.Tr_Id(), ": ", err.Error())
+		} else {
+			phase = withEpoch
+		}
+	}
+	ok, peers, latencies := requests.RequestFromPeersTimed(phase, targets, vnic, isReplicate, deadline)
+	if !ok {
+		msg.SetTr_State(ifs.Failed)
+	}
+	return ok, peers, latencies
+}
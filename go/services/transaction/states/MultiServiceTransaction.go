@@ -0,0 +1,393 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/transaction/requests"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+// TransactionOperation is a single step of a multi-service transaction,
+// applying an action with the given elements on a service and area.
+type TransactionOperation struct {
+	ServiceName string
+	ServiceArea byte
+	Action      ifs.Action
+	Elements    ifs.IElements
+}
+
+// multiStep is the runtime state of a single operation within a multi-service transaction.
+// The leader of the operation's service also keeps its targets, the ones that prepared it
+// and when it started running.
+type multiStep struct {
+	msg         *ifs.Message
+	batch       string
+	targets     map[string]byte
+	prepared    map[string]byte
+	isReplicate bool
+	running     time.Time
+}
+
+// RunMulti commits an ordered batch of operations, possibly on several services, as a
+// single atomic transaction. Any node may coordinate the batch, every operation is run by
+// the leader of its service, on the service's participants and with the leader's epoch.
+// The operations are first prepared, in order, and only when all of them voted yes is the
+// decision of the batch logged and are they committed. If any of them fails to prepare,
+// the prepared ones are rolled back in reverse order. Once logged, the decision stands:
+// an operation whose leader misses the commit is sent again, and a new leader of its
+// service asks this node for the decision. A batch sent again with the same idempotency
+// key, within the window of its services, gets the original batch's result.
+func (this *TransactionManager) RunMulti(ops []*TransactionOperation, key string, vnic ifs.IVNic) *l8services.L8Transaction {
+	tr := &l8services.L8Transaction{Id: ifs.NewUuid(), State: int32(ifs.Created), Created: time.Now().UnixMilli()}
+	if len(ops) == 0 {
+		return this.multiFailed(tr, "RunMulti: no operations", vnic)
+	}
+
+	steps, err := this.multiSteps(tr.Id, ops, vnic)
+	if err != nil {
+		return this.multiFailed(tr, err.Error(), vnic)
+	}
+	if key != "" {
+		window, timeout := batchLimitsOf(steps, vnic.Resources())
+		original, ok := this.idempotency.claim("batch/"+key, tr, window)
+		if ok {
+			vnic.Resources().Logger().Debug("RunMulti: batch ", key, " is a retry")
+			//A batch runs two phases of every operation
+			return this.idempotency.outcome(original, 2*time.Duration(len(steps))*timeout)
+		}
+	}
+
+	tr = &l8services.L8Transaction{Id: tr.Id, State: int32(ifs.Running), Created: tr.Created, Running: time.Now().UnixMilli()}
+	err = this.recordBatch(tr, vnic)
+	if err != nil {
+		return this.multiFailed(tr, "RunMulti: Failed to log "+tr.Id+": "+err.Error(), vnic)
+	}
+
+	//Phase 1, the leader of every operation's service prepares it
+	for i, step := range steps {
+		this.metrics.created(step.msg)
+		ok, errMsg := this.sendStep(step, ifs.Running, vnic)
+		if !ok {
+			//The failed operation too, its leader may have prepared it before failing to answer
+			this.rollbackSteps(steps[:i+1], vnic)
+			return this.multiFailed(tr, "RunMulti: Failed to prepare "+step.msg.ServiceName()+
+				" area "+strconv.Itoa(int(step.msg.ServiceArea()))+": "+errMsg, vnic)
+		}
+	}
+
+	//Phase 2, log the decision of the batch, then the leaders commit the operations. The
+	//logged decision stands, an operation whose leader missed it is sent to the service's
+	//leader once more.
+	committed := &l8services.L8Transaction{Id: tr.Id, State: int32(ifs.Committed), Created: tr.Created,
+		Running: tr.Running, End: time.Now().UnixMilli()}
+	err = this.recordBatch(committed, vnic)
+	if err != nil {
+		//The decision is not logged, so it is not taken
+		this.rollbackSteps(steps, vnic)
+		return this.multiFailed(tr, "RunMulti: Failed to log the commit of "+tr.Id+": "+err.Error(), vnic)
+	}
+	acknowledged := true
+	for _, step := range steps {
+		ok, errMsg := this.sendStep(step, ifs.Committed, vnic)
+		if !ok {
+			ok, errMsg = this.sendStep(step, ifs.Committed, vnic)
+		}
+		if !ok {
+			acknowledged = false
+			vnic.Resources().Logger().Warning("RunMulti: ", step.msg.Tr_Id(), " of ", tr.Id, " missed the commit, ",
+				"the leader of ", step.msg.ServiceName(), " resolves it by the logged decision: ", errMsg)
+		}
+	}
+	//The decision is kept in the log until all the operations committed
+	if acknowledged {
+		this.recordBatch(&l8services.L8Transaction{Id: tr.Id, State: int32(ifs.Cleanup)}, vnic)
+	}
+	return committed
+}
+
+// multiSteps builds the message of every operation and checks its service has a leader.
+// Each operation gets its own transaction id, derived from the batch id, so the
+// same service may appear more than once in a batch.
+func (this *TransactionManager) multiSteps(batch string, ops []*TransactionOperation, vnic ifs.IVNic) ([]*multiStep, error) {
+	steps := make([]*multiStep, 0, len(ops))
+	for i, op := range ops {
+		service, ok := vnic.Resources().Services().ServiceHandler(op.ServiceName, op.ServiceArea)
+		if ok && service.TransactionConfig() == nil {
+			return nil, errors.New("RunMulti: service " + op.ServiceName + " area " +
+				strconv.Itoa(int(op.ServiceArea)) + " is not transactional")
+		}
+		if vnic.Resources().Services().GetLeader(op.ServiceName, op.ServiceArea) == "" {
+			return nil, errors.New("RunMulti: service " + op.ServiceName + " area " +
+				strconv.Itoa(int(op.ServiceArea)) + " has no leader")
+		}
+		msg, err := newOperationMessage(batch+"-"+strconv.Itoa(i), op, vnic.Resources())
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, &multiStep{msg: msg, batch: batch})
+	}
+	return steps, nil
+}
+
+// sendStep sends a phase of an operation to the current leader of its service, or runs it
+// right away if this node is the leader. Returns true if the leader ran the phase,
+// otherwise the error it failed with.
+func (this *TransactionManager) sendStep(step *multiStep, state ifs.TransactionState, vnic ifs.IVNic) (bool, string) {
+	msg := step.msg
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	request := &l8svcs.L8BatchStep{Batch: step.batch, Coordinator: localUuid, TrId: msg.Tr_Id(), State: int32(state),
+		Action: int32(msg.Action()), Timeout: msg.Tr_Timeout(), Data: msg.Data()}
+	service := msg.ServiceName() + " area " + strconv.Itoa(int(msg.ServiceArea()))
+	var resp ifs.IElements
+	switch leader := vnic.Resources().Services().GetLeader(msg.ServiceName(), msg.ServiceArea()); leader {
+	case "":
+		return false, "no leader for " + service
+	case localUuid:
+		resp = this.Step(request, msg, vnic)
+	default:
+		//The leader's phase is bounded by the same timeout, give its answer the time to arrive
+		timeout := int(timeoutOf(msg, vnic.Resources())/time.Second) + 1
+		resp = vnic.Request(leader, msg.ServiceName(), msg.ServiceArea(), ifs.PUT, request, timeout)
+	}
+	if resp == nil {
+		return false, "no response from the leader of " + service
+	}
+	if resp.Error() != nil {
+		return false, resp.Error().Error()
+	}
+	tr, ok := resp.Element().(*l8services.L8Transaction)
+	if !ok {
+		return false, "unexpected response from the leader of " + service
+	}
+	return tr.State == int32(state), tr.ErrMsg
+}
+
+// rollbackSteps has the leaders of the given operations roll them back, last operation first.
+func (this *TransactionManager) rollbackSteps(steps []*multiStep, vnic ifs.IVNic) {
+	for i := len(steps) - 1; i >= 0; i-- {
+		this.sendStep(steps[i], ifs.Rollback, vnic)
+	}
+}
+
+// multiFailed marks the batch transaction as failed and records it.
+func (this *TransactionManager) multiFailed(tr *l8services.L8Transaction, errMsg string, vnic ifs.IVNic) *l8services.L8Transaction {
+	tr = &l8services.L8Transaction{Id: tr.Id, State: int32(ifs.Failed), ErrMsg: errMsg, Created: tr.Created,
+		Running: tr.Running, End: time.Now().UnixMilli()}
+	this.recordBatch(tr, vnic)
+	return tr
+}
+
+// batchLimitsOf returns the longest idempotency window and transaction timeout of the
+// services of a batch.
+func batchLimitsOf(steps []*multiStep, r ifs.IResources) (time.Duration, time.Duration) {
+	var window, timeout time.Duration
+	for _, step := range steps {
+		if w := agreement.For(r, step.msg.ServiceName(), step.msg.ServiceArea()).IdempotencyWindow(); w > window {
+			window = w
+		}
+		if t := timeoutOf(step.msg, r); t > timeout {
+			timeout = t
+		}
+	}
+	return window, timeout
+}
+
+// newOperationMessage creates the transaction message of an operation, in the Running
+// state, with the operation's elements as its serialized and encrypted payload and the
+// service's SLA transaction timeout.
+func newOperationMessage(trId string, op *TransactionOperation, r ifs.IResources) (*ifs.Message, error) {
+	data, err := op.Elements.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	encData, err := r.Security().Encrypt(data)
+	if err != nil {
+		return nil, err
+	}
+	msg := &ifs.Message{}
+	msg.SetSource(r.SysConfig().LocalUuid)
+	msg.SetServiceName(op.ServiceName)
+	msg.SetServiceArea(op.ServiceArea)
+	msg.SetAction(op.Action)
+	msg.SetData(encData)
+	msg.SetTr_Id(trId)
+	msg.SetTr_State(ifs.Running)
+	msg.SetTr_Timeout(int64(timeoutOf(msg, r) / time.Second))
+	return msg, nil
+}
+
+// Step runs a phase of an operation of a multi-service transaction on the participants of
+// the operation's service, for the node coordinating the batch. Like a queued transaction,
+// it is rejected unless this node leads the service and was not deposed.
+func (this *TransactionManager) Step(step *l8svcs.L8BatchStep, msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
+	stepMsg := &ifs.Message{}
+	stepMsg.SetSource(vnic.Resources().SysConfig().LocalUuid)
+	stepMsg.SetServiceName(msg.ServiceName())
+	stepMsg.SetServiceArea(msg.ServiceArea())
+	stepMsg.SetAction(ifs.Action(step.Action))
+	stepMsg.SetData(step.Data)
+	stepMsg.SetTr_Id(step.TrId)
+	stepMsg.SetTr_State(ifs.TransactionState(step.State))
+	stepMsg.SetTr_Timeout(step.Timeout)
+
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	if vnic.Resources().Services().GetLeader(stepMsg.ServiceName(), stepMsg.ServiceArea()) != localUuid {
+		return stepFailed(stepMsg, "A non leader has got the step")
+	}
+	if this.fenced(stepMsg, localUuid) {
+		return stepFailed(stepMsg, "A deposed leader has got the step")
+	}
+	st := this.transactionsOf(stepMsg, vnic)
+	st.runMtx.Lock()
+	defer st.runMtx.Unlock()
+	switch stepMsg.Tr_State() {
+	case ifs.Running:
+		return st.prepareStep(stepMsg, step.Batch, step.Coordinator)
+	case ifs.Committed:
+		prepared, err := st.stepOf(stepMsg)
+		if err != nil {
+			return stepFailed(stepMsg, "RunMulti: Protocol Error: "+stepMsg.Tr_Id()+" "+err.Error())
+		}
+		return st.commitStep(prepared)
+	case ifs.Rollback:
+		prepared, err := st.stepOf(stepMsg)
+		if err != nil {
+			return stepFailed(stepMsg, "RunMulti: Protocol Error: "+stepMsg.Tr_Id()+" "+err.Error())
+		}
+		return st.rollbackStep(prepared, "RunMulti: rolled back with batch "+step.Batch)
+	}
+	return stepFailed(stepMsg, "Unexpected step state "+stepMsg.Tr_State().String())
+}
+
+// prepareStep resolves the targets of an operation of a batch, logs it and prepares it on
+// them. An operation that fails to prepare is rolled back right away, a prepared one is
+// kept for the decision of the batch.
+func (this *ServiceTransactions) prepareStep(msg *ifs.Message, batch, coordinator string) ifs.IElements {
+	targets, isReplicate, err := this.targetsOf(msg)
+	if err != nil {
+		return stepFailed(msg, "RunMulti: Protocol Error: "+msg.Tr_Id()+" "+err.Error())
+	}
+	step := &multiStep{msg: msg, batch: batch, targets: targets, prepared: map[string]byte{},
+		isReplicate: isReplicate, running: time.Now()}
+	err = this.tm.recordTransition(msg, false, false, nil, this.nic)
+	if err == nil {
+		err = this.tm.recordStep(msg, batch, coordinator, targets, isReplicate, this.nic)
+	}
+	if err != nil {
+		return this.rollbackStep(step, "RunMulti: Failed to log "+msg.Tr_Id()+": "+err.Error())
+	}
+
+	ok, peers, _ := this.tm.requestPhase(msg, targets, this.nic, isReplicate, deadlineOf(msg, this.nic.Resources()))
+	prepared, errMsg := succeededTargetsOf(peers, targets)
+	for target, peerErr := range peers {
+		if peerErr == requests.TimeoutError {
+			prepared[target] = targets[target]
+		}
+	}
+	step.prepared = prepared
+	if !ok {
+		return this.rollbackStep(step, "RunMulti: Failed to prepare "+msg.ServiceName()+
+			" area "+strconv.Itoa(int(msg.ServiceArea()))+": "+errMsg)
+	}
+	this.tm.mtx.Lock()
+	this.tm.batchSteps[msg.Tr_Id()] = step
+	this.tm.mtx.Unlock()
+	msg.SetTr_State(ifs.Running)
+	return L8TransactionFor(msg)
+}
+
+// commitStep logs the commit of an operation of a decided batch and commits it on the
+// targets that prepared it. The operation is never rolled back once the batch is decided,
+// the targets that miss the commit are recorded as lagging for repair.
+func (this *ServiceTransactions) commitStep(step *multiStep) ifs.IElements {
+	msg := step.msg
+	msg.SetTr_State(ifs.Committed)
+	err := this.tm.recordTransition(msg, false, false, nil, this.nic)
+	if err != nil {
+		return stepFailed(msg, "RunMulti: Failed to log the commit of "+msg.Tr_Id()+": "+err.Error())
+	}
+	committedTargets, peers := this.commitOn(msg, step.prepared, step.isReplicate, deadlineOf(msg, this.nic.Resources()))
+	keys := this.keysOf(msg)
+	dropped := this.tm.lagging.add(msg, keys, committedTargets, missedTargetsOf(step.targets, committedTargets),
+		step.isReplicate, peers)
+	if dropped > 0 {
+		this.nic.Resources().Logger().Warning("RunMulti: dropped ", dropped, " lagging peer records of ",
+			msg.ServiceName(), " area ", msg.ServiceArea(), ", their peers need a full resync")
+	}
+	this.tm.divergent.committed(msg, this.tm.epochOf(msg, this.nic), keys, committedTargets)
+	this.tm.metrics.ran(msg, true, step.running, step.running)
+
+	msg.SetTr_State(ifs.Cleanup)
+	this.tm.requestPhase(msg, step.targets, this.nic, step.isReplicate, time.Now().Add(timeoutOf(msg, this.nic.Resources())))
+	this.tm.recordTransition(msg, false, false, nil, this.nic)
+	this.tm.forgetStep(msg.Tr_Id())
+	msg.SetTr_State(ifs.Committed)
+	return L8TransactionFor(msg)
+}
+
+// rollbackStep rolls an operation of a batch back on the targets that prepared it,
+// logging the rollback first, and answers that it failed.
+func (this *ServiceTransactions) rollbackStep(step *multiStep, errMsg string) ifs.IElements {
+	msg := step.msg
+	msg.SetTr_State(ifs.Rollback)
+	this.tm.recordTransition(msg, false, false, nil, this.nic)
+	this.tm.metrics.rolledBack(msg)
+	this.tm.requestPhase(msg, step.prepared, this.nic, step.isReplicate, time.Now().Add(timeoutOf(msg, this.nic.Resources())))
+
+	msg.SetTr_State(ifs.Failed)
+	msg.SetTr_ErrMsg(errMsg)
+	this.tm.recordTransition(msg, false, false, nil, this.nic)
+	this.tm.metrics.ran(msg, false, step.running, step.running)
+	this.tm.forgetStep(msg.Tr_Id())
+	return L8TransactionFor(msg)
+}
+
+// stepOf returns the prepared operation of a batch this leader keeps. An operation this
+// leader does not keep, e.g. one a previous leader prepared, is resolved on the replicas of
+// its key, for a replicated service, or on all the participants, as any of them may hold it.
+func (this *ServiceTransactions) stepOf(msg *ifs.Message) (*multiStep, error) {
+	this.tm.mtx.Lock()
+	step, ok := this.tm.batchSteps[msg.Tr_Id()]
+	this.tm.mtx.Unlock()
+	if ok {
+		return step, nil
+	}
+	targets, isReplicate, err := this.targetsOf(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &multiStep{msg: msg, targets: targets, prepared: targets, isReplicate: isReplicate, running: time.Now()}, nil
+}
+
+// forgetStep drops a batch operation this leader resolved.
+func (this *TransactionManager) forgetStep(trId string) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	delete(this.batchSteps, trId)
+}
+
+// stepFailed marks an operation of a batch as failed, without recording it, as its leader
+// did not run the phase.
+func stepFailed(msg *ifs.Message, errMsg string) ifs.IElements {
+	msg.SetTr_State(ifs.Failed)
+	msg.SetTr_ErrMsg(errMsg)
+	return L8TransactionFor(msg)
+}
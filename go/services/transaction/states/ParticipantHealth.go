@@ -0,0 +1,273 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/saichler/l8services/go/services/transaction/requests"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+// Limits of the participant health tracking.
+const (
+	unhealthyAfter    = 3                // Consecutive failures after which a participant is excluded
+	exclusionPeriod   = 10 * time.Second // Time an unhealthy participant is first excluded for
+	maxExclusion      = 5 * time.Minute  // Longest exclusion, doubled on every failed probe
+	probeTimeout      = 5                // Seconds a probe waits for the participant
+	latencySmoothing  = 0.2              // Weight of the last sample in the latency average
+	excludedErrorText = "Participant is excluded as unhealthy"
+)
+
+// ParticipantScore is a snapshot of the health of a participant, as seen by this node
+// from the responses to the transactions it coordinated. An excluded participant whose
+// exclusion expired stays excluded until it answers a probe.
+type ParticipantScore struct {
+	Node          string
+	Successes     int64
+	Failures      int64
+	SuccessRate   float64
+	Latency       time.Duration
+	LastError     string
+	LastErrorTime time.Time
+	Excluded      bool
+	ExcludedUntil time.Time
+}
+
+// participantHealth accumulates the responses of a participant.
+type participantHealth struct {
+	successes     int64
+	failures      int64
+	consecutive   int
+	latency       time.Duration
+	lastError     string
+	lastErrorTime time.Time
+	excludedUntil time.Time
+	exclusion     time.Duration
+	probing       bool
+	serviceName   string // The last service the participant was asked for, to probe it
+	serviceArea   byte
+}
+
+// ParticipantHealth scores the participants by their responses to the transaction phases.
+// A participant that did not respond to several phases in a row is excluded from the
+// commit targets and the replica picks. Once its exclusion expires it is probed, and
+// re-admitted if it responds, or excluded again for twice as long if it does not. A
+// re-admitted participant is repaired of the transactions it missed while excluded.
+type ParticipantHealth struct {
+	nodes      map[string]*participantHealth
+	readmitted func(node string, vnic ifs.IVNic)
+	mtx        *sync.Mutex
+}
+
+// newParticipantHealth creates an empty participant health record, calling readmitted
+// when a participant is re-admitted.
+func newParticipantHealth(readmitted func(node string, vnic ifs.IVNic)) *ParticipantHealth {
+	ph := &ParticipantHealth{}
+	ph.nodes = make(map[string]*participantHealth)
+	ph.readmitted = readmitted
+	ph.mtx = &sync.Mutex{}
+	return ph
+}
+
+// record scores the participants by the results of a transaction phase. A participant
+// that responded, even with a failure of the transaction, is healthy, one that did not
+// respond before the deadline failed.
+func (this *ParticipantHealth) record(msg *ifs.Message, peers map[string]string, latencies map[string]time.Duration) {
+	now := time.Now()
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	for node, errMsg := range peers {
+		ph := this.healthOf(node)
+		ph.serviceName = msg.ServiceName()
+		ph.serviceArea = msg.ServiceArea()
+		latency, responded := latencies[node]
+		if responded && errMsg != requests.TimeoutError {
+			ph.successes++
+			ph.consecutive = 0
+			if ph.latency == 0 {
+				ph.latency = latency
+			} else {
+				ph.latency += time.Duration(latencySmoothing * float64(latency-ph.latency))
+			}
+			continue
+		}
+		ph.failures++
+		ph.consecutive++
+		ph.lastError = errMsg
+		ph.lastErrorTime = now
+		if ph.consecutive >= unhealthyAfter && !ph.excluded(now) {
+			ph.exclude(now)
+		}
+	}
+}
+
+// healthOf returns the health of a participant, creating it on first use. The caller
+// holds the lock.
+func (this *ParticipantHealth) healthOf(node string) *participantHealth {
+	ph, ok := this.nodes[node]
+	if !ok {
+		ph = &participantHealth{}
+		this.nodes[node] = ph
+	}
+	return ph
+}
+
+// excluded returns true if the participant is excluded at the given time.
+func (this *participantHealth) excluded(now time.Time) bool {
+	return now.Before(this.excludedUntil)
+}
+
+// exclude excludes the participant, for twice as long as the last time, up to the limit.
+func (this *participantHealth) exclude(now time.Time) {
+	if this.exclusion == 0 {
+		this.exclusion = exclusionPeriod
+	} else if this.exclusion < maxExclusion {
+		this.exclusion *= 2
+		if this.exclusion > maxExclusion {
+			this.exclusion = maxExclusion
+		}
+	}
+	this.excludedUntil = now.Add(this.exclusion)
+}
+
+// Exclude excludes a participant for the given period, as if it was found unhealthy, for
+// example to drain it. Once the period expires it is probed on the service before it is
+// re-admitted.
+func (this *ParticipantHealth) Exclude(serviceName string, serviceArea byte, node string, period time.Duration) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	ph := this.healthOf(node)
+	ph.serviceName = serviceName
+	ph.serviceArea = serviceArea
+	ph.exclusion = period
+	ph.excludedUntil = time.Now().Add(period)
+}
+
+// Excluded returns true if the participant is excluded as unhealthy. A participant whose
+// exclusion expired stays excluded until a probe succeeds, which is started here when a
+// vnic is given.
+func (this *ParticipantHealth) Excluded(node string, vnic ifs.IVNic) bool {
+	now := time.Now()
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	ph, ok := this.nodes[node]
+	if !ok || ph.exclusion == 0 {
+		return false
+	}
+	if vnic != nil && !ph.excluded(now) && !ph.probing {
+		ph.probing = true
+		go this.probe(node, ph.serviceName, ph.serviceArea, vnic)
+	}
+	return true
+}
+
+// probe asks the participant for its in-doubt transactions of the service, a request it
+// answers without changing any state, and re-admits it if it responds, repairing it of
+// the transactions it missed.
+func (this *ParticipantHealth) probe(node string, serviceName string, serviceArea byte, vnic ifs.IVNic) {
+	query := &l8services.L8Transaction{State: int32(ifs.Running)}
+	resp := vnic.Request(node, serviceName, serviceArea, ifs.GET, query, probeTimeout)
+	if resp != nil && resp.Error() == nil {
+		this.readmit(node, vnic)
+		return
+	}
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	ph := this.healthOf(node)
+	ph.probing = false
+	if resp != nil && resp.Error() != nil {
+		ph.lastError = resp.Error().Error()
+	} else {
+		ph.lastError = requests.TimeoutError
+	}
+	ph.lastErrorTime = time.Now()
+	ph.exclude(time.Now())
+}
+
+// readmit re-admits a participant that answered its probe, then repairs it of the
+// transactions committed without it.
+func (this *ParticipantHealth) readmit(node string, vnic ifs.IVNic) {
+	this.mtx.Lock()
+	ph := this.healthOf(node)
+	ph.probing = false
+	ph.consecutive = 0
+	ph.exclusion = 0
+	ph.excludedUntil = time.Time{}
+	this.mtx.Unlock()
+	vnic.Resources().Logger().Info("ParticipantHealth: re-admitting participant ", node)
+	if this.readmitted != nil {
+		this.readmitted(node, vnic)
+	}
+}
+
+// split separates the excluded participants from the targets of a transaction. If all
+// of them are excluded none is, a transaction is better tried than not.
+func (this *ParticipantHealth) split(targets map[string]byte, vnic ifs.IVNic) (map[string]byte, map[string]byte) {
+	healthy := make(map[string]byte, len(targets))
+	excluded := make(map[string]byte)
+	for node, replica := range targets {
+		if this.Excluded(node, vnic) {
+			excluded[node] = replica
+		} else {
+			healthy[node] = replica
+		}
+	}
+	if len(healthy) == 0 {
+		return targets, map[string]byte{}
+	}
+	return healthy, excluded
+}
+
+// Scores returns the health of every participant seen by this node, sorted by node.
+func (this *ParticipantHealth) Scores() []*ParticipantScore {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	result := make([]*ParticipantScore, 0, len(this.nodes))
+	for node, ph := range this.nodes {
+		score := &ParticipantScore{Node: node, Successes: ph.successes, Failures: ph.failures,
+			Latency: ph.latency, LastError: ph.lastError, LastErrorTime: ph.lastErrorTime,
+			Excluded: ph.exclusion != 0, ExcludedUntil: ph.excludedUntil}
+		if total := ph.successes + ph.failures; total > 0 {
+			score.SuccessRate = float64(ph.successes) / float64(total)
+		}
+		result = append(result, score)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Node < result[j].Node })
+	return result
+}
+
+// ParticipantHealth returns the health record of the participants of this node's transactions.
+func (this *TransactionManager) ParticipantHealth() *ParticipantHealth {
+	return this.health
+}
+
+// repairReadmitted repairs a re-admitted participant of the transactions it missed, on
+// the services it lags on that this node leads.
+func (this *TransactionManager) repairReadmitted(node string, vnic ifs.IVNic) {
+	localUuid := vnic.Resources().SysConfig().LocalUuid
+	for _, lp := range this.lagging.servicesOf(node) {
+		if vnic.Resources().Services().GetLeader(lp.ServiceName, lp.ServiceArea) != localUuid {
+			continue
+		}
+		repaired := this.Repair(lp.ServiceName, lp.ServiceArea, vnic)
+		if repaired > 0 {
+			vnic.Resources().Logger().Info("ParticipantHealth: repaired ", repaired, " records of ", lp.ServiceName,
+				" area ", lp.ServiceArea, " on re-admitting ", node)
+		}
+	}
+}
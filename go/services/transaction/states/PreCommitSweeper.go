@@ -0,0 +1,171 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// Bounds of the interval between two sweeps of the stale pre-commit entries.
+const (
+	minSweepPeriod = time.Second
+	maxSweepPeriod = time.Minute
+)
+
+// stalePreCommit is a pre-commit entry that outlived the service's pre-commit TTL.
+type stalePreCommit struct {
+	trId    string
+	action  ifs.Action
+	applied bool
+}
+
+// sweepPreCommit is the background goroutine that periodically resolves the pre-commit
+// entries that are older than the service's pre-commit TTL, until the service shuts down.
+func (this *ServiceTransactions) sweepPreCommit() {
+	for {
+		ttl := agreement.For(this.nic.Resources(), this.serviceName, this.serviceArea).PreCommitTTL()
+		select {
+		case <-this.stop:
+			return
+		case <-time.After(sweepPeriodOf(ttl)):
+		}
+		if ttl > 0 {
+			this.sweep(ttl)
+		}
+	}
+}
+
+// sweepPeriodOf returns the interval between two sweeps for a pre-commit TTL.
+func sweepPeriodOf(ttl time.Duration) time.Duration {
+	period := ttl / 4
+	if period < minSweepPeriod {
+		period = minSweepPeriod
+	}
+	if period > maxSweepPeriod {
+		period = maxSweepPeriod
+	}
+	return period
+}
+
+// sweep resolves the pre-commit entries older than the ttl, that the leader never cleaned
+// up or rolled back, e.g. because it failed. The leader is asked for the outcome of each
+// of them. A committed transaction is discarded, one that failed is rolled back locally,
+// and one the leader does not know is discarded if this participant applied it and
+// rolled back otherwise. Transactions still in progress, or that cannot be checked as
+// there is no reachable leader, are left for the next sweep. Returns the number of
+// swept entries.
+func (this *ServiceTransactions) sweep(ttl time.Duration) int {
+	swept := 0
+	for _, stale := range this.staleOf(ttl) {
+		decision, ok := this.leaderDecisionOf(stale.trId)
+		if !ok {
+			continue
+		}
+		committed := stale.applied
+		switch decision {
+		case ifs.Committed, ifs.Cleanup:
+			//Applied here or missed and recorded by the leader as lagging, for repair
+			committed = true
+		case ifs.Failed, ifs.Rollback:
+			committed = false
+		case ifs.NotATransaction:
+			//Unknown to the leader, so it is kept only if this participant applied it
+		default:
+			continue
+		}
+
+		var msg *ifs.Message
+		if committed {
+			msg = this.localPhaseOf(stale.trId, stale.action, ifs.Cleanup)
+			this.cleanupInternal(msg)
+			this.nic.Resources().Logger().Info("TransactionSweeper: discarded orphaned pre-commit of ", stale.trId)
+		} else {
+			msg = this.localPhaseOf(stale.trId, stale.action, ifs.Rollback)
+			this.rollbackInternal(msg)
+			this.nic.Resources().Logger().Info("TransactionSweeper: rolled back orphaned pre-commit of ", stale.trId)
+		}
+		this.tm.metrics.orphanSwept(msg, !committed)
+		swept++
+	}
+	return swept
+}
+
+// localPhaseOf returns the message of a phase this participant runs by itself on a
+// transaction it holds in pre-commit.
+func (this *ServiceTransactions) localPhaseOf(trId string, action ifs.Action, state ifs.TransactionState) *ifs.Message {
+	msg := &ifs.Message{}
+	msg.SetSource(this.nic.Resources().SysConfig().LocalUuid)
+	msg.SetServiceName(this.serviceName)
+	msg.SetServiceArea(this.serviceArea)
+	msg.SetAction(action)
+	msg.SetTr_Id(trId)
+	msg.SetTr_State(state)
+	return msg
+}
+
+// staleOf returns the pre-commit entries that were prepared before the ttl.
+func (this *ServiceTransactions) staleOf(ttl time.Duration) []*stalePreCommit {
+	this.preCommitMtx.Lock()
+	defer this.preCommitMtx.Unlock()
+	result := make([]*stalePreCommit, 0)
+	for trId, prepared := range this.prepared {
+		if time.Since(prepared.since) > ttl {
+			result = append(result, &stalePreCommit{trId: trId, action: prepared.action, applied: prepared.applied})
+		}
+	}
+	return result
+}
+
+// leaderDecisionOf asks the leader of the service for its decision on a transaction.
+// Returns NotATransaction if the leader does not know it, and false if there is no
+// leader or it could not be reached, so an unreachable leader is never taken as one
+// that does not know the transaction.
+func (this *ServiceTransactions) leaderDecisionOf(trId string) (ifs.TransactionState, bool) {
+	leader := this.nic.Resources().Services().GetLeader(this.serviceName, this.serviceArea)
+	if leader == "" {
+		return ifs.NotATransaction, false
+	}
+	if leader == this.nic.Resources().SysConfig().LocalUuid {
+		return this.tm.decisionOf(trId), true
+	}
+	timeout := agreement.For(this.nic.Resources(), this.serviceName, this.serviceArea).TransactionTimeout()
+	resp := this.nic.Request(leader, this.serviceName, this.serviceArea, ifs.GET,
+		&l8svcs.L8DecisionQuery{TrId: trId}, int(timeout/time.Second))
+	if resp == nil || resp.Error() != nil {
+		return ifs.NotATransaction, false
+	}
+	decision, ok := resp.Element().(*l8svcs.L8InDoubtTransaction)
+	if !ok {
+		return ifs.NotATransaction, false
+	}
+	return ifs.TransactionState(decision.State), true
+}
+
+// SweepPreCommit resolves, right away, the pre-commit entries of a service on this
+// participant that are older than the service's pre-commit TTL. Returns the number
+// of swept entries.
+func (this *TransactionManager) SweepPreCommit(serviceName string, serviceArea byte, vnic ifs.IVNic) int {
+	ttl := agreement.For(vnic.Resources(), serviceName, serviceArea).PreCommitTTL()
+	if ttl <= 0 {
+		return 0
+	}
+	probe := &ifs.Message{}
+	probe.SetServiceName(serviceName)
+	probe.SetServiceArea(serviceArea)
+	return this.transactionsOf(probe, vnic).sweep(ttl)
+}
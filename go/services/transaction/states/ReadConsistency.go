@@ -0,0 +1,208 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/saichler/l8bus/go/overlay/protocol"
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+	"google.golang.org/protobuf/proto"
+)
+
+// Read sends a GET to a transactional service with the given consistency. A strong or
+// session read is sent to the leader, an eventual read is served by this node if it is a
+// participant of the service, or by another participant otherwise. A session read carries
+// the id of the caller's last transaction on the service, without one it reads strong.
+func (this *TransactionManager) Read(serviceName string, serviceArea byte, filter interface{},
+	consistency agreement.ReadConsistency, sessionTrId string, vnic ifs.IVNic) ifs.IElements {
+	read, err := NewConsistentRead(filter, consistency, sessionTrId)
+	if err != nil {
+		return object.NewError("Read: " + err.Error())
+	}
+	op := &TransactionOperation{ServiceName: serviceName, ServiceArea: serviceArea, Action: ifs.GET, Elements: object.New(nil, read)}
+	msg, err := newOperationMessage(ifs.NewUuid(), op, vnic.Resources())
+	if err != nil {
+		return object.NewError("Read: " + err.Error())
+	}
+	msg.SetTr_State(ifs.Created)
+
+	local := vnic.Resources().SysConfig().LocalUuid
+	dest := vnic.Resources().Services().GetLeader(serviceName, serviceArea)
+	if consistency == agreement.ReadEventual {
+		dest = participantOf(serviceName, serviceArea, dest, vnic)
+	}
+	if dest == "" {
+		return object.NewError("Read: no leader for service " + serviceName)
+	}
+	if dest == local {
+		return this.transactionsOf(msg, vnic).read(msg)
+	}
+	return vnic.Forward(msg, dest)
+}
+
+// NewConsistentRead wraps the filter of a GET with the consistency it asks for, so a client
+// sending the GET to a transactional service overrides the service's read consistency.
+// For a session read, sessionTrId is the id of the caller's last transaction on the service.
+func NewConsistentRead(filter interface{}, consistency agreement.ReadConsistency, sessionTrId string) (*l8svcs.L8ConsistentRead, error) {
+	pb, ok := filter.(proto.Message)
+	if !ok || pb == nil {
+		return nil, errors.New("filter of the read is not a protobuf message")
+	}
+	data, err := proto.Marshal(pb)
+	if err != nil {
+		return nil, err
+	}
+	return &l8svcs.L8ConsistentRead{Consistency: int32(consistency), SessionTrId: sessionTrId,
+		ElementType: reflect.ValueOf(pb).Elem().Type().Name(), ElementData: data}, nil
+}
+
+// readOf returns the filter of a GET, the consistency it asks for and, for a session read,
+// the caller's last transaction. A GET that is not an L8ConsistentRead reads with the
+// consistency of the service's agreement.
+func readOf(msg *ifs.Message, r ifs.IResources) (ifs.IElements, agreement.ReadConsistency, string, error) {
+	pb, err := protocol.ElementsOf(msg, r)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	read, ok := pb.Element().(*l8svcs.L8ConsistentRead)
+	if !ok {
+		return pb, agreement.For(r, msg.ServiceName(), msg.ServiceArea()).ReadConsistency(), "", nil
+	}
+	info, err := r.Registry().Info(read.ElementType)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	filter, err := info.NewInstance()
+	if err != nil {
+		return nil, 0, "", err
+	}
+	filterPb, ok := filter.(proto.Message)
+	if !ok {
+		return nil, 0, "", errors.New("filter type " + read.ElementType + " is not a protobuf message")
+	}
+	err = proto.Unmarshal(read.ElementData, filterPb)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	return object.New(nil, filter), agreement.ReadConsistency(read.Consistency), read.SessionTrId, nil
+}
+
+// participantOf returns this node if it is a participant of the service, otherwise any
+// other participant, or the given fallback if there is none.
+func participantOf(serviceName string, serviceArea byte, fallback string, vnic ifs.IVNic) string {
+	_, ok := vnic.Resources().Services().ServiceHandler(serviceName, serviceArea)
+	if ok {
+		return vnic.Resources().SysConfig().LocalUuid
+	}
+	for target := range vnic.Resources().Services().GetParticipants(serviceName, serviceArea) {
+		return target
+	}
+	return fallback
+}
+
+// read serves a GET. The leader first waits, up to the read's timeout, for what the read
+// must follow. A session read follows the caller's last transaction only, a strong read
+// follows all the transactions queued before it. An eventual read, and a read served by
+// another participant, is served right away from the node's own cache.
+func (this *ServiceTransactions) read(msg *ifs.Message) ifs.IElements {
+	pb, consistency, sessionTrId, err := readOf(msg, this.nic.Resources())
+	if err != nil {
+		msg.SetTr_State(ifs.Failed)
+		msg.SetTr_ErrMsg("ReadConsistency.read: Protocol Error: " + msg.Tr_Id() + " " + err.Error())
+		return L8TransactionFor(msg)
+	}
+	if consistency != agreement.ReadEventual &&
+		this.nic.Resources().Services().GetLeader(msg.ServiceName(), msg.ServiceArea()) == this.nic.Resources().SysConfig().LocalUuid {
+		deadline := time.Now().Add(timeoutOf(msg, this.nic.Resources()))
+		var ok bool
+		if consistency == agreement.ReadSession && sessionTrId != "" {
+			ok = this.awaitTransaction(sessionTrId, deadline)
+		} else {
+			ok = this.awaitEarlier(deadline)
+		}
+		if !ok {
+			msg.SetTr_State(ifs.Failed)
+			msg.SetTr_ErrMsg("ReadConsistency.read: Timed out waiting for earlier transactions of " + msg.ServiceName())
+			return L8TransactionFor(msg)
+		}
+	}
+	return this.get(pb, msg)
+}
+
+// awaitEarlier waits for the transactions admitted to the queue so far to finish.
+// Returns false if the deadline passed first.
+func (this *ServiceTransactions) awaitEarlier(deadline time.Time) bool {
+	this.mtx.Lock()
+	seq := this.admitted
+	this.mtx.Unlock()
+	return this.waitWhile(deadline, func() bool {
+		for _, tr := range this.queue {
+			if tr.seq <= seq {
+				return true
+			}
+		}
+		for _, trSeq := range this.inFlightTrs {
+			if trSeq <= seq {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// awaitTransaction waits for a transaction to finish. A transaction the leader did not get
+// yet, e.g. one still forwarded by the node that created it, is waited for too.
+// Returns false if the deadline passed first.
+func (this *ServiceTransactions) awaitTransaction(trId string, deadline time.Time) bool {
+	return this.waitWhile(deadline, func() bool {
+		if _, known := this.tm.history.Get(trId); !known {
+			return true
+		}
+		if _, ok := this.inFlightTrs[trId]; ok {
+			return true
+		}
+		for _, tr := range this.queue {
+			if tr.msg.Tr_Id() == trId {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// waitWhile waits, on the queue's condition, while pending returns true. pending is
+// called with the mutex held. Returns false if the deadline passed first.
+func (this *ServiceTransactions) waitWhile(deadline time.Time, pending func() bool) bool {
+	timer := time.AfterFunc(time.Until(deadline), func() {
+		this.mtx.Lock()
+		defer this.mtx.Unlock()
+		this.cond.Broadcast()
+	})
+	defer timer.Stop()
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	for pending() {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		this.cond.Wait()
+	}
+	return true
+}
@@ -17,68 +17,232 @@ import (
 	"bytes"
 	"strconv"
 	"sync"
+	"time"
 
+	"github.com/saichler/l8services/go/services/agreement"
 	"github.com/saichler/l8srlz/go/serialize/object"
 	"github.com/saichler/l8types/go/ifs"
 	"github.com/saichler/l8types/go/types/l8services"
 )
 
 // ServiceTransactions manages the transaction queue for a single service.
-// It processes transactions sequentially using a condition variable for
-// blocking queue operations and maintains pre-commit state for rollback.
+// It runs transactions on different keys in parallel, up to the service's
+// concurrency limit, while transactions on the same key run in queue order.
+// As a participant, it maintains the prepared changes, their key locks and
+// the pre-commit state for rollback.
 type ServiceTransactions struct {
-	mtx     *sync.Mutex
-	cond    *sync.Cond
-	queue   []*ifs.Message
-	running bool
-	nic     ifs.IVNic
+	mtx         *sync.Mutex
+	cond        *sync.Cond
+	queue       []*queuedTransaction
+	inFlight    map[string]bool
+	inFlightCnt int
+	inFlightTrs map[string]uint64
+	admitted    uint64
+	exclusive   bool
+	handingOff  bool
+	running     bool
+	stop        chan struct{}
+	nic         ifs.IVNic
+	tm          *TransactionManager
+	runMtx      *sync.RWMutex
+	serviceName string
+	serviceArea byte
 
 	preCommit    map[string]interface{}
+	absent       map[string]ifs.IElements
+	prepared     map[string]*preparedTransaction
+	locks        map[string]string
 	preCommitMtx *sync.Mutex
 }
 
+// queuedTransaction is a queued transaction message, the keys of the elements it changes
+// and the deadline by which it must complete. No keys means the keys are unknown, so the
+// transaction runs exclusively. The sequence orders it among the transactions admitted
+// to the queue, for the reads that must follow it.
+type queuedTransaction struct {
+	msg      *ifs.Message
+	seq      uint64
+	keys     []string
+	queued   time.Time
+	deadline time.Time
+}
+
 // newServiceTransactions creates a new transaction queue and starts its processor.
-func newServiceTransactions(nic ifs.IVNic) *ServiceTransactions {
+func newServiceTransactions(tm *TransactionManager, serviceName string, serviceArea byte, nic ifs.IVNic) *ServiceTransactions {
 	serviceTransactions := &ServiceTransactions{}
 	serviceTransactions.mtx = &sync.Mutex{}
 	serviceTransactions.cond = sync.NewCond(serviceTransactions.mtx)
-	serviceTransactions.queue = make([]*ifs.Message, 0)
+	serviceTransactions.queue = make([]*queuedTransaction, 0)
+	serviceTransactions.inFlight = make(map[string]bool)
+	serviceTransactions.inFlightTrs = make(map[string]uint64)
 	serviceTransactions.running = true
+	serviceTransactions.stop = make(chan struct{})
 	serviceTransactions.nic = nic
+	serviceTransactions.tm = tm
+	serviceTransactions.runMtx = &sync.RWMutex{}
+	serviceTransactions.serviceName = serviceName
+	serviceTransactions.serviceArea = serviceArea
 	serviceTransactions.preCommitMtx = &sync.Mutex{}
 	serviceTransactions.preCommit = map[string]interface{}{}
+	serviceTransactions.absent = map[string]ifs.IElements{}
+	serviceTransactions.prepared = map[string]*preparedTransaction{}
+	serviceTransactions.locks = map[string]string{}
 
 	go serviceTransactions.processTransactions()
+	go serviceTransactions.sweepPreCommit()
+	go serviceTransactions.repairLagging()
 	return serviceTransactions
 }
 
-// Next blocks until a transaction is available and returns it.
+// maxConcurrentTransactions returns how many transactions of the service may run in
+// parallel, as set on this node's agreement of the service.
+func (this *ServiceTransactions) maxConcurrentTransactions() int {
+	return agreement.For(this.nic.Resources(), this.serviceName, this.serviceArea).MaxConcurrentTransactions()
+}
+
+// Next blocks until a transaction can run and returns it, marking its key as in flight.
 // Returns nil if the service is shutting down.
-func (this *ServiceTransactions) Next() *ifs.Message {
+func (this *ServiceTransactions) Next() *queuedTransaction {
 	this.mtx.Lock()
 	defer this.mtx.Unlock()
-	for this.running && len(this.queue) == 0 {
+	for {
+		if !this.running {
+			return nil
+		}
+		tr := this.nextRunnable()
+		if tr != nil {
+			return tr
+		}
 		this.cond.Wait()
 	}
+}
 
-	if !this.running {
+// nextRunnable removes and returns the first queued transaction that may run now, or nil.
+// A transaction may run if the concurrency limit was not reached and no earlier transaction
+// on any of its keys is still queued or in flight. A transaction with unknown keys waits
+// for everything before it and blocks everything after it. Nothing runs while the queue
+// is handed off to a new leader. The caller holds the mutex.
+func (this *ServiceTransactions) nextRunnable() *queuedTransaction {
+	if this.exclusive || this.handingOff || this.inFlightCnt >= this.maxConcurrentTransactions() {
 		return nil
 	}
+	blocked := make(map[string]bool)
+	for i, tr := range this.queue {
+		if len(tr.keys) == 0 {
+			if this.inFlightCnt > 0 || len(blocked) > 0 {
+				return nil
+			}
+			this.exclusive = true
+		} else if this.isBusy(tr.keys, blocked) {
+			for _, key := range tr.keys {
+				blocked[key] = true
+			}
+			continue
+		} else {
+			for _, key := range tr.keys {
+				this.inFlight[key] = true
+			}
+		}
+		this.inFlightCnt++
+		this.inFlightTrs[tr.msg.Tr_Id()] = tr.seq
+		this.queue = append(this.queue[:i], this.queue[i+1:]...)
+		return tr
+	}
+	return nil
+}
 
-	msg := this.queue[0]
-	this.queue = this.queue[1:]
-	return msg
+// isBusy returns true if any of the keys is in flight or blocked by an earlier transaction.
+// The caller holds the mutex.
+func (this *ServiceTransactions) isBusy(keys []string, blocked map[string]bool) bool {
+	for _, key := range keys {
+		if this.inFlight[key] || blocked[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// done releases the keys of a finished transaction and wakes up the processor.
+func (this *ServiceTransactions) done(tr *queuedTransaction) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	if len(tr.keys) == 0 {
+		this.exclusive = false
+	}
+	for _, key := range tr.keys {
+		delete(this.inFlight, key)
+	}
+	this.inFlightCnt--
+	delete(this.inFlightTrs, tr.msg.Tr_Id())
+	this.cond.Broadcast()
 }
 
-// processTransactions is the background goroutine that dequeues and runs transactions.
+// processTransactions is the background goroutine that dequeues transactions
+// and runs each of them in its own goroutine.
 func (this *ServiceTransactions) processTransactions() {
-	for this.running {
+	for {
 		tr := this.Next()
 		if tr == nil {
-			continue
+			return
 		}
-		this.run(tr)
+		go this.runQueued(tr)
+	}
+}
+
+// shutdown stops the queue processor and the background sweeper and repairer, and fails
+// the transactions still queued.
+func (this *ServiceTransactions) shutdown() {
+	this.mtx.Lock()
+	if !this.running {
+		this.mtx.Unlock()
+		return
+	}
+	this.running = false
+	close(this.stop)
+	queued := this.queue
+	this.queue = make([]*queuedTransaction, 0)
+	this.cond.Broadcast()
+	this.mtx.Unlock()
+
+	for _, tr := range queued {
+		tr.msg.SetTr_State(ifs.Failed)
+		tr.msg.SetTr_ErrMsg("Service " + this.serviceName + " was deactivated")
+		this.tm.recordTransition(tr.msg, false, false, nil, this.nic)
+		this.nic.Reply(tr.msg, L8TransactionFor(tr.msg))
+	}
+}
+
+// runQueued runs a dequeued transaction. The run lock is shared by the service's own
+// transactions and held exclusively by a multi-service transaction.
+func (this *ServiceTransactions) runQueued(tr *queuedTransaction) {
+	defer this.done(tr)
+	this.runMtx.RLock()
+	defer this.runMtx.RUnlock()
+	running := time.Now()
+	endSpan := this.tm.metrics.startSpan(SpanRun, tr.msg)
+	committed := this.run(tr.msg, tr.deadline)
+	endSpan()
+	this.tm.metrics.ran(tr.msg, committed, tr.queued, running)
+}
+
+// depth returns the number of queued transactions and of transactions in flight.
+func (this *ServiceTransactions) depth() (int32, int32) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return int32(len(this.queue)), int32(this.inFlightCnt)
+}
+
+// keysOf returns the keys of the elements a transaction changes, or none if they are unknown.
+func (this *ServiceTransactions) keysOf(msg *ifs.Message) []string {
+	service, ok := this.nic.Resources().Services().ServiceHandler(msg.ServiceName(), msg.ServiceArea())
+	if !ok || service.TransactionConfig() == nil {
+		return []string{}
+	}
+	pb, err := this.preparedElementsOf(msg)
+	if err != nil {
+		return []string{}
 	}
+	return keysOf(pb, service.TransactionConfig(), this.nic.Resources())
 }
 
 // ServiceKey creates a unique key from service name and area for map indexing.
@@ -14,31 +14,66 @@
 package states
 
 import (
-	"context"
 	"runtime"
 	"time"
 
+	"github.com/saichler/l8services/go/services/agreement"
 	"github.com/saichler/l8types/go/ifs"
 )
 
 // createTransaction initializes a new transaction in the message if not already set.
-// Generates a unique ID and sets the state to Created.
-func createTransaction(msg *ifs.Message) {
+// Generates a unique ID, sets the state to Created and, unless the request carries its
+// own timeout, sets the service's SLA transaction timeout so it travels with the message.
+func createTransaction(msg *ifs.Message, r ifs.IResources) {
 	if msg.Tr_State() == ifs.NotATransaction {
 		msg.SetTr_Id(ifs.NewUuid())
 		msg.SetTr_State(ifs.Created)
 	}
+	if msg.Tr_Timeout() <= 0 {
+		timeout := agreement.For(r, msg.ServiceName(), msg.ServiceArea()).TransactionTimeout()
+		msg.SetTr_Timeout(int64(timeout / time.Second))
+	}
+}
+
+// timeoutOf returns the time a transaction has to complete, as carried by its message,
+// or the service's SLA transaction timeout if the message does not carry one.
+func timeoutOf(msg *ifs.Message, r ifs.IResources) time.Duration {
+	if msg.Tr_Timeout() > 0 {
+		return time.Duration(msg.Tr_Timeout()) * time.Second
+	}
+	return agreement.For(r, msg.ServiceName(), msg.ServiceArea()).TransactionTimeout()
+}
+
+// deadlineOf returns the time by which a transaction must complete, its timeout after it
+// was created. A transaction whose creation time is unknown, e.g. one replayed from the
+// transaction log, gets its timeout from now.
+func deadlineOf(msg *ifs.Message, r ifs.IResources) time.Time {
+	if msg.Tr_Created() > 0 {
+		return time.UnixMilli(msg.Tr_Created()).Add(timeoutOf(msg, r))
+	}
+	return time.Now().Add(timeoutOf(msg, r))
 }
 
 // Create initiates a new transaction by creating its ID and forwarding to the leader.
 // Returns immediately with Created state while the actual work continues asynchronously.
+// An eventual GET, asked for by the GET or by the service's agreement, is served by this
+// node right away.
 func (this *TransactionManager) Create(msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
 	//Create the new transaction inside the message
-	createTransaction(msg)
+	createTransaction(msg, vnic.Resources())
+	if msg.Action() == ifs.GET {
+		pb, consistency, _, err := readOf(msg, vnic.Resources())
+		if err == nil && consistency == agreement.ReadEventual {
+			return this.transactionsOf(msg, vnic).get(pb, msg)
+		}
+	}
+	this.metrics.created(msg)
+	endSpan := this.metrics.startSpan(SpanCreate, msg)
 
 	//To Keep the same flow, we are going to forward the transaction to the leader
 	//even if this is the leader
 	go func() {
+		defer endSpan()
 		runtime.Gosched()
 		leader := vnic.Resources().Services().GetLeader(msg.ServiceName(), msg.ServiceArea())
 		leaderResponse := vnic.Forward(msg, leader)
@@ -51,32 +86,9 @@ func (this *TransactionManager) Create(msg *ifs.Message, vnic ifs.IVNic) ifs.IEl
 }
 
 // Create2 is an alternative Create implementation with timeout support.
-// Uses context with 30-second timeout for the forwarding operation.
+//
+// Deprecated: Create bounds every transaction by the deadline set when it is created,
+// use it instead.
 func (this *TransactionManager) Create2(msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
-	createTransaction(msg)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-
-	go func() {
-		defer cancel()
-
-		select {
-		case <-ctx.Done():
-			vnic.Resources().Logger().Warning("Transaction forwarding cancelled")
-			return
-		default:
-			leader := vnic.Resources().Services().GetLeader(msg.ServiceName(), msg.ServiceArea())
-			if leader == "" {
-				vnic.Resources().Logger().Error("No leader found for service")
-				return
-			}
-
-			leaderResponse := vnic.Forward(msg, leader)
-			if err := vnic.Reply(msg, leaderResponse); err != nil {
-				vnic.Resources().Logger().Error("Failed to reply:", err)
-			}
-		}
-	}()
-
-	return L8TransactionFor(msg)
+	return this.Create(msg, vnic)
 }
@@ -14,23 +14,14 @@
 package states
 
 import (
-	"github.com/saichler/l8bus/go/overlay/protocol"
 	"github.com/saichler/l8services/go/services/replication"
 	"github.com/saichler/l8srlz/go/serialize/object"
 	"github.com/saichler/l8types/go/ifs"
 )
 
-// internalGet handles GET operations within a transaction, routing to replication
+// get handles GET operations within a transaction, routing to replication
 // handlers if the service has replication enabled.
-func (this *ServiceTransactions) internalGet(msg *ifs.Message) ifs.IElements {
-	pb, err := protocol.ElementsOf(msg, this.nic.Resources())
-	if err != nil {
-		msg.SetTr_State(ifs.Failed)
-		msg.SetTr_ErrMsg("T04_Commit.commitInternal: Protocol Error: " + msg.Tr_Id() + " " + err.Error())
-		this.nic.Resources().Logger().Debug(msg.Tr_Id() + " " + err.Error())
-		return L8TransactionFor(msg)
-	}
-
+func (this *ServiceTransactions) get(pb ifs.IElements, msg *ifs.Message) ifs.IElements {
 	service, _ := this.nic.Resources().Services().ServiceHandler(msg.ServiceName(), msg.ServiceArea())
 	if service.TransactionConfig().Replication() {
 		return this.replicationGet(pb, msg, service)
@@ -14,34 +14,51 @@
 package states
 
 import (
+	"errors"
+	"time"
+
 	"github.com/saichler/l8types/go/ifs"
 )
 
 // addTransaction adds a transaction to the queue after verifying this node is the leader.
-// Sets the transaction state to Queued and broadcasts to wake up the processor.
+// Sets the transaction state to Queued and broadcasts to wake up the processor. A
+// transaction that cannot be written to the transaction log is not queued.
 func (this *ServiceTransactions) addTransaction(msg *ifs.Message, vnic ifs.IVNic) error {
 	msg.SetTr_State(ifs.Queued)
 	if vnic.Resources().SysConfig().LocalUuid != vnic.Resources().Services().GetLeader(msg.ServiceName(), msg.ServiceArea()) {
 		return vnic.Resources().Logger().Error("A non leader has got the message")
 	}
+	if this.tm.fenced(msg, vnic.Resources().SysConfig().LocalUuid) {
+		return vnic.Resources().Logger().Error("A deposed leader has got the message")
+	}
+	err := this.tm.recordTransition(msg, false, true, nil, vnic)
+	if err != nil {
+		return errors.New("Failed to log transaction " + msg.Tr_Id() + ": " + err.Error())
+	}
+	now := time.Now()
+	tr := &queuedTransaction{msg: msg, keys: this.keysOf(msg), queued: now, deadline: deadlineOf(msg, vnic.Resources())}
 	this.mtx.Lock()
 	defer this.mtx.Unlock()
-	this.queue = append(this.queue, msg)
+	this.admitted++
+	tr.seq = this.admitted
+	this.queue = append(this.queue, tr)
 	this.cond.Broadcast()
 	return nil
 }
 
-// queueTransaction queues a transaction for processing. GET operations are handled
-// immediately without queueing; other operations are added to the queue.
+// queueTransaction queues a transaction for processing. GET operations are read
+// without queueing; other operations are added to the queue. An operation that cannot be
+// queued releases its idempotency key, so a later retry runs it again.
 func (this *ServiceTransactions) queueTransaction(msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
 	if msg.Action() == ifs.GET {
-		return this.internalGet(msg)
+		return this.read(msg)
 	}
 
 	err := this.addTransaction(msg, vnic)
 	if err != nil {
 		msg.SetTr_State(ifs.Failed)
 		msg.SetTr_ErrMsg(err.Error())
+		this.tm.idempotency.release(L8TransactionOf(msg))
 	}
 	return L8TransactionFor(msg)
 }
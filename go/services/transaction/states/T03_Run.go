@@ -14,80 +14,231 @@
 package states
 
 import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
 	"github.com/saichler/l8services/go/services/replication"
 	"github.com/saichler/l8services/go/services/transaction/requests"
 	"github.com/saichler/l8types/go/ifs"
 )
 
-// run executes the transaction by distributing it to all participants (or replicas).
-// Implements 2-phase commit: sends to all targets, handles failures with rollback,
-// and cleans up after successful commit.
-func (this *ServiceTransactions) run(msg *ifs.Message) {
-	this.nic.Resources().Logger().Debug("T02_Run.run: ", msg.Tr_Id(), " for ServiceName ", msg.ServiceName(), " area ", msg.ServiceArea())
+// run executes the transaction on its targets by 2-phase commit, prepare and vote, then
+// commit if enough targets voted yes. Returns true if it committed.
+func (this *ServiceTransactions) run(msg *ifs.Message, deadline time.Time) bool {
+	this.nic.Resources().Logger().Debug("T03_Run.run: ", msg.Tr_Id(), " for ServiceName ", msg.ServiceName(), " area ", msg.ServiceArea())
 	//Check if this is the leader, again, just to make sure
 	if this.nic.Resources().Services().GetLeader(msg.ServiceName(), msg.ServiceArea()) != this.nic.Resources().SysConfig().LocalUuid {
 		msg.SetTr_State(ifs.Failed)
 		msg.SetTr_ErrMsg("A non leader has got the message")
 		this.nic.Reply(msg, L8TransactionFor(msg))
-		return
+		return false
+	}
+
+	if !time.Now().Before(deadline) {
+		msg.SetTr_State(ifs.Failed)
+		msg.SetTr_ErrMsg("T03_Run.run: Transaction deadline expired while queued")
+		this.tm.recordTransition(msg, false, false, nil, this.nic)
+		this.nic.Reply(msg, L8TransactionFor(msg))
+		return false
 	}
 
 	//notify the originator that the transaction is running
 	msg.SetTr_State(ifs.Running)
+	err := this.tm.recordTransition(msg, false, false, nil, this.nic)
+	if err != nil {
+		msg.SetTr_State(ifs.Failed)
+		msg.SetTr_ErrMsg("T03_Run.run: Failed to log " + msg.Tr_Id() + " " + err.Error())
+		this.tm.recordTransition(msg, false, false, nil, this.nic)
+		this.nic.Reply(msg, L8TransactionFor(msg))
+		return false
+	}
 	this.nic.Reply(msg, L8TransactionFor(msg))
 
-	var targets map[string]byte
-	var err error
-	isReplicate := false
-	service, _ := this.nic.Resources().Services().ServiceHandler(msg.ServiceName(), msg.ServiceArea())
-	if service.TransactionConfig().Replication() {
-		//First see if there are already replication for this item
-		targets, err = replication.ReplicationFor(msg, this.nic.Resources(), service)
-		if err != nil {
-			msg.SetTr_State(ifs.Failed)
-			msg.SetTr_ErrMsg("T04_Commit.commitInternal: Protocol Error: " + msg.Tr_Id() + " " + err.Error())
-			this.nic.Resources().Logger().Debug(msg.Tr_Id() + " " + err.Error())
-			this.nic.Reply(msg, L8TransactionFor(msg))
-			return
-		}
-		//If there are no replications, take from the roundrobin.
-		if len(targets) == 0 {
-			targets = this.nic.Resources().Services().RoundRobinParticipants(msg.ServiceName(), msg.ServiceArea(),
-				service.TransactionConfig().ReplicationCount())
-		}
-		isReplicate = true
-	} else {
-		targets = this.nic.Resources().Services().GetParticipants(msg.ServiceName(), msg.ServiceArea())
-	}
-
-	this.nic.Resources().Logger().Debug("T02_Run.run: Sending to targets", msg.Tr_Id())
-	ok, peers := requests.RequestFromPeers(msg, targets, this.nic, isReplicate)
-	if !ok {
-		commitedTargets := make(map[string]byte)
-		errMsg := ""
-		for k, v := range peers {
-			if v == "" {
-				commitedTargets[k] = targets[k]
-			} else {
-				errMsg = v
+	targets, isReplicate, err := this.targetsOf(msg)
+	if err != nil {
+		msg.SetTr_State(ifs.Failed)
+		msg.SetTr_ErrMsg("T03_Run.run: Protocol Error: " + msg.Tr_Id() + " " + err.Error())
+		this.nic.Resources().Logger().Debug(msg.Tr_Id() + " " + err.Error())
+		this.tm.recordTransition(msg, false, false, nil, this.nic)
+		this.nic.Reply(msg, L8TransactionFor(msg))
+		return false
+	}
+
+	// The excluded targets count as voting no, so they never lower the votes required
+	required := agreement.For(this.nic.Resources(), msg.ServiceName(), msg.ServiceArea()).Required(len(targets))
+	targets, excluded := this.tm.health.split(targets, this.nic)
+	err = this.tm.recordTargets(msg, targets, isReplicate, this.nic)
+	if err != nil {
+		this.abort(msg, map[string]byte{}, isReplicate, "T03_Run.run: Failed to log the targets of "+msg.Tr_Id()+" "+err.Error())
+		return false
+	}
+	if len(targets) < required {
+		this.abort(msg, map[string]byte{}, isReplicate, "T03_Run.run: Not enough healthy targets, "+
+			strconv.Itoa(len(excluded))+" are excluded as unhealthy")
+		return false
+	}
+
+	//Phase 1, the targets validate and lock the change and vote on it
+	this.nic.Resources().Logger().Debug("T03_Run.run: Sending prepare to targets", msg.Tr_Id())
+	_, peers, latencies := this.tm.requestPhase(msg, targets, this.nic, isReplicate, deadline)
+	this.tm.health.record(msg, peers, latencies)
+	preparedTargets, errMsg := succeededTargetsOf(peers, targets)
+	if len(preparedTargets) < required {
+		// Release only those peers that voted yes, or may still do so after the deadline
+		for target, peerErr := range peers {
+			if peerErr == requests.TimeoutError {
+				preparedTargets[target] = targets[target]
 			}
 		}
+		this.abort(msg, preparedTargets, isReplicate, "T03_Run.run: Failed to prepare:"+errMsg)
+		return false
+	}
 
-		// Send Rollback only to those peers that commited successfully
-		msg.SetTr_State(ifs.Rollback)
-		requests.RequestFromPeers(msg, commitedTargets, this.nic, isReplicate)
+	//Phase 2, enough targets voted yes so log the decision and apply it on them. The logged
+	//decision stands, the targets that fail to apply it are retried once, then recorded as
+	//lagging for repair.
+	msg.SetTr_State(ifs.Committed)
+	err = this.tm.recordTransition(msg, false, false, nil, this.nic)
+	if err != nil {
+		//The decision is not logged, so it is not taken
+		this.abort(msg, preparedTargets, isReplicate, "T03_Run.run: Failed to log the commit of "+msg.Tr_Id()+" "+err.Error())
+		return false
+	}
+	committedTargets, commitPeers := this.commitOn(msg, preparedTargets, isReplicate, deadline)
+	for target, peerErr := range commitPeers {
+		peers[target] = peerErr
+	}
+	missed := missedTargetsOf(targets, committedTargets)
+	for target, replica := range excluded {
+		missed[target] = replica
+		peers[target] = excludedErrorText
+	}
+	keys := this.keysOf(msg)
+	dropped := this.tm.lagging.add(msg, keys, committedTargets, missed, isReplicate, peers)
+	if dropped > 0 {
+		this.nic.Resources().Logger().Warning("T03_Run.run: dropped ", dropped, " lagging peer records of ",
+			msg.ServiceName(), " area ", msg.ServiceArea(), ", their peers need a full resync")
+	}
+	this.tm.divergent.committed(msg, this.tm.epochOf(msg, this.nic), keys, committedTargets)
+	this.nic.Resources().Logger().Debug("T03_Run.run: Transaction committed: ", msg.Tr_Id())
+	msg.SetTr_State(ifs.Committed)
+	this.nic.Reply(msg, L8TransactionFor(msg))
 
-		msg.SetTr_State(ifs.Failed)
-		msg.SetTr_ErrMsg("T02_Run.run: Failed to commit:" + errMsg)
+	//cleanup, including the lagging targets so they release whatever they prepared
+	msg.SetTr_State(ifs.Cleanup)
+	this.tm.requestPhase(msg, targets, this.nic, isReplicate, time.Now().Add(timeoutOf(msg, this.nic.Resources())))
+	this.tm.recordTransition(msg, false, false, nil, this.nic)
+	return true
+}
 
-		this.nic.Reply(msg, L8TransactionFor(msg))
-		return
+// commitOn sends the logged commit of a transaction to the targets that prepared it, and
+// once more, with a fresh timeout, to the ones that missed it. Returns the targets that
+// committed and the result of every target.
+func (this *ServiceTransactions) commitOn(msg *ifs.Message, preparedTargets map[string]byte, isReplicate bool,
+	deadline time.Time) (map[string]byte, map[string]string) {
+	_, commitPeers, latencies := this.tm.requestPhase(msg, preparedTargets, this.nic, isReplicate, deadline)
+	this.tm.metrics.peersCommitted(msg, latencies)
+	this.tm.health.record(msg, commitPeers, latencies)
+	committedTargets, _ := succeededTargetsOf(commitPeers, preparedTargets)
+	retry := missedTargetsOf(preparedTargets, committedTargets)
+	if len(retry) > 0 {
+		msg.SetTr_State(ifs.Committed)
+		_, retryPeers, _ := this.tm.requestPhase(msg, retry, this.nic, isReplicate,
+			time.Now().Add(timeoutOf(msg, this.nic.Resources())))
+		for target, peerErr := range retryPeers {
+			commitPeers[target] = peerErr
+		}
+		committedTargets, _ = succeededTargetsOf(commitPeers, preparedTargets)
 	}
-	this.nic.Resources().Logger().Debug("T02_Run.run: Transaction committed: ", msg.Tr_Id())
 	msg.SetTr_State(ifs.Committed)
+	return committedTargets, commitPeers
+}
+
+// abort rolls the transaction back on the given targets and replies that it failed.
+// The rollback gets a fresh timeout, as the transaction's deadline may have expired.
+func (this *ServiceTransactions) abort(msg *ifs.Message, targets map[string]byte, isReplicate bool, errMsg string) {
+	msg.SetTr_State(ifs.Rollback)
+	this.tm.recordTransition(msg, false, false, nil, this.nic)
+	this.tm.metrics.rolledBack(msg)
+	this.tm.requestPhase(msg, targets, this.nic, isReplicate, time.Now().Add(timeoutOf(msg, this.nic.Resources())))
+
+	msg.SetTr_State(ifs.Failed)
+	msg.SetTr_ErrMsg(errMsg)
+	this.tm.recordTransition(msg, false, false, nil, this.nic)
+
 	this.nic.Reply(msg, L8TransactionFor(msg))
+}
 
-	//cleanup
-	msg.SetTr_State(ifs.Cleanup)
-	requests.RequestFromPeers(msg, targets, this.nic, isReplicate)
+// IHashPlacement is implemented by the services placing the replicas of a key by
+// consistent hashing of the key, for the services that select it on their agreement. The
+// replicas of a new key are placed by it, and the existing keys are moved to the replicas
+// it places them on once the participants change.
+type IHashPlacement interface {
+	HashParticipants(serviceName string, serviceArea byte, key string, replications int) map[string]byte
+}
+
+// targetsOf resolves the nodes a transaction is sent to. For replicated services these
+// are the replicas already holding the element's key, or a pick by the service's replica
+// placement for a new key, otherwise all the participants of the service. A replicated
+// service takes a single element per transaction.
+func (this *ServiceTransactions) targetsOf(msg *ifs.Message) (map[string]byte, bool, error) {
+	service, _ := this.nic.Resources().Services().ServiceHandler(msg.ServiceName(), msg.ServiceArea())
+	if !service.TransactionConfig().Replication() {
+		return this.nic.Resources().Services().GetParticipants(msg.ServiceName(), msg.ServiceArea()), false, nil
+	}
+	//Replicas are placed by the element's key, so a batch cannot be replicated as one unit
+	pb, err := this.preparedElementsOf(msg)
+	if err != nil {
+		return nil, true, err
+	}
+	if len(pb.Elements()) > 1 {
+		return nil, true, errors.New("batches of more than one element are not supported on replicated services")
+	}
+	//First see if there are already replication for this item
+	targets, err := replication.ReplicationFor(msg, this.nic.Resources(), service)
+	if err != nil {
+		return nil, true, err
+	}
+	//If there are no replications, place them by the key's hash or take from the roundrobin.
+	if len(targets) == 0 {
+		replications := service.TransactionConfig().ReplicationCount()
+		placement, _ := agreement.For(this.nic.Resources(), msg.ServiceName(), msg.ServiceArea()).ReplicaPlacement()
+		placer, ok := this.nic.Resources().Services().(IHashPlacement)
+		key := service.TransactionConfig().KeyOf(pb, this.nic.Resources())
+		if placement == agreement.PlacementConsistentHash && ok && key != "" {
+			targets = placer.HashParticipants(msg.ServiceName(), msg.ServiceArea(), key, replications)
+		} else {
+			targets = this.nic.Resources().Services().RoundRobinParticipants(msg.ServiceName(), msg.ServiceArea(),
+				replications)
+		}
+	}
+	return targets, true, nil
+}
+
+// succeededTargetsOf returns the targets that succeeded in the last phase, according to
+// the peers results, and the last error message reported by the ones that did not.
+func succeededTargetsOf(peers map[string]string, targets map[string]byte) (map[string]byte, string) {
+	commitedTargets := make(map[string]byte)
+	errMsg := ""
+	for k, v := range peers {
+		if v == "" {
+			commitedTargets[k] = targets[k]
+		} else {
+			errMsg = v
+		}
+	}
+	return commitedTargets, errMsg
+}
+
+// missedTargetsOf returns the targets that did not commit.
+func missedTargetsOf(targets, committedTargets map[string]byte) map[string]byte {
+	missed := make(map[string]byte)
+	for target, replica := range targets {
+		if _, ok := committedTargets[target]; !ok {
+			missed[target] = replica
+		}
+	}
+	return missed
 }
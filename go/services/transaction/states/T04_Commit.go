@@ -14,73 +14,114 @@
 package states
 
 import (
-	"github.com/saichler/l8bus/go/overlay/protocol"
 	"github.com/saichler/l8srlz/go/serialize/object"
 	"github.com/saichler/l8types/go/ifs"
 )
 
-// commitInternal performs the commit phase on a participant node.
-// Saves pre-commit state for rollback, then executes the transaction handler.
+// commitInternal performs the commit phase on a participant node, applying the change
+// it validated and locked during the prepare phase. The commit is logged before it is
+// applied. Committing an already applied transaction again, e.g. by a recovering leader,
+// is a no-op, and a commit arriving while another applies it waits for its outcome.
 func (this *ServiceTransactions) commitInternal(msg *ifs.Message) ifs.IElements {
 	if msg.Action() == ifs.Notify {
 		//_, err := services.Notify()
 		return nil
 	}
 
-	pb, err := protocol.ElementsOf(msg, this.nic.Resources())
-	if err != nil {
+	prepared, ok := this.startCommit(msg.Tr_Id())
+	if !ok {
 		msg.SetTr_State(ifs.Failed)
-		msg.SetTr_ErrMsg("T04_Commit.commitInternal: Protocol Error: " + msg.Tr_Id() + " " + err.Error())
-		this.nic.Resources().Logger().Debug(msg.Tr_Id() + " " + err.Error())
+		msg.SetTr_ErrMsg("T04_Commit.commitInternal: Transaction " + msg.Tr_Id() + " was not prepared")
+		this.nic.Resources().Logger().Debug(msg.Tr_ErrMsg())
 		return L8TransactionFor(msg)
 	}
 
-	if msg.Tr_IsReplica() {
-		pb = object.NewReplicaRequest(pb, msg.Tr_Replica())
+	msg.SetAction(prepared.action)
+	msg.SetTr_State(ifs.Committed)
+	if prepared.applied {
+		return L8TransactionFor(msg)
 	}
 
-	err = this.setPreCommitObject(pb, msg)
+	err := this.tm.recordApplying(msg, this.nic)
 	if err != nil {
+		this.endCommit(prepared, false)
 		msg.SetTr_State(ifs.Failed)
-		msg.SetTr_ErrMsg(err.Error())
-		this.nic.Resources().Logger().Debug(msg.Tr_Id() + " " + err.Error())
+		msg.SetTr_ErrMsg("T04_Commit.commitInternal: Log Error: " + msg.Tr_Id() + " " + err.Error())
 		return L8TransactionFor(msg)
 	}
 
 	this.nic.Resources().Logger().Debug("T04_Commit.commitInternal: Before Transaction Handle ", msg.Tr_Id())
-	resp := this.nic.Resources().Services().TransactionHandle(pb, msg.Action(), msg, this.nic)
+	resp := this.nic.Resources().Services().TransactionHandle(prepared.pb, msg.Action(), msg, this.nic)
 	if resp != nil && resp.Error() != nil {
-		this.preCommitMtx.Lock()
-		delete(this.preCommit, msg.Tr_Id())
-		this.preCommitMtx.Unlock()
+		//Keep the prepared state, the leader retries the commit or rolls back and releases it
+		this.endCommit(prepared, false)
 		msg.SetTr_State(ifs.Failed)
 		msg.SetTr_ErrMsg("T04_Commit.commitInternal: Handle Error: " + msg.Tr_Id() + " " + resp.Error().Error())
 		this.nic.Resources().Logger().Debug(msg.Tr_ErrMsg())
-
 		return L8TransactionFor(msg)
 	}
+	this.endCommit(prepared, true)
+
 	this.nic.Resources().Logger().Debug("T04_Commit.commitInternal: Transaction commited on node ",
 		this.nic.Resources().SysConfig().LocalUuid, " - ", msg.Tr_Id())
-	msg.SetTr_State(ifs.Committed)
+	this.tm.recordTransition(msg, true, false, nil, this.nic)
 	return L8TransactionFor(msg)
 }
 
+// startCommit returns the prepared transaction, marked as committing unless it was
+// already applied, after waiting for a commit applying it to finish. Returns false if
+// the transaction was not prepared.
+func (this *ServiceTransactions) startCommit(trId string) (*preparedTransaction, bool) {
+	this.preCommitMtx.Lock()
+	defer this.preCommitMtx.Unlock()
+	for {
+		prepared, ok := this.prepared[trId]
+		if !ok {
+			return nil, false
+		}
+		if prepared.committing == nil {
+			if !prepared.applied {
+				prepared.committing = make(chan struct{})
+			}
+			return prepared, true
+		}
+		committing := prepared.committing
+		this.preCommitMtx.Unlock()
+		<-committing
+		this.preCommitMtx.Lock()
+	}
+}
+
+// endCommit ends the commit of a prepared transaction, marking it as applied if it was.
+func (this *ServiceTransactions) endCommit(prepared *preparedTransaction, applied bool) {
+	this.preCommitMtx.Lock()
+	defer this.preCommitMtx.Unlock()
+	prepared.applied = applied
+	close(prepared.committing)
+	prepared.committing = nil
+}
+
 // setPreCommitObject saves the current state before committing for potential rollback.
-// For PUT/DELETE/PATCH, fetches the existing object; for POST, stores the new object.
+// For PUT/DELETE/PATCH, fetches the existing object of every element of the change, so a
+// batch is rolled back as a whole, and keeps the elements that do not exist yet so a
+// rollback of a PUT or PATCH deletes them; for POST, stores the new objects.
 func (this *ServiceTransactions) setPreCommitObject(pb ifs.IElements, msg *ifs.Message) error {
 
 	if msg.Action() == ifs.PUT ||
 		msg.Action() == ifs.DELETE ||
 		msg.Action() == ifs.PATCH {
-		//Get the object before performing the action so we could rollback
+		//Get the objects before performing the action so we could rollback
 		//if necessary.
-		resp := this.nic.Resources().Services().TransactionHandle(pb, ifs.GET, msg, this.nic)
-		if resp != nil && resp.Error() != nil {
-			return resp.Error()
+		resp, absent, err := this.preImagesOf(pb, msg)
+		if err != nil {
+			return err
 		}
 		this.preCommitMtx.Lock()
 		defer this.preCommitMtx.Unlock()
 		this.preCommit[msg.Tr_Id()] = resp
+		if absent != nil && msg.Action() != ifs.DELETE {
+			this.absent[msg.Tr_Id()] = absent
+		}
 	} else {
 		this.preCommitMtx.Lock()
 		defer this.preCommitMtx.Unlock()
@@ -88,3 +129,78 @@ func (this *ServiceTransactions) setPreCommitObject(pb ifs.IElements, msg *ifs.M
 	}
 	return nil
 }
+
+// preImagesOf fetches the existing object of every element of the change. A single
+// element is fetched as is, a batch is fetched element by element. The elements of the
+// change that do not exist are returned apart, or nil if they all exist.
+func (this *ServiceTransactions) preImagesOf(pb ifs.IElements, msg *ifs.Message) (ifs.IElements, ifs.IElements, error) {
+	elems := pb.Elements()
+	if len(elems) <= 1 {
+		resp := this.nic.Resources().Services().TransactionHandle(pb, ifs.GET, msg, this.nic)
+		if resp != nil && resp.Error() != nil {
+			return nil, nil, resp.Error()
+		}
+		if !hasElements(resp) {
+			return resp, pb, nil
+		}
+		return resp, nil, nil
+	}
+	preImages := make([]interface{}, 0, len(elems))
+	absent := make([]interface{}, 0)
+	for _, elem := range elems {
+		if elem == nil {
+			continue
+		}
+		var single ifs.IElements = object.New(nil, elem)
+		if msg.Tr_IsReplica() {
+			single = object.NewReplicaRequest(single, msg.Tr_Replica())
+		}
+		resp := this.nic.Resources().Services().TransactionHandle(single, ifs.GET, msg, this.nic)
+		if resp != nil && resp.Error() != nil {
+			return nil, nil, resp.Error()
+		}
+		if resp != nil && resp.Element() != nil {
+			preImages = append(preImages, resp.Element())
+		} else {
+			absent = append(absent, elem)
+		}
+	}
+	if len(absent) == 0 {
+		return object.New(nil, preImages), nil, nil
+	}
+	var absentElems ifs.IElements = object.New(nil, absent)
+	if msg.Tr_IsReplica() {
+		absentElems = object.NewReplicaRequest(absentElems, msg.Tr_Replica())
+	}
+	return object.New(nil, preImages), absentElems, nil
+}
+
+// hasElements returns true if the elements hold at least one element.
+func hasElements(pb ifs.IElements) bool {
+	if pb == nil {
+		return false
+	}
+	for _, elem := range pb.Elements() {
+		if elem != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// absentOf returns the elements of a transaction that did not exist before it, or nil.
+func (this *ServiceTransactions) absentOf(msg *ifs.Message) ifs.IElements {
+	this.preCommitMtx.Lock()
+	defer this.preCommitMtx.Unlock()
+	return this.absent[msg.Tr_Id()]
+}
+
+// preCommitOf returns the saved pre-commit state of a transaction, or nil if there is none.
+func (this *ServiceTransactions) preCommitOf(msg *ifs.Message) ifs.IElements {
+	this.preCommitMtx.Lock()
+	defer this.preCommitMtx.Unlock()
+	if _, ok := this.preCommit[msg.Tr_Id()]; !ok {
+		return nil
+	}
+	return this.preCommitObject(msg)
+}
@@ -0,0 +1,186 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"errors"
+	"time"
+
+	"github.com/saichler/l8bus/go/overlay/protocol"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// ITransactionValidator is an optional interface a transactional service handler can
+// implement to vote on a change during the prepare phase, before it is applied.
+// Returning an error votes no and aborts the transaction on all participants.
+type ITransactionValidator interface {
+	ValidateTransaction(pb ifs.IElements, action ifs.Action, vnic ifs.IVNic) error
+}
+
+// preparedTransaction is a change, of one or more elements, that a participant validated
+// and locked during the prepare phase, waiting for the leader's decision to apply or
+// release it as a whole. The action is kept so a leader that only knows the transaction
+// id, e.g. one resolving it after a failover, can still have it applied or reverted.
+// The coordinator is the leader that prepared it, asked for its decision after a failover.
+// Committing is set, and closed once done, while a commit applies it.
+type preparedTransaction struct {
+	pb          ifs.IElements
+	keys        []string
+	action      ifs.Action
+	applied     bool
+	committing  chan struct{}
+	since       time.Time
+	coordinator string
+}
+
+// prepareInternal performs the prepare phase on a participant node. It validates the
+// change, locks the keys of all its elements and saves their pre-commit state without
+// applying anything. A Running response votes yes, a Failed response votes no. A change
+// that cannot be written to the transaction log votes no.
+func (this *ServiceTransactions) prepareInternal(msg *ifs.Message) ifs.IElements {
+	if msg.Action() == ifs.Notify {
+		return nil
+	}
+
+	pb, err := this.preparedElementsOf(msg)
+	if err != nil {
+		return this.voteNo(msg, "T04_Prepare.prepareInternal: Protocol Error: "+msg.Tr_Id()+" "+err.Error())
+	}
+
+	service, _ := this.nic.Resources().Services().ServiceHandler(msg.ServiceName(), msg.ServiceArea())
+	keys := keysOf(pb, service.TransactionConfig(), this.nic.Resources())
+	err = this.lockKeys(keys, msg.Tr_Id())
+	if err != nil {
+		return this.voteNo(msg, "T04_Prepare.prepareInternal: "+err.Error())
+	}
+
+	validator, ok := service.(ITransactionValidator)
+	if ok {
+		err = validator.ValidateTransaction(pb, msg.Action(), this.nic)
+	}
+	if err == nil {
+		err = this.setPreCommitObject(pb, msg)
+	}
+	if err != nil {
+		this.preCommitMtx.Lock()
+		this.unlockKeys(keys, msg.Tr_Id())
+		this.preCommitMtx.Unlock()
+		return this.voteNo(msg, "T04_Prepare.prepareInternal: Validation Error: "+msg.Tr_Id()+" "+err.Error())
+	}
+
+	this.preCommitMtx.Lock()
+	coordinator := this.nic.Resources().Services().GetLeader(msg.ServiceName(), msg.ServiceArea())
+	this.prepared[msg.Tr_Id()] = &preparedTransaction{pb: pb, keys: keys, action: msg.Action(), since: time.Now(),
+		coordinator: coordinator}
+	this.preCommitMtx.Unlock()
+
+	//Write ahead the pre-commit snapshot so a restarted node can still roll it back
+	err = this.tm.recordPrepared(msg, coordinator, this.preCommitOf(msg), this.absentOf(msg), this.nic)
+	if err != nil {
+		this.preCommitMtx.Lock()
+		this.release(msg.Tr_Id())
+		this.preCommitMtx.Unlock()
+		return this.voteNo(msg, "T04_Prepare.prepareInternal: Log Error: "+msg.Tr_Id()+" "+err.Error())
+	}
+
+	this.nic.Resources().Logger().Debug("T04_Prepare.prepareInternal: Transaction prepared on node ",
+		this.nic.Resources().SysConfig().LocalUuid, " - ", msg.Tr_Id())
+	return L8TransactionFor(msg)
+}
+
+// preparedElementsOf extracts the transaction's elements, addressed to the message's replica if any.
+func (this *ServiceTransactions) preparedElementsOf(msg *ifs.Message) (ifs.IElements, error) {
+	pb, err := protocol.ElementsOf(msg, this.nic.Resources())
+	if err != nil {
+		return nil, err
+	}
+	if msg.Tr_IsReplica() {
+		pb = object.NewReplicaRequest(pb, msg.Tr_Replica())
+	}
+	return pb, nil
+}
+
+// voteNo marks the transaction as failed on this participant.
+func (this *ServiceTransactions) voteNo(msg *ifs.Message, errMsg string) ifs.IElements {
+	msg.SetTr_State(ifs.Failed)
+	msg.SetTr_ErrMsg(errMsg)
+	this.nic.Resources().Logger().Debug(errMsg)
+	return L8TransactionFor(msg)
+}
+
+// keysOf returns the keys of the elements of a change, in order and without duplicates.
+// Elements without a key, e.g. of a query, are skipped.
+func keysOf(pb ifs.IElements, config ifs.ITransactionConfig, r ifs.IResources) []string {
+	elems := pb.Elements()
+	if len(elems) <= 1 {
+		key := config.KeyOf(pb, r)
+		if key == "" {
+			return []string{}
+		}
+		return []string{key}
+	}
+	keys := make([]string, 0, len(elems))
+	seen := make(map[string]bool)
+	for _, elem := range elems {
+		if elem == nil {
+			continue
+		}
+		key := config.KeyOf(object.New(nil, elem), r)
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// lockKeys locks the element keys of a transaction until it is released, either all
+// of them or none if any is locked by another transaction.
+func (this *ServiceTransactions) lockKeys(keys []string, trId string) error {
+	this.preCommitMtx.Lock()
+	defer this.preCommitMtx.Unlock()
+	for _, key := range keys {
+		holder, ok := this.locks[key]
+		if ok && holder != trId {
+			return errors.New("Key " + key + " is locked by transaction " + holder)
+		}
+	}
+	for _, key := range keys {
+		this.locks[key] = trId
+	}
+	return nil
+}
+
+// unlockKeys releases the keys that are held by the transaction.
+// The caller must hold preCommitMtx.
+func (this *ServiceTransactions) unlockKeys(keys []string, trId string) {
+	for _, key := range keys {
+		if this.locks[key] == trId {
+			delete(this.locks, key)
+		}
+	}
+}
+
+// release drops all the participant state of a transaction and unlocks its keys.
+// The caller must hold preCommitMtx.
+func (this *ServiceTransactions) release(trId string) {
+	prepared, ok := this.prepared[trId]
+	if ok {
+		this.unlockKeys(prepared.keys, trId)
+	}
+	delete(this.prepared, trId)
+	delete(this.preCommit, trId)
+	delete(this.absent, trId)
+}
@@ -18,28 +18,60 @@ import (
 	"github.com/saichler/l8types/go/ifs"
 )
 
-// rollbackInternal reverts a committed transaction using the saved pre-commit state.
-// Converts the action type to its inverse operation (POST->DELETE, etc.).
+// IRestorableService is an optional interface a transactional service handler can
+// implement to write the pre-commit state of a rolled back transaction as is, bypassing
+// the checks of its regular writes, e.g. that the element's version is the current one.
+type IRestorableService interface {
+	Restore(pb ifs.IElements, action ifs.Action, vnic ifs.IVNic) ifs.IElements
+}
+
+// rollbackInternal aborts a transaction on a participant. A transaction that was only
+// prepared is released, one that was already applied is reverted using the saved
+// pre-commit state, converting the action type to its inverse (POST->DELETE, etc.).
+// The elements a PUT or PATCH created, as they did not exist before, are deleted.
 func (this *ServiceTransactions) rollbackInternal(msg *ifs.Message) ifs.IElements {
 
 	if msg.Action() == ifs.Notify {
 		return nil
 	}
 
-	this.setRollbackAction(msg)
-
 	this.preCommitMtx.Lock()
 	defer this.preCommitMtx.Unlock()
 
+	prepared, ok := this.prepared[msg.Tr_Id()]
+	if ok {
+		msg.SetAction(prepared.action)
+	}
+	if ok && !prepared.applied {
+		this.release(msg.Tr_Id())
+		this.tm.recordTransition(msg, true, false, nil, this.nic)
+		return L8TransactionFor(msg)
+	}
+
+	//Nothing was committed on this node, e.g. a rollback replayed by a recovering leader
+	if _, ok = this.preCommit[msg.Tr_Id()]; !ok {
+		this.release(msg.Tr_Id())
+		return L8TransactionFor(msg)
+	}
+
+	this.setRollbackAction(msg)
+	defer this.tm.recordTransition(msg, true, false, nil, this.nic)
+
 	elem := this.preCommitObject(msg)
-	resp := this.nic.Resources().Services().TransactionHandle(elem, msg.Action(), msg, this.nic)
+	absent := this.absent[msg.Tr_Id()]
+	var resp ifs.IElements
+	if hasElements(elem) || absent == nil {
+		resp = this.nic.Resources().Services().TransactionHandle(elem, msg.Action(), msg, this.nic)
+	}
+	if absent != nil && (resp == nil || resp.Error() == nil) {
+		resp = this.nic.Resources().Services().TransactionHandle(absent, ifs.DELETE, msg, this.nic)
+	}
+	this.release(msg.Tr_Id())
 	if resp != nil && resp.Error() != nil {
 		msg.SetTr_State(ifs.Failed)
 		msg.SetTr_ErrMsg("T05_Rollback.rollbackInternal: Handle Error: " + msg.Tr_Id() + " " + resp.Error().Error())
-		delete(this.preCommit, msg.Tr_Id())
 		return L8TransactionFor(msg)
 	}
-	delete(this.preCommit, msg.Tr_Id())
 	return L8TransactionFor(msg)
 }
 
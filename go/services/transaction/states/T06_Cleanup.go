@@ -17,14 +17,18 @@ import (
 	"github.com/saichler/l8types/go/ifs"
 )
 
-// cleanupInternal removes the pre-commit state after a successful transaction.
-// Called as the final phase to free memory used for rollback support.
+// cleanupInternal removes the pre-commit state and releases the key lock after a
+// successful transaction. Called as the final phase to free memory used for rollback support.
 func (this *ServiceTransactions) cleanupInternal(msg *ifs.Message) ifs.IElements {
 	if msg.Action() == ifs.Notify {
 		return nil
 	}
 	this.preCommitMtx.Lock()
 	defer this.preCommitMtx.Unlock()
-	delete(this.preCommit, msg.Tr_Id())
+	_, ok := this.prepared[msg.Tr_Id()]
+	this.release(msg.Tr_Id())
+	if ok {
+		this.tm.recordTransition(msg, true, false, nil, this.nic)
+	}
 	return L8TransactionFor(msg)
 }
@@ -0,0 +1,297 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// HandOff hands the queued transactions of a service over to the next leader when this
+// node resigns. It stops dequeuing, waits for the transactions in flight to finish, calls
+// resign to give up the leadership and get the new leader, and forwards the queued
+// transactions to it in queue order. If this node is elected again, or no leader was
+// elected, the transactions are queued back. Returns the number of handed off transactions.
+func (this *TransactionManager) HandOff(serviceName string, serviceArea byte, vnic ifs.IVNic, resign func() string) int {
+	probe := &ifs.Message{}
+	probe.SetServiceName(serviceName)
+	probe.SetServiceArea(serviceArea)
+	st := this.transactionsOf(probe, vnic)
+
+	queued := st.drain()
+	leader := resign()
+	if leader == "" || leader == vnic.Resources().SysConfig().LocalUuid {
+		st.resume(queued)
+		return 0
+	}
+	st.resume(nil)
+	for _, tr := range queued {
+		st.handOff(tr.msg, leader)
+	}
+	return len(queued)
+}
+
+// drain stops dequeuing transactions, waits for the ones in flight to finish and
+// removes and returns the queued ones.
+func (this *ServiceTransactions) drain() []*queuedTransaction {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.handingOff = true
+	for this.inFlightCnt > 0 {
+		this.cond.Wait()
+	}
+	queued := this.queue
+	this.queue = make([]*queuedTransaction, 0)
+	return queued
+}
+
+// resume puts the given transactions back at the head of the queue and resumes dequeuing.
+func (this *ServiceTransactions) resume(queued []*queuedTransaction) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.queue = append(queued, this.queue...)
+	this.handingOff = false
+	this.cond.Broadcast()
+}
+
+// handOff forwards a transaction this node does not coordinate to the leader, and replies
+// the leader's response to the node that sent it here. A transaction handed off by a
+// node that was the leader is recorded as failed on it, as it ends there.
+func (this *ServiceTransactions) handOff(msg *ifs.Message, leader string) {
+	if msg.Tr_State() == ifs.Queued {
+		recorded := msg.Clone()
+		recorded.SetTr_State(ifs.Failed)
+		recorded.SetTr_ErrMsg("Transaction was handed off to leader " + leader)
+		this.tm.recordTransition(recorded, false, false, nil, this.nic)
+	}
+	this.nic.Resources().Logger().Info("TransactionHandoff: handing ", msg.Tr_Id(), " off to leader ", leader)
+	clone := msg.Clone()
+	clone.SetTr_State(ifs.Created)
+	resp := this.nic.Forward(clone, leader)
+	this.nic.Reply(msg, resp)
+}
+
+// InDoubt returns the transactions of the message's service this participant holds in
+// pre-commit, with the leader that prepared them and whether they were already applied.
+func (this *TransactionManager) InDoubt(msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
+	return object.New(nil, this.transactionsOf(msg, vnic).inDoubt())
+}
+
+// inDoubt returns the transactions of the service this participant holds in pre-commit.
+func (this *ServiceTransactions) inDoubt() *l8svcs.L8InDoubtTransactionList {
+	this.preCommitMtx.Lock()
+	defer this.preCommitMtx.Unlock()
+	list := &l8svcs.L8InDoubtTransactionList{List: make([]*l8svcs.L8InDoubtTransaction, 0, len(this.prepared))}
+	for trId, prepared := range this.prepared {
+		list.List = append(list.List, &l8svcs.L8InDoubtTransaction{TrId: trId, Applied: prepared.applied,
+			Coordinator: prepared.coordinator})
+	}
+	return list
+}
+
+// Decision returns the state of a transaction as this node coordinated it, from its
+// history or, once the history expired or the node restarted, from its log. The state
+// is NotATransaction if this node does not know the transaction.
+func (this *TransactionManager) Decision(query *l8svcs.L8DecisionQuery) ifs.IElements {
+	return object.New(nil, &l8svcs.L8InDoubtTransaction{TrId: query.TrId, State: int32(this.decisionOf(query.TrId))})
+}
+
+// decisionOf returns the state of a transaction this node coordinated, or NotATransaction.
+func (this *TransactionManager) decisionOf(trId string) ifs.TransactionState {
+	status, ok := this.history.Get(trId)
+	if ok {
+		return ifs.TransactionState(status.State)
+	}
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	entry, ok := this.pending[trId]
+	if !ok {
+		return ifs.NotATransaction
+	}
+	return ifs.TransactionState(entry.State)
+}
+
+// Resolve runs the decision a new leader took on an in-doubt transaction this participant
+// holds: a committed one is committed and cleaned up, any other is rolled back. The
+// resolution of a deposed leader is rejected.
+func (this *TransactionManager) Resolve(resolution *l8svcs.L8InDoubtResolution, msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
+	if this.epochs != nil {
+		_, highest := this.epochs(msg.ServiceName(), msg.ServiceArea(), msg.Source())
+		if resolution.Epoch < highest {
+			return object.NewError("Resolve: transaction " + resolution.TrId + " was resolved by deposed leader " +
+				msg.Source() + " with a stale epoch")
+		}
+	}
+	committed := ifs.TransactionState(resolution.State) == ifs.Committed
+	state := this.transactionsOf(msg, vnic).resolve(resolution.TrId, committed)
+	return object.New(nil, &l8svcs.L8InDoubtTransaction{TrId: resolution.TrId, State: int32(state)})
+}
+
+// resolve commits and cleans up, or rolls back, a transaction this participant holds in
+// pre-commit. Returns its resulting state, or NotATransaction if it does not hold it.
+func (this *ServiceTransactions) resolve(trId string, committed bool) ifs.TransactionState {
+	this.preCommitMtx.Lock()
+	prepared, ok := this.prepared[trId]
+	this.preCommitMtx.Unlock()
+	if !ok {
+		return ifs.NotATransaction
+	}
+	if !committed {
+		msg := this.localPhaseOf(trId, prepared.action, ifs.Rollback)
+		this.rollbackInternal(msg)
+		return msg.Tr_State()
+	}
+	msg := this.localPhaseOf(trId, prepared.action, ifs.Committed)
+	this.commitInternal(msg)
+	if msg.Tr_State() == ifs.Failed {
+		return ifs.Failed
+	}
+	msg = this.localPhaseOf(trId, prepared.action, ifs.Cleanup)
+	this.cleanupInternal(msg)
+	return msg.Tr_State()
+}
+
+// inDoubtTransaction is a transaction a failed leader left on the participants of a service.
+type inDoubtTransaction struct {
+	holders     map[string]byte
+	applied     bool
+	coordinator string
+}
+
+// ResolveInDoubt resolves the transactions that the participants of a service hold in
+// pre-commit and this node does not know about, i.e. the ones a failed leader left
+// behind. Each participant is asked for its in-doubt transactions. A transaction that
+// any of them already applied was decided to commit, so it is committed and cleaned up
+// on the participants that hold it. Otherwise the leader that prepared it is asked for
+// its decision, and if it cannot tell, the votes decide: a transaction fewer participants
+// voted yes on than the commit policy requires cannot have committed, so it is rolled
+// back. The others are left for the failed leader to resolve from its log once it is
+// back, or for the participants' pre-commit sweeper. Returns the number of resolved
+// transactions.
+func (this *TransactionManager) ResolveInDoubt(serviceName string, serviceArea byte, vnic ifs.IVNic) int {
+	timeout := agreement.For(vnic.Resources(), serviceName, serviceArea).TransactionTimeout()
+	inDoubt := make(map[string]*inDoubtTransaction)
+	participants := vnic.Resources().Services().GetParticipants(serviceName, serviceArea)
+	for target, replica := range participants {
+		resp := vnic.Request(target, serviceName, serviceArea, ifs.GET, &l8svcs.L8InDoubtQuery{}, int(timeout/time.Second))
+		if resp == nil || resp.Error() != nil {
+			vnic.Resources().Logger().Error("TransactionHandoff: failed to query in-doubt transactions of ", target)
+			continue
+		}
+		list, ok := resp.Element().(*l8svcs.L8InDoubtTransactionList)
+		if !ok {
+			continue
+		}
+		for _, tr := range list.List {
+			if tr == nil || tr.TrId == "" {
+				continue
+			}
+			//Transactions this node coordinates, or recovered from its log, are not in doubt
+			if _, known := this.history.Get(tr.TrId); known {
+				continue
+			}
+			doubt, ok := inDoubt[tr.TrId]
+			if !ok {
+				doubt = &inDoubtTransaction{holders: make(map[string]byte)}
+				inDoubt[tr.TrId] = doubt
+			}
+			doubt.holders[target] = replica
+			doubt.applied = doubt.applied || tr.Applied
+			if tr.Coordinator != "" {
+				doubt.coordinator = tr.Coordinator
+			}
+		}
+	}
+
+	required := agreement.For(vnic.Resources(), serviceName, serviceArea).Required(this.targetCountOf(serviceName, serviceArea, len(participants), vnic))
+	claimed := int64(0)
+	if this.epochs != nil {
+		claimed, _ = this.epochs(serviceName, serviceArea, vnic.Resources().SysConfig().LocalUuid)
+	}
+	resolved := 0
+	for trId, doubt := range inDoubt {
+		committed := doubt.applied
+		if !committed {
+			switch this.coordinatorDecisionOf(serviceName, serviceArea, trId, doubt.coordinator, vnic) {
+			case ifs.Committed, ifs.Cleanup:
+				committed = true
+			case ifs.Failed, ifs.Rollback:
+			case ifs.NotATransaction:
+				if len(doubt.holders) >= required {
+					vnic.Resources().Logger().Warning("TransactionHandoff: cannot tell the decision on in-doubt transaction ",
+						trId, ", leaving it to its leader or to the pre-commit sweeper")
+					continue
+				}
+			default:
+				//The leader that prepared it is still running it
+				continue
+			}
+		}
+
+		state := ifs.Rollback
+		if committed {
+			state = ifs.Committed
+			vnic.Resources().Logger().Info("TransactionHandoff: committing in-doubt transaction ", trId)
+		} else {
+			vnic.Resources().Logger().Info("TransactionHandoff: rolling back in-doubt transaction ", trId)
+		}
+		resolution := &l8svcs.L8InDoubtResolution{TrId: trId, State: int32(state), Epoch: claimed}
+		for holder := range doubt.holders {
+			resp := vnic.Request(holder, serviceName, serviceArea, ifs.PUT, resolution, int(timeout/time.Second))
+			if resp == nil || resp.Error() != nil {
+				vnic.Resources().Logger().Error("TransactionHandoff: failed to resolve ", trId, " on ", holder)
+			}
+		}
+		resolved++
+	}
+	return resolved
+}
+
+// targetCountOf returns the number of targets the leader sent a transaction of the service
+// to, its replication count for a replicated service, otherwise all the participants.
+func (this *TransactionManager) targetCountOf(serviceName string, serviceArea byte, participants int, vnic ifs.IVNic) int {
+	service, ok := vnic.Resources().Services().ServiceHandler(serviceName, serviceArea)
+	if ok && service.TransactionConfig() != nil && service.TransactionConfig().Replication() {
+		count := service.TransactionConfig().ReplicationCount()
+		if count < participants {
+			return count
+		}
+	}
+	return participants
+}
+
+// coordinatorDecisionOf asks the leader that prepared an in-doubt transaction for its
+// decision. Returns NotATransaction if it is unknown, unreachable or does not know it.
+func (this *TransactionManager) coordinatorDecisionOf(serviceName string, serviceArea byte, trId, coordinator string, vnic ifs.IVNic) ifs.TransactionState {
+	if coordinator == "" {
+		return ifs.NotATransaction
+	}
+	if coordinator == vnic.Resources().SysConfig().LocalUuid {
+		return this.decisionOf(trId)
+	}
+	timeout := agreement.For(vnic.Resources(), serviceName, serviceArea).TransactionTimeout()
+	resp := vnic.Request(coordinator, serviceName, serviceArea, ifs.GET, &l8svcs.L8DecisionQuery{TrId: trId}, int(timeout/time.Second))
+	if resp == nil || resp.Error() != nil {
+		return ifs.NotATransaction
+	}
+	decision, ok := resp.Element().(*l8svcs.L8InDoubtTransaction)
+	if !ok {
+		return ifs.NotATransaction
+	}
+	return ifs.TransactionState(decision.State)
+}
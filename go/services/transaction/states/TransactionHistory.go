@@ -0,0 +1,115 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+// Default limits of the transaction status history kept on the leader.
+const (
+	defaultHistorySize = 10000
+	defaultHistoryTTL  = 10 * time.Minute
+)
+
+// historyEntry is the latest known status of a transaction and its position in the eviction order.
+type historyEntry struct {
+	tr      *l8services.L8Transaction
+	updated time.Time
+	elem    *list.Element
+}
+
+// TransactionHistory is a bounded, TTL expiring record of the latest status of
+// recent transactions, indexed by transaction id. Entries are evicted in the
+// order they were last updated, once the history is full or they expire.
+type TransactionHistory struct {
+	entries map[string]*historyEntry
+	order   *list.List
+	maxSize int
+	ttl     time.Duration
+	mtx     *sync.Mutex
+}
+
+// NewTransactionHistory creates a history holding up to maxSize transactions for ttl.
+func NewTransactionHistory(maxSize int, ttl time.Duration) *TransactionHistory {
+	history := &TransactionHistory{}
+	history.entries = make(map[string]*historyEntry)
+	history.order = list.New()
+	history.maxSize = maxSize
+	history.ttl = ttl
+	history.mtx = &sync.Mutex{}
+	return history
+}
+
+// SetLimits changes the size and ttl limits, evicting entries that no longer fit.
+func (this *TransactionHistory) SetLimits(maxSize int, ttl time.Duration) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.maxSize = maxSize
+	this.ttl = ttl
+	this.expire()
+}
+
+// put records the latest status of a transaction.
+func (this *TransactionHistory) put(tr *l8services.L8Transaction) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	entry, ok := this.entries[tr.Id]
+	if ok {
+		entry.tr = tr
+		entry.updated = time.Now()
+		this.order.MoveToBack(entry.elem)
+	} else {
+		entry = &historyEntry{tr: tr, updated: time.Now()}
+		entry.elem = this.order.PushBack(entry)
+		this.entries[tr.Id] = entry
+	}
+	this.expire()
+}
+
+// Get returns the latest recorded status of a transaction, if it was not evicted.
+func (this *TransactionHistory) Get(trId string) (*l8services.L8Transaction, bool) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.expire()
+	entry, ok := this.entries[trId]
+	if !ok {
+		return nil, false
+	}
+	return entry.tr, true
+}
+
+// Size returns the number of transactions currently in the history.
+func (this *TransactionHistory) Size() int {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return len(this.entries)
+}
+
+// expire evicts the oldest entries while the history is too big or they are older than the ttl.
+func (this *TransactionHistory) expire() {
+	now := time.Now()
+	for front := this.order.Front(); front != nil; front = this.order.Front() {
+		entry := front.Value.(*historyEntry)
+		if len(this.entries) <= this.maxSize && now.Sub(entry.updated) <= this.ttl {
+			return
+		}
+		this.order.Remove(front)
+		delete(this.entries, entry.tr.Id)
+	}
+}
@@ -0,0 +1,331 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/types/l8services"
+	"google.golang.org/protobuf/proto"
+)
+
+// ITransactionLog is a write-ahead log of transaction state transitions.
+// Implementations must persist an entry before Append returns so a restarted
+// node can replay the transactions that were in flight when it went down.
+type ITransactionLog interface {
+	// Append durably records a single transaction state transition.
+	Append(entry *TransactionLogEntry) error
+	// Pending returns the folded, latest entry of every transaction that has
+	// not yet reached a terminal state.
+	Pending() ([]*TransactionLogEntry, error)
+	// Close flushes and releases the underlying log resources.
+	Close() error
+}
+
+// TransactionLogEntry is a single transition of a transaction as seen by this node.
+// The same transaction may be logged twice on a node, once as the leader that
+// coordinates it and once as a participant that applies it. The leader also logs
+// the targets, participants or replicas, it sends the transaction to, and the
+// batch of a multi-service transaction operation with the node coordinating it, which
+// logs the batch itself as an entry of its own. A participant logs the leader
+// that prepared the transaction as its coordinator, and logs a commit as applying
+// before it applies it, so a restarted node applies it again only if it did not finish.
+type TransactionLogEntry struct {
+	TrId        string             `json:"id"`
+	State       int32              `json:"state"`
+	ServiceName string             `json:"service"`
+	ServiceArea byte               `json:"area"`
+	Action      int32              `json:"action"`
+	Source      string             `json:"source,omitempty"`
+	Participant bool               `json:"participant,omitempty"`
+	IsReplica   bool               `json:"isReplica,omitempty"`
+	Replica     byte               `json:"replica,omitempty"`
+	Timeout     int64              `json:"timeout,omitempty"`
+	Data        string             `json:"data,omitempty"`
+	Snapshot    []*SnapshotElement `json:"snapshot,omitempty"`
+	Absent      []*SnapshotElement `json:"absent,omitempty"`
+	Targets     map[string]byte    `json:"targets,omitempty"`
+	Replicate   bool               `json:"replicate,omitempty"`
+	Batch       string             `json:"batch,omitempty"`
+	Coordinator string             `json:"coordinator,omitempty"`
+	Applying    bool               `json:"applying,omitempty"`
+	Time        int64              `json:"time"`
+}
+
+// SnapshotElement is a serialized pre-commit element and the registered type
+// name needed to instantiate it again on replay.
+type SnapshotElement struct {
+	Type string `json:"type"`
+	Data []byte `json:"data"`
+}
+
+// newTransactionLogEntry creates a log entry for the message's current state.
+func newTransactionLogEntry(msg *ifs.Message, participant bool) *TransactionLogEntry {
+	return &TransactionLogEntry{
+		TrId:        msg.Tr_Id(),
+		State:       int32(msg.Tr_State()),
+		ServiceName: msg.ServiceName(),
+		ServiceArea: msg.ServiceArea(),
+		Action:      int32(msg.Action()),
+		Source:      msg.Source(),
+		Participant: participant,
+		IsReplica:   msg.Tr_IsReplica(),
+		Replica:     msg.Tr_Replica(),
+		Timeout:     msg.Tr_Timeout(),
+		Time:        time.Now().UnixMilli(),
+	}
+}
+
+// key returns the identity of the entry's transaction from this node's role point of view.
+func (this *TransactionLogEntry) key() string {
+	if this.Participant {
+		return this.TrId + "-p"
+	}
+	return this.TrId + "-l"
+}
+
+// isTerminal returns true if no further work is needed for this transaction on this node.
+func (this *TransactionLogEntry) isTerminal() bool {
+	switch ifs.TransactionState(this.State) {
+	case ifs.Failed, ifs.Cleanup:
+		return true
+	case ifs.Rollback:
+		return this.Participant
+	}
+	return false
+}
+
+// merge folds a newer entry of the same transaction into this one, keeping the
+// payload, snapshot and targets recorded by earlier transitions.
+func (this *TransactionLogEntry) merge(newer *TransactionLogEntry) {
+	this.State = newer.State
+	this.Time = newer.Time
+	this.Applying = newer.Applying
+	if newer.Data != "" {
+		this.Data = newer.Data
+	}
+	if newer.Snapshot != nil {
+		this.Snapshot = newer.Snapshot
+	}
+	if newer.Absent != nil {
+		this.Absent = newer.Absent
+	}
+	if newer.Targets != nil {
+		this.Targets = newer.Targets
+		this.Replicate = newer.Replicate
+	}
+	if newer.Batch != "" {
+		this.Batch = newer.Batch
+	}
+	if newer.Coordinator != "" {
+		this.Coordinator = newer.Coordinator
+	}
+}
+
+// message rebuilds the transaction message recorded by the entry.
+func (this *TransactionLogEntry) message() *ifs.Message {
+	msg := &ifs.Message{}
+	msg.SetServiceName(this.ServiceName)
+	msg.SetServiceArea(this.ServiceArea)
+	msg.SetAction(ifs.Action(this.Action))
+	msg.SetSource(this.Source)
+	msg.SetData(this.Data)
+	msg.SetTr_Id(this.TrId)
+	msg.SetTr_State(ifs.TransactionState(this.State))
+	msg.SetTr_IsReplica(this.IsReplica)
+	msg.SetTr_Replica(this.Replica)
+	msg.SetTr_Timeout(this.Timeout)
+	return msg
+}
+
+// snapshotOf serializes the elements of a pre-commit snapshot.
+func snapshotOf(elems ifs.IElements) ([]*SnapshotElement, error) {
+	if elems == nil {
+		return nil, nil
+	}
+	result := make([]*SnapshotElement, 0)
+	for _, elem := range elems.Elements() {
+		pb, ok := elem.(proto.Message)
+		if !ok || pb == nil {
+			continue
+		}
+		data, err := proto.Marshal(pb)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &SnapshotElement{Type: reflect.ValueOf(pb).Elem().Type().Name(), Data: data})
+	}
+	return result, nil
+}
+
+// elementsOfSnapshot instantiates the elements of a serialized pre-commit snapshot.
+func elementsOfSnapshot(snapshot []*SnapshotElement, r ifs.IResources) (ifs.IElements, error) {
+	elems := make([]interface{}, 0, len(snapshot))
+	for _, se := range snapshot {
+		info, err := r.Registry().Info(se.Type)
+		if err != nil {
+			return nil, err
+		}
+		ins, err := info.NewInstance()
+		if err != nil {
+			return nil, err
+		}
+		err = proto.Unmarshal(se.Data, ins.(proto.Message))
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, ins)
+	}
+	if len(elems) == 1 {
+		return object.New(nil, elems[0]), nil
+	}
+	return object.New(nil, elems), nil
+}
+
+// recordTransition records the message's current state in the leader's status history
+// and appends it to the transaction log, if one is set. Cleanup is internal to the
+// participants, so the status history keeps the Committed outcome instead. Returns the
+// error of the append, the transition is not durable then.
+func (this *TransactionManager) recordTransition(msg *ifs.Message, participant bool, withData bool, snapshot ifs.IElements, nic ifs.IVNic) error {
+	if !participant && msg.Tr_State() != ifs.Cleanup {
+		tr := L8TransactionOf(msg)
+		this.history.put(tr)
+		this.idempotency.update(tr)
+	}
+	trLog := this.transactionLog()
+	if trLog == nil {
+		return nil
+	}
+	entry := newTransactionLogEntry(msg, participant)
+	if withData {
+		entry.Data = msg.Data()
+	}
+	if snapshot != nil {
+		elems, err := snapshotOf(snapshot)
+		if err != nil {
+			nic.Resources().Logger().Error("TransactionLog: failed to serialize snapshot of ", msg.Tr_Id(), " ", err.Error())
+		}
+		entry.Snapshot = elems
+	}
+	err := trLog.Append(entry)
+	if err != nil {
+		nic.Resources().Logger().Error("TransactionLog: failed to append ", msg.Tr_Id(), " ", err.Error())
+	}
+	return err
+}
+
+// recordBatch records the state of a multi-service transaction this node coordinates in
+// its status history and appends it to the transaction log, if one is set. A committed
+// batch is pending in the log until all its operations committed, so a new leader of
+// their services can still ask for the decision.
+func (this *TransactionManager) recordBatch(tr *l8services.L8Transaction, nic ifs.IVNic) error {
+	if ifs.TransactionState(tr.State) != ifs.Cleanup {
+		this.history.put(tr)
+		this.idempotency.update(tr)
+	}
+	trLog := this.transactionLog()
+	if trLog == nil {
+		return nil
+	}
+	err := trLog.Append(&TransactionLogEntry{TrId: tr.Id, State: tr.State, Batch: tr.Id, Time: time.Now().UnixMilli()})
+	if err != nil {
+		nic.Resources().Logger().Error("TransactionLog: failed to append the batch ", tr.Id, " ", err.Error())
+	}
+	return err
+}
+
+// recordPrepared appends a participant's prepared transaction, with its payload, its
+// pre-commit snapshot and the elements it creates, so a restarted node can roll it back,
+// and the leader that prepared it, so a new leader can ask it for its decision. A snapshot
+// that cannot be serialized fails the append, as the prepare could not be rolled back.
+func (this *TransactionManager) recordPrepared(msg *ifs.Message, coordinator string, snapshot ifs.IElements, absent ifs.IElements, nic ifs.IVNic) error {
+	trLog := this.transactionLog()
+	if trLog == nil {
+		return nil
+	}
+	entry := newTransactionLogEntry(msg, true)
+	entry.Data = msg.Data()
+	entry.Coordinator = coordinator
+	var err error
+	entry.Snapshot, err = snapshotOf(snapshot)
+	if err == nil {
+		entry.Absent, err = snapshotOf(absent)
+	}
+	if err != nil {
+		nic.Resources().Logger().Error("TransactionLog: failed to serialize snapshot of ", msg.Tr_Id(), " ", err.Error())
+		return err
+	}
+	err = trLog.Append(entry)
+	if err != nil {
+		nic.Resources().Logger().Error("TransactionLog: failed to append ", msg.Tr_Id(), " ", err.Error())
+	}
+	return err
+}
+
+// recordApplying appends a participant's commit before it applies it.
+func (this *TransactionManager) recordApplying(msg *ifs.Message, nic ifs.IVNic) error {
+	trLog := this.transactionLog()
+	if trLog == nil {
+		return nil
+	}
+	entry := newTransactionLogEntry(msg, true)
+	entry.Applying = true
+	err := trLog.Append(entry)
+	if err != nil {
+		nic.Resources().Logger().Error("TransactionLog: failed to append the commit of ", msg.Tr_Id(), " ", err.Error())
+	}
+	return err
+}
+
+// recordTargets appends the leader's transaction, in its current state, with the targets
+// it is sent to, so a new leader recovering it resolves it on the same nodes.
+func (this *TransactionManager) recordTargets(msg *ifs.Message, targets map[string]byte, isReplicate bool, nic ifs.IVNic) error {
+	trLog := this.transactionLog()
+	if trLog == nil {
+		return nil
+	}
+	entry := newTransactionLogEntry(msg, false)
+	entry.Targets = targets
+	entry.Replicate = isReplicate
+	err := trLog.Append(entry)
+	if err != nil {
+		nic.Resources().Logger().Error("TransactionLog: failed to append the targets of ", msg.Tr_Id(), " ", err.Error())
+	}
+	return err
+}
+
+// recordStep appends an operation of a multi-service transaction, with its payload, its
+// targets, the batch it belongs to and the node coordinating the batch, so a new leader
+// can ask that node for the batch's decision.
+func (this *TransactionManager) recordStep(msg *ifs.Message, batch string, coordinator string, targets map[string]byte,
+	isReplicate bool, nic ifs.IVNic) error {
+	trLog := this.transactionLog()
+	if trLog == nil {
+		return nil
+	}
+	entry := newTransactionLogEntry(msg, false)
+	entry.Data = msg.Data()
+	entry.Targets = targets
+	entry.Replicate = isReplicate
+	entry.Batch = batch
+	entry.Coordinator = coordinator
+	err := trLog.Append(entry)
+	if err != nil {
+		nic.Resources().Logger().Error("TransactionLog: failed to append the step ", msg.Tr_Id(), " of ", batch, " ", err.Error())
+	}
+	return err
+}
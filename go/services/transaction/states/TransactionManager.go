@@ -13,22 +13,34 @@
 
 // Package states implements the transaction state machine for distributed
 // ACID transactions. It manages transaction lifecycle through states:
-// Created -> Queued -> Running -> Committed/Rollback -> Cleanup.
+// Created -> Queued -> Running (prepare) -> Committed/Rollback -> Cleanup.
 package states
 
 import (
 	"sync"
 
+	"github.com/saichler/l8srlz/go/serialize/object"
 	"github.com/saichler/l8types/go/ifs"
 )
 
 // TransactionManager orchestrates distributed transactions across services.
 // It maintains per-service transaction queues and coordinates the 2-phase
-// commit protocol for ensuring data consistency.
+// commit protocol, prepare and vote before apply, for ensuring data consistency.
 type TransactionManager struct {
 	serviceTransactions map[string]*ServiceTransactions
 	services            ifs.IServices
 	mtx                 *sync.Mutex
+	trLog               ITransactionLog
+	pending             map[string]*TransactionLogEntry
+	history             *TransactionHistory
+	lagging             *LaggingPeers
+	divergent           *DivergentWrites
+	health              *ParticipantHealth
+	idempotency         *IdempotencyCache
+	metrics             *TransactionMetrics
+	rebalances          map[string]*RebalanceReport
+	batchSteps          map[string]*multiStep
+	epochs              func(serviceName string, serviceArea byte, node string) (int64, int64)
 }
 
 // NewTransactionManager creates a new TransactionManager linked to the service manager.
@@ -37,6 +49,15 @@ func NewTransactionManager(services ifs.IServices) *TransactionManager {
 	tm.mtx = &sync.Mutex{}
 	tm.services = services
 	tm.serviceTransactions = make(map[string]*ServiceTransactions)
+	tm.pending = make(map[string]*TransactionLogEntry)
+	tm.history = NewTransactionHistory(defaultHistorySize, defaultHistoryTTL)
+	tm.lagging = newLaggingPeers()
+	tm.divergent = newDivergentWrites()
+	tm.health = newParticipantHealth(tm.repairReadmitted)
+	tm.idempotency = newIdempotencyCache()
+	tm.metrics = newTransactionMetrics()
+	tm.rebalances = make(map[string]*RebalanceReport)
+	tm.batchSteps = make(map[string]*multiStep)
 	return tm
 }
 
@@ -47,23 +68,51 @@ func (this *TransactionManager) transactionsOf(msg *ifs.Message, nic ifs.IVNic)
 	serviceKey := ServiceKey(msg.ServiceName(), msg.ServiceArea())
 	st, ok := this.serviceTransactions[serviceKey]
 	if !ok {
-		this.serviceTransactions[serviceKey] = newServiceTransactions(nic)
+		this.serviceTransactions[serviceKey] = newServiceTransactions(this, msg.ServiceName(), msg.ServiceArea(), nic)
 		st = this.serviceTransactions[serviceKey]
 	}
 	return st
 }
 
+// Shutdown stops processing the transactions of a service on this node, once it is
+// deactivated, failing the ones still queued. Its background sweeper and repairer stop
+// with it. A later transaction of the service starts processing it again.
+func (this *TransactionManager) Shutdown(serviceName string, serviceArea byte) {
+	serviceKey := ServiceKey(serviceName, serviceArea)
+	this.mtx.Lock()
+	st, ok := this.serviceTransactions[serviceKey]
+	delete(this.serviceTransactions, serviceKey)
+	this.mtx.Unlock()
+	if ok {
+		st.shutdown()
+	}
+}
+
 // Run processes a transaction based on its current state, routing to the
-// appropriate handler (created, commit, rollback, or cleanup).
+// appropriate handler (created, prepare, commit, rollback, or cleanup).
+// The phase messages of a deposed leader are rejected.
 func (this *TransactionManager) Run(msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
+	if msg.Tr_State() != ifs.Created && this.stalePhase(msg, vnic) {
+		vnic.Resources().Logger().Error("Rejecting transaction ", msg.Tr_Id(), " ", msg.Tr_State().String(),
+			" from deposed leader ", msg.Source())
+		msg.SetTr_State(ifs.Failed)
+		msg.SetTr_ErrMsg("Transaction " + msg.Tr_Id() + " was sent by deposed leader " + msg.Source() + " with a stale epoch")
+		return L8TransactionFor(msg)
+	}
 	switch msg.Tr_State() {
 	case ifs.Created:
 		return this.created(msg, vnic)
 	case ifs.Running:
+		defer this.metrics.startSpan(SpanPrepare, msg)()
+		return this.prepare(msg, vnic)
+	case ifs.Committed:
+		defer this.metrics.startSpan(SpanCommit, msg)()
 		return this.commit(msg, vnic)
 	case ifs.Rollback:
+		defer this.metrics.startSpan(SpanRollback, msg)()
 		return this.rollback(msg, vnic)
 	case ifs.Cleanup:
+		defer this.metrics.startSpan(SpanCleanup, msg)()
 		return this.cleanup(msg, vnic)
 	default:
 		panic("Unexpected transaction state " + msg.Tr_State().String() + ":" + msg.Tr_ErrMsg())
@@ -71,11 +120,39 @@ func (this *TransactionManager) Run(msg *ifs.Message, vnic ifs.IVNic) ifs.IEleme
 }
 
 // created handles newly created transactions by queuing them for processing.
+// A retry of a write sent with an idempotency key that was already used returns the
+// original transaction's result.
+// A transaction that reached this node after it lost the leadership is handed off to the
+// new leader, unless it came from there.
 func (this *TransactionManager) created(msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
 	st := this.transactionsOf(msg, vnic)
+	leader := vnic.Resources().Services().GetLeader(msg.ServiceName(), msg.ServiceArea())
+	if msg.Action() != ifs.GET && leader != "" && leader != vnic.Resources().SysConfig().LocalUuid && leader != msg.Source() {
+		go st.handOff(msg, leader)
+		return L8TransactionFor(msg)
+	}
+	if msg.Action() != ifs.GET {
+		key, err := idempotencyKeyOf(msg, vnic.Resources())
+		if err != nil {
+			msg.SetTr_State(ifs.Failed)
+			msg.SetTr_ErrMsg("Failed to read the idempotency key of " + msg.Tr_Id() + ": " + err.Error())
+			return L8TransactionFor(msg)
+		}
+		original, ok := this.duplicateOf(msg, key, vnic.Resources())
+		if ok {
+			vnic.Resources().Logger().Debug("Transaction ", msg.Tr_Id(), " is a retry of ", original.Id)
+			return object.New(nil, original)
+		}
+	}
 	return st.queueTransaction(msg, vnic)
 }
 
+// prepare processes the prepare phase of a transaction, voting on it.
+func (this *TransactionManager) prepare(msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
+	st := this.transactionsOf(msg, vnic)
+	return st.prepareInternal(msg)
+}
+
 // commit processes the commit phase of a transaction.
 func (this *TransactionManager) commit(msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
 	st := this.transactionsOf(msg, vnic)
@@ -0,0 +1,276 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// Span names of the transaction phases reported to an ITransactionTracer.
+const (
+	SpanCreate   = "create"
+	SpanRun      = "run"
+	SpanPrepare  = "prepare"
+	SpanCommit   = "commit"
+	SpanRollback = "rollback"
+	SpanCleanup  = "cleanup"
+)
+
+// latencyBuckets are the upper bounds, in milliseconds, of the latency histograms buckets.
+var latencyBuckets = []int64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// ITransactionTracer is an optional tracer, plugged into the TransactionManager,
+// that gets a span around every transaction phase on this node.
+type ITransactionTracer interface {
+	StartSpan(name string, msg *ifs.Message) ITransactionSpan
+}
+
+// ITransactionSpan is a started span, ended with the message once the phase is done,
+// so its transaction state and error tell the outcome.
+type ITransactionSpan interface {
+	End(msg *ifs.Message)
+}
+
+// latencyHistogram accumulates latency samples into the latencyBuckets.
+type latencyHistogram struct {
+	counts []int64
+	count  int64
+	sumMs  int64
+}
+
+// newLatencyHistogram creates an empty histogram.
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]int64, len(latencyBuckets)+1)}
+}
+
+// observe adds a sample to the histogram.
+func (this *latencyHistogram) observe(d time.Duration) {
+	ms := d.Milliseconds()
+	i := sort.Search(len(latencyBuckets), func(i int) bool { return latencyBuckets[i] >= ms })
+	this.counts[i]++
+	this.count++
+	this.sumMs += ms
+}
+
+// snapshot returns a copy of the histogram.
+func (this *latencyHistogram) snapshot() *l8svcs.L8LatencyHistogram {
+	counts := make([]int64, len(this.counts))
+	copy(counts, this.counts)
+	return &l8svcs.L8LatencyHistogram{Buckets: latencyBuckets, Counts: counts, Count: this.count, SumMs: this.sumMs}
+}
+
+// serviceMetrics holds the counters and histograms of a single service.
+type serviceMetrics struct {
+	serviceName       string
+	serviceArea       byte
+	created           int64
+	committed         int64
+	failed            int64
+	rolledBack        int64
+	orphansDiscarded  int64
+	orphansRolledBack int64
+	queueWait         *latencyHistogram
+	runTime           *latencyHistogram
+	phaseTime         map[string]*latencyHistogram
+	peerCommit        map[string]*latencyHistogram
+}
+
+// TransactionMetrics collects per-service transaction counters and latency histograms,
+// and forwards span hooks to the tracer, if one is set.
+type TransactionMetrics struct {
+	services map[string]*serviceMetrics
+	tracer   ITransactionTracer
+	mtx      *sync.Mutex
+}
+
+// newTransactionMetrics creates an empty metrics collector.
+func newTransactionMetrics() *TransactionMetrics {
+	metrics := &TransactionMetrics{}
+	metrics.services = make(map[string]*serviceMetrics)
+	metrics.mtx = &sync.Mutex{}
+	return metrics
+}
+
+// SetTracer sets the tracer getting the transaction spans, nil removes it.
+func (this *TransactionMetrics) SetTracer(tracer ITransactionTracer) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.tracer = tracer
+}
+
+// startSpan starts a span with the tracer, if one is set, and returns the function ending it.
+func (this *TransactionMetrics) startSpan(name string, msg *ifs.Message) func() {
+	start := time.Now()
+	this.mtx.Lock()
+	tracer := this.tracer
+	this.mtx.Unlock()
+	var span ITransactionSpan
+	if tracer != nil {
+		span = tracer.StartSpan(name, msg)
+	}
+	return func() {
+		this.update(msg, func(sm *serviceMetrics) {
+			histogramOf(sm.phaseTime, name).observe(time.Since(start))
+		})
+		if span != nil {
+			span.End(msg)
+		}
+	}
+}
+
+// serviceOf returns, or creates, the metrics of a service, the caller holds the mutex.
+func (this *TransactionMetrics) serviceOf(serviceName string, serviceArea byte) *serviceMetrics {
+	key := ServiceKey(serviceName, serviceArea)
+	sm, ok := this.services[key]
+	if !ok {
+		sm = &serviceMetrics{serviceName: serviceName, serviceArea: serviceArea,
+			queueWait: newLatencyHistogram(), runTime: newLatencyHistogram(),
+			phaseTime: make(map[string]*latencyHistogram), peerCommit: make(map[string]*latencyHistogram)}
+		this.services[key] = sm
+	}
+	return sm
+}
+
+// update applies a change to the metrics of the message's service.
+func (this *TransactionMetrics) update(msg *ifs.Message, change func(*serviceMetrics)) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	change(this.serviceOf(msg.ServiceName(), msg.ServiceArea()))
+}
+
+// created counts a transaction created on this node.
+func (this *TransactionMetrics) created(msg *ifs.Message) {
+	this.update(msg, func(sm *serviceMetrics) { sm.created++ })
+}
+
+// ran records the outcome and latencies of a transaction this node ran as the leader.
+// The outcome is the commit decision, as a failed cleanup leaves the message failed.
+func (this *TransactionMetrics) ran(msg *ifs.Message, committed bool, queued, running time.Time) {
+	this.update(msg, func(sm *serviceMetrics) {
+		if committed {
+			sm.committed++
+		} else {
+			sm.failed++
+		}
+		sm.queueWait.observe(running.Sub(queued))
+		sm.runTime.observe(time.Since(running))
+	})
+}
+
+// rolledBack counts a transaction the leader rolled back.
+func (this *TransactionMetrics) rolledBack(msg *ifs.Message) {
+	this.update(msg, func(sm *serviceMetrics) { sm.rolledBack++ })
+}
+
+// orphanSwept counts a stale pre-commit entry the participant's sweeper discarded, or
+// rolled back if rolledBack is true.
+func (this *TransactionMetrics) orphanSwept(msg *ifs.Message, rolledBack bool) {
+	this.update(msg, func(sm *serviceMetrics) {
+		if rolledBack {
+			sm.orphansRolledBack++
+		} else {
+			sm.orphansDiscarded++
+		}
+	})
+}
+
+// peersCommitted records the commit latency of every peer that responded.
+func (this *TransactionMetrics) peersCommitted(msg *ifs.Message, latencies map[string]time.Duration) {
+	this.update(msg, func(sm *serviceMetrics) {
+		for peer, latency := range latencies {
+			histogramOf(sm.peerCommit, peer).observe(latency)
+		}
+	})
+}
+
+// Of returns a snapshot of the counters and histograms of a service.
+func (this *TransactionMetrics) Of(serviceName string, serviceArea byte) *l8svcs.L8ServiceMetrics {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return this.serviceOf(serviceName, serviceArea).snapshot()
+}
+
+// All returns a snapshot of the metrics of every service seen on this node.
+func (this *TransactionMetrics) All() []*l8svcs.L8ServiceMetrics {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	result := make([]*l8svcs.L8ServiceMetrics, 0, len(this.services))
+	for _, sm := range this.services {
+		result = append(result, sm.snapshot())
+	}
+	return result
+}
+
+// snapshot returns a copy of the service's metrics.
+func (this *serviceMetrics) snapshot() *l8svcs.L8ServiceMetrics {
+	result := &l8svcs.L8ServiceMetrics{ServiceName: this.serviceName, ServiceArea: int32(this.serviceArea),
+		Created: this.created, Committed: this.committed, Failed: this.failed, RolledBack: this.rolledBack,
+		OrphansDiscarded: this.orphansDiscarded, OrphansRolledBack: this.orphansRolledBack,
+		QueueWait: this.queueWait.snapshot(), RunTime: this.runTime.snapshot(),
+		PhaseTime: make(map[string]*l8svcs.L8LatencyHistogram), PeerCommit: make(map[string]*l8svcs.L8LatencyHistogram)}
+	for name, h := range this.phaseTime {
+		result.PhaseTime[name] = h.snapshot()
+	}
+	for peer, h := range this.peerCommit {
+		result.PeerCommit[peer] = h.snapshot()
+	}
+	return result
+}
+
+// histogramOf returns, or creates, the histogram of a name.
+func histogramOf(histograms map[string]*latencyHistogram, name string) *latencyHistogram {
+	h, ok := histograms[name]
+	if !ok {
+		h = newLatencyHistogram()
+		histograms[name] = h
+	}
+	return h
+}
+
+// Metrics returns a snapshot of the transaction metrics of a service, including its
+// current queue depth and the number of transactions in flight.
+func (this *TransactionManager) Metrics(serviceName string, serviceArea byte) *l8svcs.L8ServiceMetrics {
+	result := this.metrics.Of(serviceName, serviceArea)
+	this.mtx.Lock()
+	st, ok := this.serviceTransactions[ServiceKey(serviceName, serviceArea)]
+	this.mtx.Unlock()
+	if ok {
+		result.QueueDepth, result.InFlight = st.depth()
+	}
+	return result
+}
+
+// AllMetrics returns a snapshot of the transaction metrics of every service seen on this node.
+func (this *TransactionManager) AllMetrics() []*l8svcs.L8ServiceMetrics {
+	all := this.metrics.All()
+	for _, sm := range all {
+		this.mtx.Lock()
+		st, ok := this.serviceTransactions[ServiceKey(sm.ServiceName, byte(sm.ServiceArea))]
+		this.mtx.Unlock()
+		if ok {
+			sm.QueueDepth, sm.InFlight = st.depth()
+		}
+	}
+	return all
+}
+
+// SetTracer plugs in a tracer getting a span around every transaction phase on this node.
+func (this *TransactionManager) SetTracer(tracer ITransactionTracer) {
+	this.metrics.SetTracer(tracer)
+}
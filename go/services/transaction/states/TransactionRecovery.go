@@ -0,0 +1,229 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+// SetTransactionLog sets the write-ahead log and replays its pending entries.
+// Participant entries restore their prepared changes right away so a later
+// Commit, Rollback or Cleanup can be served, leader entries are kept until this
+// node is the leader of their service and Recover is called. A nil log stops
+// the logging.
+func (this *TransactionManager) SetTransactionLog(trLog ITransactionLog, nic ifs.IVNic) error {
+	if trLog == nil {
+		this.mtx.Lock()
+		this.trLog = nil
+		this.pending = make(map[string]*TransactionLogEntry)
+		this.mtx.Unlock()
+		return nil
+	}
+	entries, err := trLog.Pending()
+	if err != nil {
+		return err
+	}
+
+	participantEntries := make([]*TransactionLogEntry, 0)
+	this.mtx.Lock()
+	this.trLog = trLog
+	this.pending = make(map[string]*TransactionLogEntry)
+	for _, entry := range entries {
+		if entry.Participant {
+			participantEntries = append(participantEntries, entry)
+		} else {
+			this.pending[entry.TrId] = entry
+		}
+	}
+	this.mtx.Unlock()
+
+	for _, entry := range participantEntries {
+		msg := entry.message()
+		st := this.transactionsOf(msg, nic)
+		st.restorePrepared(entry)
+	}
+	return nil
+}
+
+// transactionLog returns the current write-ahead log, or nil if none is set.
+func (this *TransactionManager) transactionLog() ITransactionLog {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return this.trLog
+}
+
+// Recover resolves the logged transactions of the services this node is now the leader of.
+// Queued transactions are queued again, transactions that were still preparing are
+// rolled back on the participants and committed ones are applied and cleaned up.
+// A prepared operation of a multi-service transaction is committed if the decision of its
+// batch was logged, by this node or by the node that coordinated the batch, and a batch
+// this node coordinated but did not decide before it went down is failed.
+func (this *TransactionManager) Recover(nic ifs.IVNic) {
+	localUuid := nic.Resources().SysConfig().LocalUuid
+	entries := make([]*TransactionLogEntry, 0)
+	undecided := make([]*TransactionLogEntry, 0)
+	committed := make(map[string]bool)
+	this.mtx.Lock()
+	for trId, entry := range this.pending {
+		if entry.Batch != "" && ifs.TransactionState(entry.State) == ifs.Committed {
+			committed[entry.Batch] = true
+		}
+		if entry.TrId == entry.Batch && ifs.TransactionState(entry.State) != ifs.Committed {
+			undecided = append(undecided, entry)
+			delete(this.pending, trId)
+			continue
+		}
+		if this.services.GetLeader(entry.ServiceName, entry.ServiceArea) == localUuid {
+			entries = append(entries, entry)
+			delete(this.pending, trId)
+		}
+	}
+	this.mtx.Unlock()
+
+	for _, entry := range undecided {
+		this.recordBatch(&l8services.L8Transaction{Id: entry.TrId, State: int32(ifs.Failed),
+			ErrMsg: "TransactionRecovery: batch aborted after coordinator restart"}, nic)
+	}
+	for _, entry := range entries {
+		if entry.Batch != "" && ifs.TransactionState(entry.State) == ifs.Running &&
+			(committed[entry.Batch] || this.batchDecisionOf(entry, nic) == ifs.Committed) {
+			entry.State = int32(ifs.Committed)
+		}
+	}
+
+	for _, entry := range entries {
+		msg := entry.message()
+		st := this.transactionsOf(msg, nic)
+		st.recover(entry)
+	}
+}
+
+// batchDecisionOf returns the decision of the batch of a logged operation, as known by the
+// node that coordinated the batch. A batch its coordinator does not know, or that cannot
+// be asked, is not committed.
+func (this *TransactionManager) batchDecisionOf(entry *TransactionLogEntry, nic ifs.IVNic) ifs.TransactionState {
+	if entry.Coordinator == "" || entry.Coordinator == nic.Resources().SysConfig().LocalUuid {
+		return this.decisionOf(entry.Batch)
+	}
+	timeout := agreement.For(nic.Resources(), entry.ServiceName, entry.ServiceArea).TransactionTimeout()
+	resp := nic.Request(entry.Coordinator, entry.ServiceName, entry.ServiceArea, ifs.GET,
+		&l8svcs.L8DecisionQuery{TrId: entry.Batch}, int(timeout/time.Second))
+	if resp == nil || resp.Error() != nil {
+		nic.Resources().Logger().Error("TransactionRecovery: failed to ask ", entry.Coordinator, " for the decision of ", entry.Batch)
+		return ifs.NotATransaction
+	}
+	decision, ok := resp.Element().(*l8svcs.L8InDoubtTransaction)
+	if !ok {
+		return ifs.NotATransaction
+	}
+	return ifs.TransactionState(decision.State)
+}
+
+// recover finishes or rolls back a single logged transaction on the new leader, on the
+// targets it was sent to. A transaction logged without its targets is resolved on the
+// replicas of its key, for a replicated service, or on all the participants.
+func (this *ServiceTransactions) recover(entry *TransactionLogEntry) {
+	msg := entry.message()
+	this.nic.Resources().Logger().Info("TransactionRecovery: recovering ", msg.Tr_Id(), " in state ", msg.Tr_State().String())
+	targets, isReplicate := entry.Targets, entry.Replicate
+	if targets == nil && msg.Tr_State() != ifs.Created && msg.Tr_State() != ifs.Queued {
+		var err error
+		targets, isReplicate, err = this.targetsOf(msg)
+		if err != nil {
+			this.nic.Resources().Logger().Error("TransactionRecovery: failed to resolve the targets of ", msg.Tr_Id(), " ", err.Error())
+			return
+		}
+	}
+	switch msg.Tr_State() {
+	case ifs.Created, ifs.Queued:
+		err := this.addTransaction(msg, this.nic)
+		if err != nil {
+			this.nic.Resources().Logger().Error("TransactionRecovery: failed to queue ", msg.Tr_Id(), " ", err.Error())
+		}
+	case ifs.Running, ifs.Rollback:
+		msg.SetTr_State(ifs.Rollback)
+		this.tm.recordTransition(msg, false, false, nil, this.nic)
+		this.tm.requestPhase(msg, targets, this.nic, isReplicate, time.Now().Add(timeoutOf(msg, this.nic.Resources())))
+		msg.SetTr_State(ifs.Failed)
+		msg.SetTr_ErrMsg("TransactionRecovery: rolled back after leader restart")
+		this.tm.recordTransition(msg, false, false, nil, this.nic)
+	case ifs.Committed:
+		//The decision was logged, make sure all the participants applied it
+		this.tm.history.put(L8TransactionOf(msg))
+		this.tm.requestPhase(msg, targets, this.nic, isReplicate, time.Now().Add(timeoutOf(msg, this.nic.Resources())))
+		msg.SetTr_State(ifs.Cleanup)
+		this.tm.requestPhase(msg, targets, this.nic, isReplicate, time.Now().Add(timeoutOf(msg, this.nic.Resources())))
+		this.tm.recordTransition(msg, false, false, nil, this.nic)
+	}
+}
+
+// restorePrepared puts a logged prepared transaction, its key lock, pre-commit snapshot
+// and the elements it creates back, so the participant can still apply, roll back or clean it up.
+func (this *ServiceTransactions) restorePrepared(entry *TransactionLogEntry) {
+	msg := entry.message()
+	pb, err := this.preparedElementsOf(msg)
+	if err != nil {
+		this.nic.Resources().Logger().Error("TransactionRecovery: failed to restore ", entry.TrId, " ", err.Error())
+		return
+	}
+	service, ok := this.nic.Resources().Services().ServiceHandler(msg.ServiceName(), msg.ServiceArea())
+	if !ok || service.TransactionConfig() == nil {
+		return
+	}
+	keys := keysOf(pb, service.TransactionConfig(), this.nic.Resources())
+
+	var snapshot ifs.IElements
+	if len(entry.Snapshot) > 0 {
+		snapshot, err = elementsOfSnapshot(entry.Snapshot, this.nic.Resources())
+		if err != nil {
+			this.nic.Resources().Logger().Error("TransactionRecovery: failed to restore snapshot of ", entry.TrId, " ", err.Error())
+			return
+		}
+	}
+	var absent ifs.IElements
+	if len(entry.Absent) > 0 {
+		absent, err = elementsOfSnapshot(entry.Absent, this.nic.Resources())
+		if err != nil {
+			this.nic.Resources().Logger().Error("TransactionRecovery: failed to restore the created elements of ", entry.TrId, " ", err.Error())
+			return
+		}
+		if msg.Tr_IsReplica() {
+			absent = object.NewReplicaRequest(absent, msg.Tr_Replica())
+		}
+		if snapshot == nil {
+			snapshot = object.New(nil, []interface{}{})
+		}
+	}
+
+	this.preCommitMtx.Lock()
+	defer this.preCommitMtx.Unlock()
+	this.prepared[entry.TrId] = &preparedTransaction{pb: pb, keys: keys, action: ifs.Action(entry.Action),
+		since: time.UnixMilli(entry.Time), applied: ifs.TransactionState(entry.State) == ifs.Committed && !entry.Applying,
+		coordinator: entry.Coordinator}
+	for _, key := range keys {
+		this.locks[key] = entry.TrId
+	}
+	if snapshot != nil {
+		this.preCommit[entry.TrId] = snapshot
+	}
+	if absent != nil {
+		this.absent[entry.TrId] = absent
+	}
+}
@@ -0,0 +1,49 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package states
+
+import (
+	"strconv"
+
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// Status looks up the state, error message and timestamps of a recent transaction.
+// The history is kept on the leader, so a node that is not the leader of the
+// message's service forwards the lookup to it.
+func (this *TransactionManager) Status(query *l8svcs.L8TransactionStatusQuery, msg *ifs.Message, vnic ifs.IVNic) ifs.IElements {
+	if query.TrId == "" {
+		return object.NewError("Status: transaction id is empty")
+	}
+	leader := vnic.Resources().Services().GetLeader(msg.ServiceName(), msg.ServiceArea())
+	if leader == "" {
+		return object.NewError("Status: no leader for service " + msg.ServiceName() +
+			" area " + strconv.Itoa(int(msg.ServiceArea())))
+	}
+	if leader != vnic.Resources().SysConfig().LocalUuid {
+		return vnic.Forward(msg, leader)
+	}
+	status, ok := this.history.Get(query.TrId)
+	if !ok {
+		return object.NewError("Status: transaction " + query.TrId + " was not found or has expired")
+	}
+	return object.New(nil, status)
+}
+
+// History returns the status history of the transactions this node coordinated as a leader.
+func (this *TransactionManager) History() *TransactionHistory {
+	return this.history
+}
@@ -0,0 +1,108 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+func TestBatchTransaction(t *testing.T) {
+	defer reset("TestBatchTransaction")
+	sc := ifs.NewServiceLevelAgreement(&base.BaseService{}, "batch", 0, true, nil)
+	sc.SetServiceItem(&testtypes.TestProto{})
+	sc.SetServiceItemList(&testtypes.TestProtoList{})
+	sc.SetPrimaryKeys("MyString")
+	sc.SetVoter(true)
+	sc.SetTransactional(true)
+
+	activateOnAll(sc)
+	defer deactivateOnAll(sc.ServiceName(), 0)
+	time.Sleep(time.Second)
+
+	nic := topo.VnicByVnetNum(1, 2)
+	batch := object.New(nil, []interface{}{
+		&testtypes.TestProto{MyString: "batch1"},
+		&testtypes.TestProto{MyString: "batch2"},
+		&testtypes.TestProto{MyString: "batch3"},
+	})
+	resp := nic.ProximityRequest(sc.ServiceName(), 0, ifs.POST, batch, 5)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	tr := resp.Element().(*l8services.L8Transaction)
+	if tr.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected batch transaction to commit ", ifs.TransactionState(tr.State), " ", tr.ErrMsg)
+		return
+	}
+
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic = topo.VnicByVnetNum(vnet, vnic)
+			h, _ := nic.Resources().Services().ServiceHandler(sc.ServiceName(), 0)
+			if h.(*base.BaseService).Size() != 3 {
+				Log.Fail(t, nic.Resources().SysConfig().LocalAlias, " Expected the batch of 3 elements ", h.(*base.BaseService).Size())
+				return
+			}
+		}
+	}
+
+	//A batch changing an existing element and creating a new one is rolled back as a whole
+	leader := leaderVnic(ServiceName, 1)
+	if leader == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	handler := topo.TrHandlerByVnetNum(2, 1)
+	handler.SetErrorMode(true)
+	defer handler.SetErrorMode(false)
+	ops := []*states.TransactionOperation{
+		{ServiceName: sc.ServiceName(), ServiceArea: 0, Action: ifs.PUT, Elements: object.New(nil, []interface{}{
+			&testtypes.TestProto{MyString: "batch1", MyInt32: 1},
+			&testtypes.TestProto{MyString: "batch4"},
+		})},
+		{ServiceName: ServiceName, ServiceArea: 1, Action: ifs.PUT, Elements: object.New(nil, &testtypes.TestProto{MyString: "batchFail"})},
+	}
+	tr = leader.Resources().Services().(*manager.ServiceManager).RunMultiServiceTransaction(ops, "", leader)
+	if tr.State != int32(ifs.Failed) {
+		Log.Fail(t, "Expected the failing batch to be rolled back ", ifs.TransactionState(tr.State))
+		return
+	}
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic = topo.VnicByVnetNum(vnet, vnic)
+			h, _ := nic.Resources().Services().ServiceHandler(sc.ServiceName(), 0)
+			if h.(*base.BaseService).Size() != 3 {
+				Log.Fail(t, nic.Resources().SysConfig().LocalAlias, " Expected the created element to be deleted ", h.(*base.BaseService).Size())
+				return
+			}
+			resp = h.Get(object.New(nil, &testtypes.TestProto{MyString: "batch1"}), nic)
+			if resp.Element() == nil || resp.Element().(*testtypes.TestProto).MyInt32 != 0 {
+				Log.Fail(t, nic.Resources().SysConfig().LocalAlias, " Expected the changed element to be restored")
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/manager"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+)
+
+type electionEventRecorder struct {
+	events []*manager.ElectionEvent
+	mtx    sync.Mutex
+}
+
+func (this *electionEventRecorder) OnElectionEvent(event *manager.ElectionEvent) {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.events = append(this.events, event)
+}
+
+func (this *electionEventRecorder) elected(leader string) bool {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	for _, event := range this.events {
+		if event.Type == manager.LeaderElected && event.Leader == leader && event.Epoch != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func TestElectionEvents(t *testing.T) {
+	defer reset("TestElectionEvents")
+
+	nic := leaderVnic(ServiceName, 1)
+	if nic == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	oldLeader := nic.Resources().SysConfig().LocalUuid
+	services := nic.Resources().Services().(*manager.ServiceManager)
+
+	history := services.LeadershipHistory(ServiceName, 1)
+	if len(history) == 0 || history[len(history)-1].Leader != oldLeader || !history[len(history)-1].End.IsZero() {
+		Log.Fail(t, "Expected the history to end with the running term of the leader")
+		return
+	}
+
+	recorder := &electionEventRecorder{}
+	services.SubscribeElectionEvents(recorder)
+	defer services.UnsubscribeElectionEvents(recorder)
+
+	_, err := services.ResignLeadership(ServiceName, 1, nic)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	newLeader := services.GetLeader(ServiceName, 1)
+	time.Sleep(100 * time.Millisecond)
+	if !recorder.elected(newLeader) {
+		Log.Fail(t, "Expected an event electing ", newLeader)
+		return
+	}
+
+	history = services.LeadershipHistory(ServiceName, 1)
+	if len(history) < 2 {
+		Log.Fail(t, "Expected the resigned and the new terms in the history")
+		return
+	}
+	resigned := history[len(history)-2]
+	if resigned.Leader != oldLeader || resigned.EndedBy != manager.LeaderResigned {
+		Log.Fail(t, "Expected the term of the old leader to end by resignation")
+		return
+	}
+	if history[len(history)-1].Leader != newLeader {
+		Log.Fail(t, "Expected the history to end with the term of the new leader")
+		return
+	}
+}
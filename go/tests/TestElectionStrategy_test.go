@@ -0,0 +1,151 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/election"
+	"github.com/saichler/l8services/go/services/manager"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+)
+
+func TestElectionStrategy(t *testing.T) {
+	c := &election.Candidacy{ServiceName: "strategy"}
+
+	uuid := &election.UuidStrategy{}
+	if !uuid.Outranks("b", "a", c) || uuid.Outranks("a", "b", c) {
+		Log.Fail(t, "Expected the higher UUID to win")
+		return
+	}
+
+	weighted := election.NewWeightedStrategy().SetLabels("a", "preferred").SetPriority("preferred", 10)
+	if !weighted.Outranks("a", "b", c) {
+		Log.Fail(t, "Expected the node with the preferred label to win")
+		return
+	}
+	weighted.SetPriority("b", 20)
+	if !weighted.Outranks("b", "a", c) {
+		Log.Fail(t, "Expected the node with the higher priority to win")
+		return
+	}
+
+	lease := election.NewLeaseStrategy(time.Minute, nil)
+	c.Leader = "a"
+	c.LastHeartbeat = time.Now()
+	if !lease.Outranks("a", "b", c) || lease.Eligible("b", c) {
+		Log.Fail(t, "Expected the leader to keep the leadership while its lease is valid")
+		return
+	}
+	c.LastHeartbeat = time.Now().Add(-2 * time.Minute)
+	if !lease.Outranks("b", "a", c) || !lease.Eligible("b", c) {
+		Log.Fail(t, "Expected the fallback strategy once the lease expired")
+		return
+	}
+}
+
+func TestPreferredLeaders(t *testing.T) {
+	//The lowest UUID, which the default strategy never elects
+	var lowest ifs.IVNic
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic := topo.VnicByVnetNum(vnet, vnic)
+			if lowest == nil || nic.Resources().SysConfig().LocalUuid < lowest.Resources().SysConfig().LocalUuid {
+				lowest = nic
+			}
+		}
+	}
+	lowestUuid := lowest.Resources().SysConfig().LocalUuid
+
+	sla := ifs.NewServiceLevelAgreement(&base.BaseService{}, "preferring", 0, true, nil)
+	sla.SetServiceItem(&testtypes.TestProto{})
+	sla.SetServiceItemList(&testtypes.TestProtoList{})
+	sla.SetPrimaryKeys("MyString")
+	sla.SetVoter(true)
+	agreement.OnSLA(sla).SetPreferredLeaders(lowestUuid)
+	activateOnAll(sla)
+	defer deactivateOnAll("preferring", 0)
+	time.Sleep(3 * time.Second)
+
+	leader := leaderVnic("preferring", 0)
+	if leader == nil || leader.Resources().SysConfig().LocalUuid != lowestUuid {
+		Log.Fail(t, "Expected the preferred leader set on the SLA to lead")
+		return
+	}
+
+	//Every node has its own copy of the strategy carried by the SLA
+	first := agreement.For(topo.VnicByVnetNum(1, 1).Resources(), "preferring", 0).ElectionStrategy()
+	second := agreement.For(topo.VnicByVnetNum(1, 2).Resources(), "preferring", 0).ElectionStrategy()
+	if first == second || first == agreement.OnSLA(sla).ElectionStrategy() {
+		Log.Fail(t, "Expected the nodes not to share the election strategy of the SLA")
+		return
+	}
+}
+
+func TestElectionResponseWhileElecting(t *testing.T) {
+	sla := ifs.NewServiceLevelAgreement(&base.BaseService{}, "electing", 0, true, nil)
+	sla.SetServiceItem(&testtypes.TestProto{})
+	sla.SetServiceItemList(&testtypes.TestProtoList{})
+	sla.SetPrimaryKeys("MyString")
+	sla.SetVoter(true)
+	activateOnAll(sla)
+	defer deactivateOnAll("electing", 0)
+	time.Sleep(time.Second)
+
+	leader := leaderVnic("electing", 0)
+	if leader == nil {
+		Log.Fail(t, "No leader for electing")
+		return
+	}
+	var lowest ifs.IVNic
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic := topo.VnicByVnetNum(vnet, vnic)
+			if lowest == nil || nic.Resources().SysConfig().LocalUuid < lowest.Resources().SysConfig().LocalUuid {
+				lowest = nic
+			}
+		}
+	}
+	leaderUuid := leader.Resources().SysConfig().LocalUuid
+	lowestUuid := lowest.Resources().SysConfig().LocalUuid
+
+	//The lowest ranked node starts an election and hears back from the leader while electing
+	services := lowest.Resources().Services().(*manager.ServiceManager)
+	services.StartElection("electing", 0, lowest)
+	time.Sleep(200 * time.Millisecond)
+	leader.Unicast(lowestUuid, "electing", 0, ifs.ElectionResponse, nil)
+
+	done := make(chan string, 1)
+	go func() {
+		done <- services.GetLeader("electing", 0)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		Log.Fail(t, "Expected the election response not to lock the leader info")
+		return
+	}
+
+	//It aborts its election and keeps following the leader
+	time.Sleep(agreement.DefaultElectionTimeout + time.Second)
+	if services.IsLeader("electing", 0, lowestUuid) || services.GetLeader("electing", 0) != leaderUuid {
+		Log.Fail(t, "Expected the lowest ranked node to abort its election")
+		return
+	}
+}
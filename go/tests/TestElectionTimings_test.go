@@ -0,0 +1,80 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/manager"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+)
+
+func TestElectionTimings(t *testing.T) {
+	nic := leaderVnic(ServiceName, 1)
+	if nic == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	leader := nic.Resources().SysConfig().LocalUuid
+	services := nic.Resources().Services().(*manager.ServiceManager)
+	defer agreeOnAll(ServiceName, 1, func(a *agreement.Agreement) {
+		a.SetElectionTimings(agreement.DefaultElectionTimings())
+	})
+
+	invalid := agreement.DefaultElectionTimings()
+	invalid.HeartbeatTimeout = invalid.HeartbeatPeriod
+	if services.SetElectionTimings(ServiceName, 1, invalid) == nil {
+		Log.Fail(t, "Expected a heartbeat timeout that does not exceed the period to be rejected")
+		return
+	}
+
+	fast := agreement.ElectionTimings{
+		ElectionTimeout:  time.Second,
+		HeartbeatTimeout: 2 * time.Second,
+		HeartbeatPeriod:  500 * time.Millisecond,
+		DebounceWindow:   100 * time.Millisecond,
+	}
+	err := services.SetElectionTimings(ServiceName, 1, fast)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	if services.ElectionTimings(ServiceName, 1) != fast {
+		Log.Fail(t, "Expected the new election timings to be set")
+		return
+	}
+
+	//Every node keeps its own timings
+	for vnet := 1; vnet <= 3; vnet++ {
+		other := topo.VnicByVnetNum(vnet, 1)
+		if other == nic {
+			continue
+		}
+		if other.Resources().Services().(*manager.ServiceManager).ElectionTimings(ServiceName, 1) != agreement.DefaultElectionTimings() {
+			Log.Fail(t, "Expected the timings of another node to be kept")
+			return
+		}
+	}
+	agreeOnAll(ServiceName, 1, func(a *agreement.Agreement) { a.SetElectionTimings(fast) })
+
+	//The running heartbeats and monitors pick up the new timings and keep the leader
+	time.Sleep(3 * time.Second)
+	if services.GetLeader(ServiceName, 1) != leader {
+		Log.Fail(t, "Expected the leader to be kept after changing the timings")
+		return
+	}
+}
@@ -0,0 +1,215 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/services/replication"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+// hashedKeys is the number of keys the rebalance test places on the hash ring.
+const hashedKeys = 30
+
+func TestHashPlacement(t *testing.T) {
+	if !waitForReplicationServices(t) {
+		return
+	}
+
+	first := topo.VnicByVnetNum(1, 1).Resources().Services().(*manager.ServiceManager)
+	second := topo.VnicByVnetNum(2, 2).Resources().Services().(*manager.ServiceManager)
+	for i := 0; i < 10; i++ {
+		key := "key" + strconv.Itoa(i)
+		targets := first.HashParticipants(ServiceName, 2, key, 2)
+		if len(targets) != 2 {
+			Log.Fail(t, "Expected 2 replicas for ", key, " got ", len(targets))
+			return
+		}
+		again := second.HashParticipants(ServiceName, 2, key, 2)
+		if len(again) != len(targets) {
+			Log.Fail(t, "Expected the nodes to place ", key, " on the same replicas")
+			return
+		}
+		for uuid, replica := range targets {
+			if r, ok := again[uuid]; !ok || r != replica {
+				Log.Fail(t, "Expected the nodes to place ", key, " on the same replicas")
+				return
+			}
+		}
+	}
+}
+
+func TestHashRebalance(t *testing.T) {
+	defer reset("TestHashRebalance")
+	sla := ifs.NewServiceLevelAgreement(&base.BaseService{}, "hashed", 0, true, nil)
+	sla.SetServiceItem(&testtypes.TestProto{})
+	sla.SetServiceItemList(&testtypes.TestProtoList{})
+	sla.SetPrimaryKeys("MyString")
+	sla.SetVoter(true)
+	sla.SetTransactional(true)
+	sla.SetReplication(true)
+	sla.SetReplicationCount(2)
+	agreement.OnSLA(sla).SetReplicaPlacement(agreement.PlacementConsistentHash, 0)
+	joiner := topo.VnicByVnetNum(3, 3)
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			if nic := topo.VnicByVnetNum(vnet, vnic); nic != joiner {
+				base.Activate(sla, nic)
+			}
+		}
+	}
+	defer deactivateOnAll("hashed", 0)
+	time.Sleep(3 * time.Second)
+
+	//The transactions place the replicas of the new keys on the hash ring
+	nic := leaderVnic("hashed", 0)
+	if nic == nil {
+		Log.Fail(t, "No leader for hashed")
+		return
+	}
+	for i := 0; i < hashedKeys; i++ {
+		resp := nic.ProximityRequest("hashed", 0, ifs.POST, &testtypes.TestProto{MyString: "hashed" + strconv.Itoa(i), MyInt32: int32(i)}, 5)
+		if resp == nil || resp.Error() != nil {
+			Log.Fail(t, "Expected a response to the transaction")
+			return
+		}
+		tr := resp.Element().(*l8services.L8Transaction)
+		if tr.State != int32(ifs.Committed) {
+			Log.Fail(t, "Expected the transaction to commit ", ifs.TransactionState(tr.State), " ", tr.ErrMsg)
+			return
+		}
+	}
+	if misplaced := hashMisplaced(); misplaced != 0 {
+		Log.Fail(t, "Expected the keys on the replicas of the hash ring, ", misplaced, " are not")
+		return
+	}
+
+	//A node joining the service takes over only the keys the ring places on it
+	joinerUuid := joiner.Resources().SysConfig().LocalUuid
+	added := time.Now()
+	base.Activate(sla, joiner)
+	report := waitRebalanced(added)
+	if report == nil {
+		Log.Fail(t, "Expected the keys to be moved once the node joined, ", hashMisplaced(), " are misplaced")
+		return
+	}
+	if report.Moved == 0 || report.Moved == hashedKeys || report.Moved != len(report.Moves) {
+		Log.Fail(t, "Expected some of the keys to move to the joining node, moved ", report.Moved, " of ", len(report.Moves))
+		return
+	}
+	for _, move := range report.Moves {
+		if _, ok := move.To[joinerUuid]; !ok {
+			Log.Fail(t, "Expected key ", move.Key, " to move to the joining node only")
+			return
+		}
+	}
+
+	//A node leaving the service hands its keys to the next nodes on the ring
+	removed := time.Now()
+	joiner.Resources().Services().DeActivate("hashed", 0, joiner.Resources(), joiner)
+	report = waitRebalanced(removed)
+	if report == nil {
+		Log.Fail(t, "Expected the keys to be moved once the node left, ", hashMisplaced(), " are misplaced")
+		return
+	}
+	if report.Moved == 0 || report.Moved == hashedKeys || report.Moved != len(report.Moves) {
+		Log.Fail(t, "Expected the keys of the leaving node to move, moved ", report.Moved, " of ", len(report.Moves))
+		return
+	}
+	for _, move := range report.Moves {
+		if _, ok := move.From[joinerUuid]; !ok {
+			Log.Fail(t, "Expected key ", move.Key, " to move off the leaving node only")
+			return
+		}
+	}
+}
+
+// waitRebalanced waits until the leader of the hashed service moved its keys after the
+// given time, and all of them are on the replicas of the hash ring. Returns the report of
+// the moves, nil if they did not complete in time.
+func waitRebalanced(since time.Time) *states.RebalanceReport {
+	for i := 0; i < 30; i++ {
+		time.Sleep(time.Second)
+		nic := leaderVnic("hashed", 0)
+		if nic == nil {
+			continue
+		}
+		report := nic.Resources().Services().(*manager.ServiceManager).Rebalanced("hashed", 0)
+		if report != nil && report.Time.After(since) && hashMisplaced() == 0 {
+			return report
+		}
+	}
+	return nil
+}
+
+// hashMisplaced returns the number of keys of the hashed service that the replication
+// index, or the participants' elements, do not place on the replicas of the hash ring.
+func hashMisplaced() int {
+	nic := leaderVnic("hashed", 0)
+	if nic == nil {
+		return hashedKeys
+	}
+	sm := nic.Resources().Services().(*manager.ServiceManager)
+	index := replication.ReplicationIndex("hashed", 0, nic.Resources())
+	if index == nil {
+		return hashedKeys
+	}
+	misplaced := 0
+	for i := 0; i < hashedKeys; i++ {
+		key := "hashed" + strconv.Itoa(i)
+		expected := sm.HashParticipants("hashed", 0, key, 2)
+		location, ok := index.Keys[key]
+		if !ok || len(location.Location) != len(expected) {
+			misplaced++
+			continue
+		}
+		for uuid := range expected {
+			if _, ok := location.Location[uuid]; !ok || !holds(uuid, key) {
+				misplaced++
+				break
+			}
+		}
+	}
+	return misplaced
+}
+
+// holds returns true if the node holds the key of the hashed service.
+func holds(uuid string, key string) bool {
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic := topo.VnicByVnetNum(vnet, vnic)
+			if nic.Resources().SysConfig().LocalUuid != uuid {
+				continue
+			}
+			h, ok := nic.Resources().Services().ServiceHandler("hashed", 0)
+			if !ok {
+				return false
+			}
+			_, ok = h.(*base.BaseService).All()[key]
+			return ok
+		}
+	}
+	return false
+}
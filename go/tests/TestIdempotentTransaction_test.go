@@ -0,0 +1,140 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+// idempotentService creates the SLA of a transactional service remembering the
+// idempotency keys of its writes for the given window.
+func idempotentService(name string, window time.Duration) *ifs.ServiceLevelAgreement {
+	sc := ifs.NewServiceLevelAgreement(&base.BaseService{}, name, 0, true, nil)
+	sc.SetServiceItem(&testtypes.TestProto{})
+	sc.SetServiceItemList(&testtypes.TestProtoList{})
+	sc.SetPrimaryKeys("MyString")
+	sc.SetVoter(true)
+	sc.SetTransactional(true)
+	agreement.OnSLA(sc).SetIdempotencyWindow(window)
+	return sc
+}
+
+// idempotentPost posts an element with the given idempotency key.
+func idempotentPost(nic ifs.IVNic, serviceName, key string, pb *testtypes.TestProto) ifs.IElements {
+	request, err := states.NewIdempotentRequest(key, pb)
+	if err != nil {
+		return object.NewError(err.Error())
+	}
+	return nic.ProximityRequest(serviceName, 0, ifs.POST, request, 5)
+}
+
+func TestIdempotentTransaction(t *testing.T) {
+	defer reset("TestIdempotentTransaction")
+	sc := idempotentService("idem", 5*time.Minute)
+
+	activateOnAll(sc)
+	defer deactivateOnAll(sc.ServiceName(), 0)
+	time.Sleep(time.Second)
+
+	nic := topo.VnicByVnetNum(1, 2)
+	pb := &testtypes.TestProto{MyString: "idempotent"}
+	first := idempotentPost(nic, sc.ServiceName(), "request-1", pb)
+	if first != nil && first.Error() != nil {
+		Log.Fail(t, first.Error().Error())
+		return
+	}
+	retry := idempotentPost(nic, sc.ServiceName(), "request-1", pb)
+	if retry != nil && retry.Error() != nil {
+		Log.Fail(t, retry.Error().Error())
+		return
+	}
+
+	firstTr := first.Element().(*l8services.L8Transaction)
+	retryTr := retry.Element().(*l8services.L8Transaction)
+	if firstTr.Id != retryTr.Id {
+		Log.Fail(t, "Expected the retry to return the original transaction ", firstTr.Id, " ", retryTr.Id)
+		return
+	}
+	if retryTr.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected the original transaction result ", ifs.TransactionState(retryTr.State))
+		return
+	}
+
+	//A retry sent while the original is still running gets its final result
+	running := make(chan ifs.IElements, 1)
+	go func() {
+		running <- idempotentPost(nic, sc.ServiceName(), "request-2", &testtypes.TestProto{MyString: "running"})
+	}()
+	retry = idempotentPost(nic, sc.ServiceName(), "request-2", &testtypes.TestProto{MyString: "running"})
+	first = <-running
+	if retry.Error() != nil || first.Error() != nil {
+		Log.Fail(t, "Failed to post while running")
+		return
+	}
+	firstTr = first.Element().(*l8services.L8Transaction)
+	retryTr = retry.Element().(*l8services.L8Transaction)
+	if firstTr.Id != retryTr.Id || retryTr.State != int32(ifs.Committed) || firstTr.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected both requests to get the committed original ", firstTr.Id, " ", retryTr.Id, " ",
+			ifs.TransactionState(firstTr.State), " ", ifs.TransactionState(retryTr.State))
+		return
+	}
+}
+
+func TestIdempotencyWindows(t *testing.T) {
+	defer reset("TestIdempotencyWindows")
+	long := idempotentService("idemlong", 5*time.Minute)
+	short := idempotentService("idemshort", time.Second)
+	activateOnAll(long)
+	defer deactivateOnAll(long.ServiceName(), 0)
+	activateOnAll(short)
+	defer deactivateOnAll(short.ServiceName(), 0)
+	time.Sleep(time.Second)
+
+	//A key of the long window service is held ahead of the short window one
+	nic := topo.VnicByVnetNum(1, 2)
+	resp := idempotentPost(nic, long.ServiceName(), "held", &testtypes.TestProto{MyString: "held"})
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	pb := &testtypes.TestProto{MyString: "windowed"}
+	first := idempotentPost(nic, short.ServiceName(), "windowed", pb)
+	if first != nil && first.Error() != nil {
+		Log.Fail(t, first.Error().Error())
+		return
+	}
+	time.Sleep(2 * time.Second)
+
+	retry := idempotentPost(nic, short.ServiceName(), "windowed", pb)
+	if retry != nil && retry.Error() != nil {
+		Log.Fail(t, retry.Error().Error())
+		return
+	}
+	firstTr := first.Element().(*l8services.L8Transaction)
+	retryTr := retry.Element().(*l8services.L8Transaction)
+	if firstTr.Id == retryTr.Id {
+		Log.Fail(t, "Expected a retry after the window to run a new transaction ", firstTr.Id)
+		return
+	}
+}
@@ -0,0 +1,152 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+type leaderAwareService struct {
+	leading int32
+	running int32
+	overlap int32
+}
+
+func (this *leaderAwareService) Activate(sla *ifs.ServiceLevelAgreement, vnic ifs.IVNic) error {
+	return nil
+}
+func (this *leaderAwareService) DeActivate() error { return nil }
+func (this *leaderAwareService) Post(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.New(nil, nil)
+}
+func (this *leaderAwareService) Put(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.New(nil, nil)
+}
+func (this *leaderAwareService) Patch(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.New(nil, nil)
+}
+func (this *leaderAwareService) Delete(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.New(nil, nil)
+}
+func (this *leaderAwareService) Get(pb ifs.IElements, vnic ifs.IVNic) ifs.IElements {
+	return object.New(nil, nil)
+}
+func (this *leaderAwareService) Failed(pb ifs.IElements, vnic ifs.IVNic, msg *ifs.Message) ifs.IElements {
+	return nil
+}
+func (this *leaderAwareService) TransactionConfig() ifs.ITransactionConfig { return nil }
+func (this *leaderAwareService) WebService() ifs.IWebService               { return nil }
+
+func (this *leaderAwareService) OnBecomeLeader(vnic ifs.IVNic) {
+	this.enter()
+	atomic.StoreInt32(&this.leading, 1)
+	this.exit()
+}
+
+func (this *leaderAwareService) OnLoseLeadership(vnic ifs.IVNic) {
+	this.enter()
+	atomic.StoreInt32(&this.leading, 0)
+	this.exit()
+}
+
+func (this *leaderAwareService) enter() {
+	if atomic.AddInt32(&this.running, 1) > 1 {
+		atomic.StoreInt32(&this.overlap, 1)
+	}
+	time.Sleep(10 * time.Millisecond)
+}
+
+func (this *leaderAwareService) exit() {
+	atomic.AddInt32(&this.running, -1)
+}
+
+func TestLeaderCallbacks(t *testing.T) {
+	defer reset("TestLeaderCallbacks")
+
+	handlers := make(map[string]*leaderAwareService)
+	nics := make(map[string]ifs.IVNic)
+	for vnic := 1; vnic <= 3; vnic++ {
+		nic := topo.VnicByVnetNum(1, vnic)
+		services := nic.Resources().Services().(*manager.ServiceManager)
+		services.RegisterServiceHandlerType(&leaderAwareService{})
+		sla := ifs.NewServiceLevelAgreement(&leaderAwareService{}, "ldraware", 0, true, nil)
+		h, err := services.Activate(sla, nic)
+		if err != nil {
+			Log.Fail(t, err.Error())
+			return
+		}
+		uuid := nic.Resources().SysConfig().LocalUuid
+		handlers[uuid] = h.(*leaderAwareService)
+		nics[uuid] = nic
+		defer services.DeActivate("ldraware", 0, nic.Resources(), nic)
+	}
+	time.Sleep(8 * time.Second)
+
+	leader := ""
+	for uuid, h := range handlers {
+		if atomic.LoadInt32(&h.leading) == 1 {
+			if leader != "" {
+				Log.Fail(t, "Expected a single handler to be told it leads")
+				return
+			}
+			leader = uuid
+		}
+	}
+	if leader == "" || nics[leader].Resources().Services().GetLeader("ldraware", 0) != leader {
+		Log.Fail(t, "Expected the handler of the leader to be told it leads")
+		return
+	}
+
+	_, err := nics[leader].Resources().Services().(*manager.ServiceManager).ResignLeadership("ldraware", 0, nics[leader])
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	time.Sleep(time.Second)
+	if atomic.LoadInt32(&handlers[leader].leading) != 0 {
+		Log.Fail(t, "Expected the handler of the resigned leader to be told it lost the leadership")
+		return
+	}
+	newLeader := nics[leader].Resources().Services().GetLeader("ldraware", 0)
+	if h, ok := handlers[newLeader]; ok && atomic.LoadInt32(&h.leading) != 1 {
+		Log.Fail(t, "Expected the handler of the new leader to be told it leads")
+		return
+	}
+
+	//Deactivating the service on the leader tells its handler it lost the leadership
+	if h, ok := handlers[newLeader]; ok {
+		err = nics[newLeader].Resources().Services().DeActivate("ldraware", 0, nics[newLeader].Resources(), nics[newLeader])
+		if err != nil {
+			Log.Fail(t, err.Error())
+			return
+		}
+		if atomic.LoadInt32(&h.leading) != 0 {
+			Log.Fail(t, "Expected the handler of a deactivated leader to be told it lost the leadership")
+			return
+		}
+	}
+	for _, h := range handlers {
+		if atomic.LoadInt32(&h.overlap) != 0 {
+			Log.Fail(t, "Expected the callbacks of a service never to run concurrently")
+			return
+		}
+	}
+}
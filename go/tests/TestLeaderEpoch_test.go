@@ -0,0 +1,112 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+func TestLeaderEpoch(t *testing.T) {
+	nic := leaderVnic(ServiceName, 1)
+	if nic == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	leader := nic.Resources().SysConfig().LocalUuid
+	epoch, claimedBy := nic.Resources().Services().(*manager.ServiceManager).Epoch(ServiceName, 1)
+	if epoch == 0 || claimedBy != leader {
+		Log.Fail(t, "Expected the leader to hold the highest epoch")
+		return
+	}
+
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			peer := topo.VnicByVnetNum(vnet, vnic)
+			peerEpoch, peerLeader := peer.Resources().Services().(*manager.ServiceManager).Epoch(ServiceName, 1)
+			if peerEpoch != epoch || peerLeader != leader {
+				Log.Fail(t, "Expected ", peer.Resources().SysConfig().LocalAlias, " to know the leader's epoch")
+				return
+			}
+		}
+	}
+}
+
+func TestStaleCommitRejected(t *testing.T) {
+	defer reset("TestStaleCommitRejected")
+
+	leader := leaderVnic(ServiceName, 1)
+	if leader == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	epoch, _ := leader.Resources().Services().(*manager.ServiceManager).Epoch(ServiceName, 1)
+	vnet, vnic := 1, 1
+	if topo.VnicByVnetNum(vnet, vnic) == leader {
+		vnic = 2
+	}
+	participant := topo.VnicByVnetNum(vnet, vnic)
+	handler := topo.TrHandlerByVnetNum(vnet, vnic)
+	before := handler.PutN()
+
+	data, err := object.New(nil, &testtypes.TestProto{MyString: "stale"}).Serialize()
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	encData, err := leader.Resources().Security().Encrypt(data)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	msg := &ifs.Message{}
+	msg.SetSource(leader.Resources().SysConfig().LocalUuid)
+	msg.SetServiceName(ServiceName)
+	msg.SetServiceArea(1)
+	msg.SetAction(ifs.PUT)
+	msg.SetData(encData)
+	msg.SetTr_Id(ifs.NewUuid())
+	msg.SetTr_State(ifs.Committed)
+	msg.SetTr_Timeout(5)
+
+	//A commit sent with an epoch older than the leader's is rejected
+	stale, err := states.WithEpoch(msg, epoch-1, leader.Resources())
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	resp := leader.Forward(stale, participant.Resources().SysConfig().LocalUuid)
+	if resp == nil || resp.Error() != nil {
+		Log.Fail(t, "Expected the participant to answer the stale commit")
+		return
+	}
+	tr, ok := resp.Element().(*l8services.L8Transaction)
+	if !ok || tr.State != int32(ifs.Failed) || !strings.Contains(tr.ErrMsg, "stale epoch") {
+		Log.Fail(t, "Expected the stale commit to be rejected")
+		return
+	}
+	if handler.PutN() != before {
+		Log.Fail(t, "Expected the stale commit not to be applied")
+		return
+	}
+}
@@ -0,0 +1,192 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+func TestLeaderHandoff(t *testing.T) {
+	defer reset("TestLeaderHandoff")
+
+	nic := leaderVnic(ServiceName, 1)
+	if nic == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	oldLeader := nic.Resources().SysConfig().LocalUuid
+	services := nic.Resources().Services().(*manager.ServiceManager)
+
+	_, err := services.ResignLeadership(ServiceName, 1, nic)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	newLeader := services.GetLeader(ServiceName, 1)
+	if newLeader == "" || newLeader == oldLeader {
+		Log.Fail(t, "Expected a new leader after resign ", newLeader)
+		return
+	}
+
+	_, err = services.ResignLeadership(ServiceName, 1, nic)
+	if err == nil {
+		Log.Fail(t, "Expected resign of a node that is not the leader to fail")
+		return
+	}
+
+	//A transaction that reaches the old leader is handed off to the new one
+	resp := nic.ProximityRequest(ServiceName, 1, ifs.PUT, &testtypes.TestProto{MyString: "handoff"}, 5)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	tr := resp.Element().(*l8services.L8Transaction)
+	if tr.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected handed off transaction to commit ", ifs.TransactionState(tr.State), " ", tr.ErrMsg)
+		return
+	}
+}
+
+func TestResolveInDoubt(t *testing.T) {
+	defer reset("TestResolveInDoubt")
+	sla := ifs.NewServiceLevelAgreement(&base.BaseService{}, "indoubt", 0, true, nil)
+	sla.SetServiceItem(&testtypes.TestProto{})
+	sla.SetServiceItemList(&testtypes.TestProtoList{})
+	sla.SetPrimaryKeys("MyString")
+	sla.SetVoter(true)
+	sla.SetTransactional(true)
+	activateOnAll(sla)
+	defer deactivateOnAll("indoubt", 0)
+	time.Sleep(time.Second)
+
+	leader := leaderVnic("indoubt", 0)
+	if leader == nil {
+		Log.Fail(t, "No leader for indoubt")
+		return
+	}
+	others := make([]ifs.IVNic, 0)
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic := topo.VnicByVnetNum(vnet, vnic)
+			if nic != leader && len(others) < 2 {
+				others = append(others, nic)
+			}
+		}
+	}
+	holder, coordinator := others[0], others[1]
+
+	//The holder prepared a transaction the coordinator decided to commit, and one nobody knows
+	logOf := func(nic ifs.IVNic, name string, entries ...*states.TransactionLogEntry) bool {
+		trLog, err := states.NewFileTransactionLog(filepath.Join(t.TempDir(), name))
+		if err != nil {
+			Log.Fail(t, err.Error())
+			return false
+		}
+		for _, entry := range entries {
+			trLog.Append(entry)
+		}
+		err = nic.Resources().Services().(*manager.ServiceManager).SetTransactionLog(trLog, nic)
+		if err != nil {
+			Log.Fail(t, err.Error())
+			return false
+		}
+		return true
+	}
+	decided, err := walElement(holder, "decided")
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	orphan, err := walElement(holder, "orphan")
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	now := time.Now().UnixMilli()
+	if !logOf(holder, "holder.log",
+		&states.TransactionLogEntry{TrId: "indoubt-decided", State: int32(ifs.Running), ServiceName: "indoubt",
+			Action: int32(ifs.POST), Participant: true, Data: decided, Time: now,
+			Coordinator: coordinator.Resources().SysConfig().LocalUuid},
+		&states.TransactionLogEntry{TrId: "indoubt-orphan", State: int32(ifs.Running), ServiceName: "indoubt",
+			Action: int32(ifs.POST), Participant: true, Data: orphan, Time: now}) {
+		return
+	}
+	defer holder.Resources().Services().(*manager.ServiceManager).SetTransactionLog(nil, holder)
+	if !logOf(coordinator, "coordinator.log",
+		&states.TransactionLogEntry{TrId: "indoubt-decided", State: int32(ifs.Committed), ServiceName: "indoubt",
+			Action: int32(ifs.POST), Data: decided, Time: now}) {
+		return
+	}
+	defer coordinator.Resources().Services().(*manager.ServiceManager).SetTransactionLog(nil, coordinator)
+
+	resp := leader.Request(holder.Resources().SysConfig().LocalUuid, "indoubt", 0, ifs.GET, &l8svcs.L8InDoubtQuery{}, 5)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	list, ok := resp.Element().(*l8svcs.L8InDoubtTransactionList)
+	if !ok || len(list.List) != 2 {
+		Log.Fail(t, "Expected the holder to list its in-doubt transactions")
+		return
+	}
+	resp = leader.Request(coordinator.Resources().SysConfig().LocalUuid, "indoubt", 0, ifs.GET,
+		&l8svcs.L8DecisionQuery{TrId: "indoubt-decided"}, 5)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	decision, ok := resp.Element().(*l8svcs.L8InDoubtTransaction)
+	if !ok || decision.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected the coordinator to answer its logged decision")
+		return
+	}
+
+	//A new leader commits what the coordinator decided, and rolls back what too few voted on
+	err = leadWith("indoubt", 0, holder)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	time.Sleep(2 * time.Second)
+	h, _ := holder.Resources().Services().ServiceHandler("indoubt", 0)
+	resp = h.Get(object.New(nil, &testtypes.TestProto{MyString: "decided"}), holder)
+	if resp.Element() == nil {
+		Log.Fail(t, "Expected the decided transaction to be committed")
+		return
+	}
+	resp = h.Get(object.New(nil, &testtypes.TestProto{MyString: "orphan"}), holder)
+	if resp.Element() != nil {
+		Log.Fail(t, "Expected the orphaned transaction to be rolled back")
+		return
+	}
+	resp = leader.Request(holder.Resources().SysConfig().LocalUuid, "indoubt", 0, ifs.GET, &l8svcs.L8InDoubtQuery{}, 5)
+	list, ok = resp.Element().(*l8svcs.L8InDoubtTransactionList)
+	if !ok || len(list.List) != 0 {
+		Log.Fail(t, "Expected no transactions left in doubt")
+		return
+	}
+}
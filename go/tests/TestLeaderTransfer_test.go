@@ -0,0 +1,117 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/election"
+	"github.com/saichler/l8services/go/services/leadership"
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+// preferred returns the node preferred in the election of a service by any node, if any.
+func preferred(serviceName string, serviceArea byte) string {
+	node := ""
+	agreeOnAll(serviceName, serviceArea, func(a *agreement.Agreement) {
+		strategy, ok := a.ElectionStrategy().(*election.PreferredStrategy)
+		if ok && strategy.Preferred() != "" {
+			node = strategy.Preferred()
+		}
+	})
+	return node
+}
+
+func TestLeaderTransfer(t *testing.T) {
+	defer reset("TestLeaderTransfer")
+
+	nic := leaderVnic(ServiceName, 1)
+	if nic == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	oldLeader := nic.Resources().SysConfig().LocalUuid
+	services := nic.Resources().Services().(*manager.ServiceManager)
+
+	target := ""
+	for node := range services.GetParticipants(ServiceName, 1) {
+		if node != oldLeader && node > target {
+			target = node
+		}
+	}
+	if target == "" {
+		Log.Fail(t, "Expected another participant for ", ServiceName)
+		return
+	}
+
+	_, err := services.TransferLeadership(ServiceName, 1, oldLeader, nic)
+	if err == nil {
+		Log.Fail(t, "Expected transfer to the leader itself to fail")
+		return
+	}
+
+	_, err = services.TransferLeadership(ServiceName, 1, target, nic)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	if services.GetLeader(ServiceName, 1) != target {
+		Log.Fail(t, "Expected ", target, " to lead after the transfer")
+		return
+	}
+	if preferred(ServiceName, 1) != "" {
+		Log.Fail(t, "Expected the preference of the successor to be cleared once it took over")
+		return
+	}
+
+	_, _, err = services.StepDown(ServiceName, 1, nic)
+	if err == nil {
+		Log.Fail(t, "Expected step down of a node that is not the leader to fail")
+		return
+	}
+
+	//A node that is not the leader forwards the admin request to the leader
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			node := topo.VnicByVnetNum(vnet, vnic)
+			sla := ifs.NewServiceLevelAgreement(&leadership.LeadershipAdminService{}, leadership.ServiceName, leadership.ServiceArea, false, nil)
+			node.Resources().Services().Activate(sla, node)
+		}
+	}
+	defer deactivateOnAll(leadership.ServiceName, leadership.ServiceArea)
+	client := topo.VnicByVnetNum(1, 1)
+	if client == nic {
+		client = topo.VnicByVnetNum(2, 1)
+	}
+	resp := client.Request(oldLeader, leadership.ServiceName, leadership.ServiceArea, ifs.POST,
+		&l8svcs.L8LeadershipRequest{ServiceName: ServiceName, ServiceArea: 1, Target: oldLeader}, 30)
+	if resp == nil || resp.Error() != nil {
+		Log.Fail(t, "Expected the admin request to move the leadership back ", resp)
+		return
+	}
+	result, ok := resp.Element().(*l8svcs.L8LeadershipRequest)
+	if !ok || result.Leader != oldLeader {
+		Log.Fail(t, "Expected ", oldLeader, " to lead after the admin request")
+		return
+	}
+	if services.GetLeader(ServiceName, 1) != oldLeader || preferred(ServiceName, 1) != "" {
+		Log.Fail(t, "Expected ", oldLeader, " to lead without a preference left behind")
+		return
+	}
+}
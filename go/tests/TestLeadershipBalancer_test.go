@@ -0,0 +1,140 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/manager"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+)
+
+// balancerOf returns the leadership balancer of a node.
+func balancerOf(nic ifs.IVNic) *manager.LeadershipBalancer {
+	return nic.Resources().Services().(*manager.ServiceManager).LeadershipBalancer()
+}
+
+// balancedPlan returns the leaders all the nodes plan for the services, keyed by service
+// name, or nil if two nodes planned different leaders.
+func balancedPlan(names []string) map[string]string {
+	result := make(map[string]string)
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			plan := balancerOf(topo.VnicByVnetNum(vnet, vnic)).Plan()
+			for _, name := range names {
+				target := plan[name+"-0"]
+				planned, ok := result[name]
+				if target == "" || (ok && planned != target) {
+					return nil
+				}
+				result[name] = target
+			}
+		}
+	}
+	return result
+}
+
+func TestLeadershipBalancer(t *testing.T) {
+	defer reset("TestLeadershipBalancer")
+
+	names := make([]string, 0)
+	for i := 0; i < 8; i++ {
+		name := "balanced" + strconv.Itoa(i)
+		sla := ifs.NewServiceLevelAgreement(&base.BaseService{}, name, 0, true, nil)
+		sla.SetServiceItem(&testtypes.TestProto{})
+		sla.SetServiceItemList(&testtypes.TestProtoList{})
+		sla.SetPrimaryKeys("MyString")
+		sla.SetVoter(true)
+		activateOnAll(sla)
+		defer deactivateOnAll(name, 0)
+		names = append(names, name)
+	}
+	time.Sleep(3 * time.Second)
+
+	plan := balancedPlan(names)
+	if plan == nil {
+		Log.Fail(t, "Expected all the nodes to plan the same leaders")
+		return
+	}
+	planned := make(map[string]bool)
+	for _, target := range plan {
+		planned[target] = true
+	}
+	if len(planned) < 2 {
+		Log.Fail(t, "Expected the plan to spread the leaderships, all planned to ", len(planned), " node")
+		return
+	}
+
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic := topo.VnicByVnetNum(vnet, vnic)
+			balancerOf(nic).Balance(nic)
+		}
+	}
+
+	balanced := false
+	for i := 0; i < 10 && !balanced; i++ {
+		time.Sleep(time.Second)
+		balanced = true
+		for _, name := range names {
+			leader := leaderVnic(name, 0)
+			if leader == nil || leader.Resources().SysConfig().LocalUuid != plan[name] {
+				balanced = false
+				break
+			}
+		}
+	}
+	if !balanced {
+		Log.Fail(t, "Expected the planned nodes to lead the services after balancing")
+		return
+	}
+	leaders := make(map[string]bool)
+	for _, name := range names {
+		leaders[leaderVnic(name, 0).Resources().SysConfig().LocalUuid] = true
+	}
+	if len(leaders) < 2 {
+		Log.Fail(t, "Expected the leaderships to spread across the nodes")
+		return
+	}
+	//The planned leaders stay preferred after they took over
+	for _, name := range names {
+		if preferred(name, 0) != plan[name] {
+			Log.Fail(t, "Expected ", plan[name], " to stay the preferred leader of ", name)
+			return
+		}
+	}
+
+	//A node with weight 0 announces it and leads no service in any node's plan
+	nic := leaderVnic(names[0], 0)
+	uuid := nic.Resources().SysConfig().LocalUuid
+	balancerOf(nic).SetWeight(0, nic)
+	defer balancerOf(nic).SetWeight(1, nic)
+	time.Sleep(time.Second)
+	plan = balancedPlan(names)
+	if plan == nil {
+		Log.Fail(t, "Expected all the nodes to plan the same leaders after the weight change")
+		return
+	}
+	for _, target := range plan {
+		if target == uuid {
+			Log.Fail(t, "Expected a node with weight 0 to lead no service")
+			return
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+// activateMulti activates the "multi" service on all the nodes.
+func activateMulti() {
+	sla := ifs.NewServiceLevelAgreement(&base.BaseService{}, "multi", 0, true, nil)
+	sla.SetServiceItem(&testtypes.TestProto{})
+	sla.SetServiceItemList(&testtypes.TestProtoList{})
+	sla.SetPrimaryKeys("MyString")
+	sla.SetVoter(true)
+	sla.SetTransactional(true)
+	activateOnAll(sla)
+	time.Sleep(time.Second)
+}
+
+// coordinatorVnic returns a node of the topology that leads neither the test service nor
+// the "multi" service, to coordinate a multi-service transaction.
+func coordinatorVnic() ifs.IVNic {
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic := topo.VnicByVnetNum(vnet, vnic)
+			if nic != leaderVnic(ServiceName, 1) && nic != leaderVnic("multi", 0) {
+				return nic
+			}
+		}
+	}
+	return nil
+}
+
+// multiSize returns the number of elements of the "multi" service on every node.
+func multiSize() []int {
+	sizes := make([]int, 0)
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			h, _ := topo.VnicByVnetNum(vnet, vnic).Resources().Services().ServiceHandler("multi", 0)
+			sizes = append(sizes, h.(*base.BaseService).Size())
+		}
+	}
+	return sizes
+}
+
+func TestMultiServiceTransaction(t *testing.T) {
+	defer reset("TestMultiServiceTransaction")
+
+	activateMulti()
+	defer deactivateOnAll("multi", 0)
+	nic := coordinatorVnic()
+
+	before := topo.TrHandlerByVnetNum(1, 3).PutN()
+	ops := []*states.TransactionOperation{
+		{ServiceName: ServiceName, ServiceArea: 1, Action: ifs.PUT, Elements: object.New(nil, &testtypes.TestProto{MyString: "multi1"})},
+		{ServiceName: "multi", ServiceArea: 0, Action: ifs.POST, Elements: object.New(nil, &testtypes.TestProto{MyString: "multi2"})},
+	}
+	tr := nic.Resources().Services().(*manager.ServiceManager).RunMultiServiceTransaction(ops, "multi-1", nic)
+	if tr.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected multi service transaction to commit ", ifs.TransactionState(tr.State), " ", tr.ErrMsg)
+		return
+	}
+	//A retry of the batch with the same key is not applied again
+	retry := nic.Resources().Services().(*manager.ServiceManager).RunMultiServiceTransaction(ops, "multi-1", nic)
+	if retry.Id != tr.Id || retry.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected the retry to return the original batch ", tr.Id, " ", retry.Id)
+		return
+	}
+	if topo.TrHandlerByVnetNum(1, 3).PutN() != before+1 {
+		Log.Fail(t, "Expected 1 more put ", topo.TrHandlerByVnetNum(1, 3).PutN())
+		return
+	}
+	for _, size := range multiSize() {
+		if size != 1 {
+			Log.Fail(t, "Expected the multi service to hold 1 element ", multiSize())
+			return
+		}
+	}
+
+	//The test service fails the batch, so the element added to the multi service is reverted
+	handler := topo.TrHandlerByVnetNum(2, 1)
+	handler.SetErrorMode(true)
+	defer handler.SetErrorMode(false)
+	ops[1].Elements = object.New(nil, &testtypes.TestProto{MyString: "multi3"})
+	ops = []*states.TransactionOperation{ops[1], ops[0]}
+	tr = nic.Resources().Services().(*manager.ServiceManager).RunMultiServiceTransaction(ops, "", nic)
+	if tr.State != int32(ifs.Failed) {
+		Log.Fail(t, "Expected multi service transaction to fail ", ifs.TransactionState(tr.State))
+		return
+	}
+	for _, size := range multiSize() {
+		if size != 1 {
+			Log.Fail(t, "Expected the multi service element of the failed batch to be reverted ", multiSize())
+			return
+		}
+	}
+}
+
+func TestMultiServiceTransactionRecovery(t *testing.T) {
+	defer reset("TestMultiServiceTransactionRecovery")
+
+	activateMulti()
+	defer deactivateOnAll("multi", 0)
+	nic := leaderVnic("multi", 0)
+	if nic == nil {
+		Log.Fail(t, "No leader for multi")
+		return
+	}
+
+	//The leader went down with an operation of a decided batch and one of an undecided
+	//batch still prepared, both coordinated by this node
+	decided, err := walElement(nic, "batch1")
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	undecided, err := walElement(nic, "batch2")
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	localUuid := nic.Resources().SysConfig().LocalUuid
+	targets := map[string]byte{}
+	for node := range nic.Resources().Services().GetParticipants("multi", 0) {
+		targets[node] = 0
+	}
+	trLog, err := states.NewFileTransactionLog(filepath.Join(t.TempDir(), "batch.log"))
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	defer trLog.Close()
+	trLog.Append(&states.TransactionLogEntry{TrId: "decided", State: int32(ifs.Committed), Batch: "decided",
+		Time: time.Now().UnixMilli()})
+	trLog.Append(&states.TransactionLogEntry{TrId: "decided-0", State: int32(ifs.Running), ServiceName: "multi",
+		Action: int32(ifs.POST), Source: localUuid, Data: decided, Targets: targets, Batch: "decided",
+		Coordinator: localUuid, Timeout: 5, Time: time.Now().UnixMilli()})
+	trLog.Append(&states.TransactionLogEntry{TrId: "undecided-0", State: int32(ifs.Running), ServiceName: "multi",
+		Action: int32(ifs.POST), Source: localUuid, Data: undecided, Targets: targets, Batch: "undecided",
+		Coordinator: localUuid, Timeout: 5, Time: time.Now().UnixMilli()})
+
+	services := nic.Resources().Services().(*manager.ServiceManager)
+	err = services.SetTransactionLog(trLog, nic)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	defer services.SetTransactionLog(nil, nic)
+
+	//The operation of the decided batch is committed, the one of the undecided batch rolled back
+	expected := map[string]ifs.TransactionState{"decided-0": ifs.Committed, "undecided-0": ifs.Failed}
+	for trId, state := range expected {
+		resp := nic.ProximityRequest("multi", 0, ifs.GET, &l8svcs.L8TransactionStatusQuery{TrId: trId}, 5)
+		if resp != nil && resp.Error() != nil {
+			Log.Fail(t, resp.Error().Error())
+			return
+		}
+		status := resp.Element().(*l8services.L8Transaction)
+		if status.State != int32(state) {
+			Log.Fail(t, "Expected ", trId, " to be ", state.String(), " ", ifs.TransactionState(status.State))
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+func TestParallelTransaction(t *testing.T) {
+	defer reset("TestParallelTransaction")
+	leader := leaderVnic(ServiceName, 1)
+	if leader == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	a := agreement.For(leader.Resources(), ServiceName, 1)
+	a.SetMaxConcurrentTransactions(4)
+	defer a.SetMaxConcurrentTransactions(1)
+
+	nic := topo.VnicByVnetNum(2, 2)
+	before := topo.TrHandlerByVnetNum(1, 3).PutN()
+	trs := make([]*l8services.L8Transaction, 8)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < len(trs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pb := &testtypes.TestProto{MyString: "parallel" + strconv.Itoa(i%4)}
+			resp := nic.ProximityRequest(ServiceName, 1, ifs.PUT, pb, 10)
+			if resp == nil || resp.Error() != nil {
+				return
+			}
+			trs[i] = resp.Element().(*l8services.L8Transaction)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, tr := range trs {
+		if tr == nil || tr.State != int32(ifs.Committed) {
+			Log.Fail(t, "Expected parallel transaction ", i, " to commit")
+			return
+		}
+	}
+	if topo.TrHandlerByVnetNum(1, 3).PutN() != before+len(trs) {
+		Log.Fail(t, "Expected ", len(trs), " more puts ", topo.TrHandlerByVnetNum(1, 3).PutN())
+		return
+	}
+	if !overlapped(trs) {
+		Log.Fail(t, "Expected transactions on different keys to run in parallel")
+		return
+	}
+}
+
+// overlapped returns true if any two of the transactions were running at the same time.
+func overlapped(trs []*l8services.L8Transaction) bool {
+	for i := 0; i < len(trs); i++ {
+		for j := i + 1; j < len(trs); j++ {
+			if trs[i].Running < trs[j].End && trs[j].Running < trs[i].End {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+func TestParticipantHealth(t *testing.T) {
+	defer reset("TestParticipantHealth")
+
+	nic := leaderVnic(ServiceName, 1)
+	if nic == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	services := nic.Resources().Services().(*manager.ServiceManager)
+	if !doTransaction(ifs.PUT, nic, 1, t, true) {
+		return
+	}
+
+	scores := services.ParticipantHealth()
+	if len(scores) == 0 {
+		Log.Fail(t, "Expected the health of the participants")
+		return
+	}
+	for _, score := range scores {
+		if score.Successes == 0 || score.Excluded {
+			Log.Fail(t, "Expected participant ", score.Node, " to be healthy")
+			return
+		}
+	}
+	if len(services.RoundRobinParticipants(ServiceName, 1, len(scores))) != len(scores) {
+		Log.Fail(t, "Expected no participant to be excluded from the replica picks")
+		return
+	}
+}
+
+func TestParticipantExclusion(t *testing.T) {
+	defer reset("TestParticipantExclusion")
+	nic := leaderVnic(ServiceName, 1)
+	if nic == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	a := agreement.For(nic.Resources(), ServiceName, 1)
+	a.SetCommitPolicy(agreement.CommitMajority, 0)
+	defer a.SetCommitPolicy(agreement.CommitAll, 0)
+	services := nic.Resources().Services().(*manager.ServiceManager)
+	handler := topo.TrHandlerByVnetNum(2, 1)
+	node := topo.VnicByVnetNum(2, 1).Resources().SysConfig().LocalUuid
+	if node == nic.Resources().SysConfig().LocalUuid {
+		handler = topo.TrHandlerByVnetNum(2, 2)
+		node = topo.VnicByVnetNum(2, 2).Resources().SysConfig().LocalUuid
+	}
+	participants := services.ParticipantCount(ServiceName, 1)
+
+	//An excluded participant is skipped and lags behind the committed transactions
+	services.ExcludeParticipant(ServiceName, 1, node, 2*time.Second)
+	if !quorumPut(nic, handler, "excluded", false, t) {
+		return
+	}
+	score := scoreOf(services, node)
+	if score == nil || !score.Excluded {
+		Log.Fail(t, "Expected participant ", node, " to be excluded")
+		return
+	}
+	lagging := services.LaggingPeers(ServiceName, 1)
+	if len(lagging) != 1 || lagging[0].Peer != node {
+		Log.Fail(t, "Expected the excluded participant to lag ", len(lagging))
+		return
+	}
+	if _, ok := services.RoundRobinParticipants(ServiceName, 1, participants)[node]; ok {
+		Log.Fail(t, "Expected the excluded participant to be skipped by the replica picks")
+		return
+	}
+
+	//The excluded participant counts as a no vote, so all the participants can't be had
+	a.SetCommitPolicy(agreement.CommitAll, 0)
+	resp := nic.ProximityRequest(ServiceName, 1, ifs.PUT, &testtypes.TestProto{MyString: "all"}, 5)
+	a.SetCommitPolicy(agreement.CommitMajority, 0)
+	if resp == nil || resp.Error() != nil {
+		Log.Fail(t, "Expected a response to the transaction")
+		return
+	}
+	tr := resp.Element().(*l8services.L8Transaction)
+	if tr.State != int32(ifs.Failed) {
+		Log.Fail(t, "Expected the transaction to fail without the excluded participant ", ifs.TransactionState(tr.State))
+		return
+	}
+
+	//Once the exclusion expired, the next transaction probes the participant, which is
+	//re-admitted and repaired of the transactions it missed
+	time.Sleep(3 * time.Second)
+	if !quorumPut(nic, handler, "probed", false, t) {
+		return
+	}
+	for i := 0; i < 10 && (scoreOf(services, node).Excluded || len(services.LaggingPeers(ServiceName, 1)) > 0); i++ {
+		time.Sleep(500 * time.Millisecond)
+	}
+	if scoreOf(services, node).Excluded {
+		Log.Fail(t, "Expected participant ", node, " to be re-admitted")
+		return
+	}
+	if len(services.LaggingPeers(ServiceName, 1)) != 0 {
+		Log.Fail(t, "Expected the re-admitted participant to be repaired ", len(services.LaggingPeers(ServiceName, 1)))
+		return
+	}
+	if !quorumPut(nic, handler, "readmitted", false, t) || len(services.LaggingPeers(ServiceName, 1)) != 0 {
+		Log.Fail(t, "Expected the re-admitted participant to take part in the transactions")
+		return
+	}
+}
+
+// scoreOf returns the health of a participant, as seen by the services.
+func scoreOf(services *manager.ServiceManager, node string) *states.ParticipantScore {
+	for _, score := range services.ParticipantHealth() {
+		if score.Node == node {
+			return score
+		}
+	}
+	return nil
+}
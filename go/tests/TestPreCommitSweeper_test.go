@@ -0,0 +1,121 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+)
+
+func TestPreCommitSweeper(t *testing.T) {
+	defer reset("TestPreCommitSweeper")
+	ttl := agreement.For(topo.VnicByVnetNum(1, 1).Resources(), ServiceName, 1).PreCommitTTL()
+	agreeOnAll(ServiceName, 1, func(a *agreement.Agreement) { a.SetPreCommitTTL(time.Second) })
+	defer agreeOnAll(ServiceName, 1, func(a *agreement.Agreement) { a.SetPreCommitTTL(ttl) })
+
+	nic := leaderVnic(ServiceName, 1)
+	if nic == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	if !doTransaction(ifs.PUT, nic, 1, t, true) {
+		return
+	}
+	time.Sleep(2 * time.Second)
+
+	//Completed transactions are cleaned up by the leader, so nothing is left to sweep
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			peer := topo.VnicByVnetNum(vnet, vnic)
+			services := peer.Resources().Services().(*manager.ServiceManager)
+			if services.SweepPreCommit(ServiceName, 1, peer) != 0 {
+				Log.Fail(t, "Expected no orphaned pre-commit entries")
+				return
+			}
+			if services.TransactionMetrics(ServiceName, 1).OrphansRolledBack != 0 {
+				Log.Fail(t, "Expected no orphaned pre-commit rollbacks")
+				return
+			}
+		}
+	}
+}
+
+func TestPreCommitSweeperUnreachableLeader(t *testing.T) {
+	defer reset("TestPreCommitSweeperUnreachableLeader")
+	sla := ifs.NewServiceLevelAgreement(&base.BaseService{}, "sweep", 0, true, nil)
+	sla.SetServiceItem(&testtypes.TestProto{})
+	sla.SetServiceItemList(&testtypes.TestProtoList{})
+	sla.SetPrimaryKeys("MyString")
+	sla.SetVoter(true)
+	sla.SetTransactional(true)
+	activateOnAll(sla)
+	defer deactivateOnAll("sweep", 0)
+	agreeOnAll("sweep", 0, func(a *agreement.Agreement) { a.SetPreCommitTTL(time.Second) })
+	time.Sleep(time.Second)
+
+	leader := leaderVnic("sweep", 0)
+	if leader == nil {
+		Log.Fail(t, "No leader for sweep")
+		return
+	}
+	participant := topo.VnicByVnetNum(1, 1)
+	if participant == leader {
+		participant = topo.VnicByVnetNum(1, 2)
+	}
+	data, err := walElement(participant, "orphan")
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	trLog, err := states.NewFileTransactionLog(filepath.Join(t.TempDir(), "sweep.log"))
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	defer trLog.Close()
+	trLog.Append(&states.TransactionLogEntry{TrId: "sweep-orphan", State: int32(ifs.Running), ServiceName: "sweep",
+		Action: int32(ifs.POST), Participant: true, Data: data, Time: time.Now().UnixMilli()})
+	services := participant.Resources().Services().(*manager.ServiceManager)
+	err = services.SetTransactionLog(trLog, participant)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	defer services.SetTransactionLog(nil, participant)
+	time.Sleep(1500 * time.Millisecond)
+
+	//A leader that cannot answer is not taken as one that does not know the transaction
+	leader.Resources().Services().DeActivate("sweep", 0, leader.Resources(), leader)
+	if services.SweepPreCommit("sweep", 0, participant) != 0 {
+		Log.Fail(t, "Expected the orphan to be kept while the leader cannot answer")
+		return
+	}
+
+	//Once the leader answers that it does not know the transaction, the orphan is rolled back
+	base.Activate(sla, leader)
+	if services.SweepPreCommit("sweep", 0, participant) != 1 {
+		Log.Fail(t, "Expected the orphan to be rolled back once the leader answers")
+		return
+	}
+}
@@ -0,0 +1,122 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+func TestQuorumTransaction(t *testing.T) {
+	defer reset("TestQuorumTransaction")
+	nic := leaderVnic(ServiceName, 1)
+	if nic == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	a := agreement.For(nic.Resources(), ServiceName, 1)
+	a.SetCommitPolicy(agreement.CommitMajority, 0)
+	defer a.SetCommitPolicy(agreement.CommitAll, 0)
+	services := nic.Resources().Services().(*manager.ServiceManager)
+
+	handler := topo.TrHandlerByVnetNum(2, 1)
+	handler.SetErrorMode(true)
+	resp := nic.ProximityRequest(ServiceName, 1, ifs.PUT, &testtypes.TestProto{MyString: "quorum"}, 5)
+	handler.SetErrorMode(false)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	tr := resp.Element().(*l8services.L8Transaction)
+	if tr.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected quorum transaction to commit ", ifs.TransactionState(tr.State), " ", tr.ErrMsg)
+		return
+	}
+
+	lagging := services.LaggingPeers(ServiceName, 1)
+	if len(lagging) != 1 {
+		Log.Fail(t, "Expected 1 lagging peer ", len(lagging))
+		return
+	}
+	if services.RepairLaggingPeers(ServiceName, 1, nic) != 1 {
+		Log.Fail(t, "Expected the lagging peer to be repaired")
+		return
+	}
+	if len(services.LaggingPeers(ServiceName, 1)) != 0 {
+		Log.Fail(t, "Expected no lagging peers after repair")
+		return
+	}
+
+	//A newer transaction the peer applied on the same key supersedes the one it missed
+	if !quorumPut(nic, handler, "superseded", true, t) || len(services.LaggingPeers(ServiceName, 1)) != 1 {
+		Log.Fail(t, "Expected 1 lagging peer ", len(services.LaggingPeers(ServiceName, 1)))
+		return
+	}
+	if !quorumPut(nic, handler, "superseded", false, t) {
+		return
+	}
+	if len(services.LaggingPeers(ServiceName, 1)) != 0 {
+		Log.Fail(t, "Expected the superseded lagging peer to be dropped")
+		return
+	}
+
+	//The lagging records are capped
+	services.SetLaggingPeersLimit(1)
+	defer services.SetLaggingPeersLimit(states.DefaultLaggingLimit)
+	if !quorumPut(nic, handler, "capped1", true, t) || !quorumPut(nic, handler, "capped2", true, t) {
+		return
+	}
+	lagging = services.LaggingPeers(ServiceName, 1)
+	if len(lagging) != 1 || lagging[0].Keys[0] != "capped2" {
+		Log.Fail(t, "Expected only the latest lagging peer to be kept ", len(lagging))
+		return
+	}
+
+	//The leader repairs its lagging peers on its own
+	a.SetRepairInterval(time.Second)
+	defer a.SetRepairInterval(10 * time.Second)
+	for i := 0; i < 5 && len(services.LaggingPeers(ServiceName, 1)) > 0; i++ {
+		time.Sleep(time.Second)
+	}
+	if len(services.LaggingPeers(ServiceName, 1)) != 0 {
+		Log.Fail(t, "Expected the lagging peer to be repaired automatically")
+		return
+	}
+}
+
+// quorumPut commits a PUT of the key, making the handler fail it first if lagging is true.
+func quorumPut(nic ifs.IVNic, handler interface{ SetErrorMode(bool) }, key string, lagging bool, t *testing.T) bool {
+	handler.SetErrorMode(lagging)
+	resp := nic.ProximityRequest(ServiceName, 1, ifs.PUT, &testtypes.TestProto{MyString: key}, 5)
+	handler.SetErrorMode(false)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return false
+	}
+	tr := resp.Element().(*l8services.L8Transaction)
+	if tr.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected quorum transaction to commit ", ifs.TransactionState(tr.State), " ", tr.ErrMsg)
+		return false
+	}
+	return true
+}
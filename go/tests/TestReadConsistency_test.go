@@ -0,0 +1,104 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+func TestReadConsistency(t *testing.T) {
+	defer reset("TestReadConsistency")
+
+	nic := topo.VnicByVnetNum(2, 1)
+	pb := &testtypes.TestProto{MyString: "consistent"}
+	resp := nic.ProximityRequest(ServiceName, 1, ifs.PUT, pb, 5)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	tr := resp.Element().(*l8services.L8Transaction)
+
+	services := nic.Resources().Services().(*manager.ServiceManager)
+	levels := []agreement.ReadConsistency{agreement.ReadStrong, agreement.ReadSession, agreement.ReadEventual}
+	for _, level := range levels {
+		resp = services.Read(ServiceName, 1, pb, level, tr.Id, nic)
+		if resp == nil || resp.Error() != nil {
+			Log.Fail(t, "Expected a read of consistency ", level, " to succeed")
+			return
+		}
+		failed, ok := resp.Element().(*l8services.L8Transaction)
+		if ok && failed.State == int32(ifs.Failed) {
+			Log.Fail(t, "Read of consistency ", level, " failed: ", failed.ErrMsg)
+			return
+		}
+	}
+}
+
+func TestConsistentReadMessage(t *testing.T) {
+	defer reset("TestConsistentReadMessage")
+
+	sla := ifs.NewServiceLevelAgreement(&base.BaseService{}, "readcons", 0, true, nil)
+	sla.SetServiceItem(&testtypes.TestProto{})
+	sla.SetServiceItemList(&testtypes.TestProtoList{})
+	sla.SetPrimaryKeys("MyString")
+	sla.SetVoter(true)
+	sla.SetTransactional(true)
+	activateOnAll(sla)
+	defer deactivateOnAll("readcons", 0)
+	time.Sleep(time.Second)
+
+	writer := topo.VnicByVnetNum(2, 1)
+	reader := topo.VnicByVnetNum(3, 2)
+	levels := []agreement.ReadConsistency{agreement.ReadSession, agreement.ReadStrong, agreement.ReadEventual}
+	for i, level := range levels {
+		written := int32(70 + i)
+		resp := writer.ProximityRequest("readcons", 0, ifs.PUT, &testtypes.TestProto{MyString: "rc", MyInt32: written}, 5)
+		if resp == nil || resp.Error() != nil {
+			Log.Fail(t, "Expected the write to succeed")
+			return
+		}
+		tr := resp.Element().(*l8services.L8Transaction)
+		if tr.State != int32(ifs.Committed) {
+			Log.Fail(t, "Expected the write to commit ", ifs.TransactionState(tr.State), " ", tr.ErrMsg)
+			return
+		}
+
+		read, err := states.NewConsistentRead(&testtypes.TestProto{MyString: "rc"}, level, tr.Id)
+		if err != nil {
+			Log.Fail(t, err.Error())
+			return
+		}
+		resp = reader.ProximityRequest("readcons", 0, ifs.GET, read, 5)
+		if resp == nil || resp.Error() != nil {
+			Log.Fail(t, "Expected a read of consistency ", level, " to succeed")
+			return
+		}
+		elem, ok := resp.Element().(*testtypes.TestProto)
+		if !ok || elem.MyInt32 != written {
+			Log.Fail(t, "Expected a read of consistency ", level, " to see the preceding write ", written)
+			return
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/saga"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+)
+
+func TestSaga(t *testing.T) {
+	defer reset("TestSaga")
+	agreeOnAll("NoSuchSvc", 9, func(a *agreement.Agreement) { a.SetTransactionTimeout(time.Second) })
+
+	sla := ifs.NewServiceLevelAgreement(&saga.SagaService{}, saga.ServiceName, saga.ServiceArea, true, nil)
+	activateOnAll(sla)
+	defer deactivateOnAll(saga.ServiceName, saga.ServiceArea)
+	time.Sleep(time.Second)
+
+	nic := topo.VnicByVnetNum(1, 1)
+	sagas := saga.Service(nic.Resources())
+
+	step := &saga.SagaStep{
+		Call:         &saga.SagaCall{ServiceName: ServiceName, ServiceArea: 1, Action: ifs.PUT, Element: &testtypes.TestProto{MyString: "saga"}},
+		Compensation: &saga.SagaCall{ServiceName: ServiceName, ServiceArea: 1, Action: ifs.PUT, Element: &testtypes.TestProto{MyString: "saga-undo"}},
+	}
+	done := sagas.Run(saga.NewSaga(step))
+	if done.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected saga to complete ", ifs.TransactionState(done.State), " ", done.ErrMsg)
+		return
+	}
+
+	failing := &saga.SagaStep{Call: &saga.SagaCall{ServiceName: "NoSuchSvc", ServiceArea: 9, Action: ifs.POST,
+		Element: &testtypes.TestProto{MyString: "fail"}}}
+	failed := saga.NewSaga(step, failing)
+	record := sagas.Run(failed)
+	if record.State != int32(ifs.Failed) {
+		Log.Fail(t, "Expected saga to fail ", ifs.TransactionState(record.State))
+		return
+	}
+	status := sagas.Status(failed.Id)
+	if status == nil || status.Steps[0].State != int32(ifs.Rollback) {
+		Log.Fail(t, "Expected the first step to be compensated")
+		return
+	}
+	if sagas.Resume(failed.Id) {
+		Log.Fail(t, "Expected a finished saga not to be resumed")
+		return
+	}
+}
+
+func TestSagaResume(t *testing.T) {
+	defer reset("TestSagaResume")
+
+	sla := ifs.NewServiceLevelAgreement(&saga.SagaService{}, saga.ServiceName, saga.ServiceArea, true, nil)
+	activateOnAll(sla)
+	defer deactivateOnAll(saga.ServiceName, saga.ServiceArea)
+	time.Sleep(time.Second)
+
+	leader := leaderVnic(saga.ServiceName, saga.ServiceArea)
+	if leader == nil {
+		Log.Fail(t, "No leader for ", saga.ServiceName)
+		return
+	}
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			sagas := saga.Service(topo.VnicByVnetNum(vnet, vnic).Resources())
+			sagas.SetStallTimeout(time.Second)
+			sagas.SetRetention(2 * time.Second)
+		}
+	}
+	owner := topo.VnicByVnetNum(1, 1)
+	if owner == leader {
+		owner = topo.VnicByVnetNum(1, 2)
+	}
+
+	//The owner completed the first step and went down, leaving only the saga's record
+	abandoned := saga.NewSaga(
+		&saga.SagaStep{Call: &saga.SagaCall{ServiceName: ServiceName, ServiceArea: 1, Action: ifs.PUT,
+			Element: &testtypes.TestProto{MyString: "saga-first"}}},
+		&saga.SagaStep{Call: &saga.SagaCall{ServiceName: ServiceName, ServiceArea: 1, Action: ifs.PUT,
+			Element: &testtypes.TestProto{MyString: "saga-second"}}})
+	record, err := abandoned.Record()
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	record.Steps[0].State = int32(ifs.Committed)
+	record.Running = time.Now().Add(-time.Minute).UnixMilli()
+	saga.Service(owner.Resources()).Post(object.New(nil, record), owner)
+
+	//The leader, that never ran the saga, resumes it from its record after the second step
+	time.Sleep(3 * time.Second)
+	status := saga.Service(owner.Resources()).Status(abandoned.Id)
+	if status == nil || status.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected the leader to resume and complete the abandoned saga")
+		return
+	}
+	if status.Steps[0].Running != 0 || status.Steps[1].State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected the saga to resume after the completed step")
+		return
+	}
+
+	//The record of the finished saga is deleted after the retention
+	time.Sleep(4 * time.Second)
+	if saga.Service(owner.Resources()).Status(abandoned.Id) != nil {
+		Log.Fail(t, "Expected the finished saga's record to be deleted")
+		return
+	}
+}
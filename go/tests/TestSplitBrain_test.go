@@ -0,0 +1,185 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+func (this *electionEventRecorder) splitBrain(leader, rival string) bool {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	for _, event := range this.events {
+		if event.Type == manager.SplitBrain && event.Leader == leader && event.Node == rival {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSplitBrain(t *testing.T) {
+	defer reset("TestSplitBrain")
+
+	nic := leaderVnic(ServiceName, 1)
+	if nic == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	oldLeader := nic.Resources().SysConfig().LocalUuid
+	oldEpoch, _ := nic.Resources().Services().(*manager.ServiceManager).Epoch(ServiceName, 1)
+
+	var newLeader, follower ifs.IVNic
+	for vnic := 1; vnic <= 3; vnic++ {
+		peer := topo.VnicByVnetNum(2, vnic)
+		if peer == nic {
+			continue
+		}
+		if newLeader == nil {
+			newLeader = peer
+		} else if follower == nil {
+			follower = peer
+		}
+	}
+	newLeaderUuid := newLeader.Resources().SysConfig().LocalUuid
+	recorder := &electionEventRecorder{}
+	followerServices := follower.Resources().Services().(*manager.ServiceManager)
+	followerServices.SubscribeElectionEvents(recorder)
+	defer followerServices.UnsubscribeElectionEvents(recorder)
+
+	err := leadWith(ServiceName, 1, newLeader)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+
+	//The old leader, left behind by a partition, keeps sending heartbeats of its old term
+	time.Sleep(3 * time.Second)
+	nic.Multicast(ServiceName, 1, ifs.LeaderHeartbeat, &l8svcs.L8LeaderTerm{Leader: oldLeader, Epoch: oldEpoch})
+	time.Sleep(time.Second)
+
+	if !recorder.splitBrain(oldLeader, newLeaderUuid) {
+		Log.Fail(t, "Expected the follower to detect the split brain")
+		return
+	}
+	if followerServices.GetLeader(ServiceName, 1) != newLeaderUuid {
+		Log.Fail(t, "Expected the leader with the newer epoch to keep the leadership")
+		return
+	}
+	if len(newLeader.Resources().Services().(*manager.ServiceManager).DivergentWrites(ServiceName, 1)) != 0 {
+		Log.Fail(t, "Expected no divergent writes on the leader that was not deposed")
+		return
+	}
+}
+
+// divergedValue returns the MyInt32 of a "diverged" element on a node, -1 if it has none.
+func divergedValue(nic ifs.IVNic, key string) int32 {
+	h, _ := nic.Resources().Services().ServiceHandler("diverged", 0)
+	resp := h.Get(object.New(nil, &testtypes.TestProto{MyString: key}), nic)
+	if resp == nil || resp.Element() == nil {
+		return -1
+	}
+	return resp.Element().(*testtypes.TestProto).MyInt32
+}
+
+func TestSplitBrainQuarantine(t *testing.T) {
+	defer reset("TestSplitBrainQuarantine")
+
+	sla := ifs.NewServiceLevelAgreement(&base.BaseService{}, "diverged", 0, true, nil)
+	sla.SetServiceItem(&testtypes.TestProto{})
+	sla.SetServiceItemList(&testtypes.TestProtoList{})
+	sla.SetPrimaryKeys("MyString")
+	sla.SetVoter(true)
+	sla.SetTransactional(true)
+	activateOnAll(sla)
+	defer deactivateOnAll("diverged", 0)
+	time.Sleep(3 * time.Second)
+
+	nic := leaderVnic("diverged", 0)
+	if nic == nil {
+		Log.Fail(t, "No leader for diverged")
+		return
+	}
+	var winner, participant ifs.IVNic
+	for vnic := 1; vnic <= 3; vnic++ {
+		peer := topo.VnicByVnetNum(3, vnic)
+		if peer == nic {
+			continue
+		}
+		if winner == nil {
+			winner = peer
+		} else if participant == nil {
+			participant = peer
+		}
+	}
+
+	//The writes of the old leader's term, committed on all the nodes
+	for _, key := range []string{"kept", "changed", "created"} {
+		resp := nic.ProximityRequest("diverged", 0, ifs.POST, &testtypes.TestProto{MyString: key, MyInt32: 1}, 5)
+		tr, ok := resp.Element().(*l8services.L8Transaction)
+		if !ok || tr.State != int32(ifs.Committed) {
+			Log.Fail(t, "Expected the write of ", key, " to commit")
+			return
+		}
+	}
+
+	//On the other side of a partition, the winner changed one key and never had another
+	h, _ := winner.Resources().Services().ServiceHandler("diverged", 0)
+	h.Put(object.New(nil, &testtypes.TestProto{MyString: "changed", MyInt32: 2}), winner)
+	h.Delete(object.New(nil, &testtypes.TestProto{MyString: "created"}), winner)
+
+	//The winner's heartbeat with a newer epoch deposes the old leader
+	services := nic.Resources().Services().(*manager.ServiceManager)
+	epoch, _ := services.Epoch("diverged", 0)
+	winnerUuid := winner.Resources().SysConfig().LocalUuid
+	winner.Multicast("diverged", 0, ifs.LeaderHeartbeat, &l8svcs.L8LeaderTerm{Leader: winnerUuid, Epoch: epoch + 1})
+	time.Sleep(3 * time.Second)
+
+	writes := services.DivergentWrites("diverged", 0)
+	if len(writes) != 2 {
+		Log.Fail(t, "Expected the 2 writes that diverged from the winner to be quarantined, got ", len(writes))
+		return
+	}
+	for _, node := range []ifs.IVNic{nic, participant} {
+		if divergedValue(node, "kept") != 1 || divergedValue(node, "changed") != 2 || divergedValue(node, "created") != -1 {
+			Log.Fail(t, "Expected ", node.Resources().SysConfig().LocalUuid, " to be resynced from the winner")
+			return
+		}
+	}
+
+	//Replaying the quarantined writes on the winner recreates the element it never had
+	reconciled := services.ReconcileDivergentWrites("diverged", 0, nic)
+	if reconciled == 0 {
+		Log.Fail(t, "Expected the winner to commit a replayed write")
+		return
+	}
+	if divergedValue(winner, "created") != 1 {
+		Log.Fail(t, "Expected the replayed write to recreate the element on the winner")
+		return
+	}
+	if len(services.DivergentWrites("diverged", 0)) != len(writes)-reconciled {
+		Log.Fail(t, "Expected the reconciled writes to leave the quarantine")
+		return
+	}
+}
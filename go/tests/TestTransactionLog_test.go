@@ -0,0 +1,242 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+func TestFileTransactionLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tr.log")
+	trLog, err := states.NewFileTransactionLog(path)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+
+	trLog.Append(&states.TransactionLogEntry{TrId: "a", State: int32(ifs.Queued), ServiceName: "Tests", ServiceArea: 1, Data: "payload"})
+	trLog.Append(&states.TransactionLogEntry{TrId: "b", State: int32(ifs.Queued), ServiceName: "Tests", ServiceArea: 1})
+	trLog.Append(&states.TransactionLogEntry{TrId: "a", State: int32(ifs.Running), ServiceName: "Tests", ServiceArea: 1})
+	trLog.Append(&states.TransactionLogEntry{TrId: "b", State: int32(ifs.Running), ServiceName: "Tests", ServiceArea: 1})
+	trLog.Append(&states.TransactionLogEntry{TrId: "b", State: int32(ifs.Cleanup), ServiceName: "Tests", ServiceArea: 1})
+	trLog.Append(&states.TransactionLogEntry{TrId: "a", State: int32(ifs.Running), ServiceName: "Tests", ServiceArea: 1, Participant: true})
+	trLog.Close()
+
+	//Reopen to replay and compact the log
+	trLog, err = states.NewFileTransactionLog(path)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	defer trLog.Close()
+
+	pending, err := trLog.Pending()
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	if len(pending) != 2 {
+		Log.Fail(t, "Expected 2 pending entries ", len(pending))
+		return
+	}
+	for _, entry := range pending {
+		if entry.TrId != "a" {
+			Log.Fail(t, "Unexpected pending transaction ", entry.TrId)
+			return
+		}
+		if !entry.Participant && (entry.State != int32(ifs.Running) || entry.Data != "payload") {
+			Log.Fail(t, "Leader entry was not folded ", entry.State, " ", entry.Data)
+			return
+		}
+	}
+}
+
+func TestFileTransactionLogCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tr.log")
+	trLog, err := states.NewFileTransactionLog(path)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	defer trLog.Close()
+	trLog.SetCompactSize(4096)
+
+	trLog.Append(&states.TransactionLogEntry{TrId: "pending", State: int32(ifs.Queued), ServiceName: "Tests", ServiceArea: 1})
+	for i := 0; i < 200; i++ {
+		trId := "done" + strconv.Itoa(i)
+		trLog.Append(&states.TransactionLogEntry{TrId: trId, State: int32(ifs.Running), ServiceName: "Tests", ServiceArea: 1})
+		trLog.Append(&states.TransactionLogEntry{TrId: trId, State: int32(ifs.Cleanup), ServiceName: "Tests", ServiceArea: 1})
+	}
+
+	//The completed transactions were compacted away while the log was open
+	info, err := os.Stat(path)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	if info.Size() > 2*4096 {
+		Log.Fail(t, "Expected the log to be compacted while running, its size is ", info.Size())
+		return
+	}
+	pending, err := trLog.Pending()
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	if len(pending) != 1 || pending[0].TrId != "pending" {
+		Log.Fail(t, "Expected only the pending transaction to be left ", len(pending))
+		return
+	}
+}
+
+// walElement returns the encrypted payload of a transaction on a single element.
+func walElement(nic ifs.IVNic, name string) (string, error) {
+	data, err := object.New(nil, &testtypes.TestProto{MyString: name}).Serialize()
+	if err != nil {
+		return "", err
+	}
+	return nic.Resources().Security().Encrypt(data)
+}
+
+func TestTransactionLogRecovery(t *testing.T) {
+	defer reset("TestTransactionLogRecovery")
+	sla := ifs.NewServiceLevelAgreement(&base.BaseService{}, "wal", 0, true, nil)
+	sla.SetServiceItem(&testtypes.TestProto{})
+	sla.SetServiceItemList(&testtypes.TestProtoList{})
+	sla.SetPrimaryKeys("MyString")
+	sla.SetVoter(true)
+	sla.SetTransactional(true)
+	activateOnAll(sla)
+	defer deactivateOnAll("wal", 0)
+	time.Sleep(time.Second)
+
+	leader := leaderVnic("wal", 0)
+	if leader == nil {
+		Log.Fail(t, "No leader for wal")
+		return
+	}
+	var participant ifs.IVNic
+	for vnet := 1; vnet <= 3 && participant == nil; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic := topo.VnicByVnetNum(vnet, vnic)
+			if nic.Resources().SysConfig().LocalUuid != leader.Resources().SysConfig().LocalUuid {
+				participant = nic
+				break
+			}
+		}
+	}
+
+	//A participant replays the transaction it prepared before it went down, which keeps its key locked
+	data, err := walElement(participant, "locked")
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	participantLog, err := states.NewFileTransactionLog(filepath.Join(t.TempDir(), "participant.log"))
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	defer participantLog.Close()
+	participantLog.Append(&states.TransactionLogEntry{TrId: "wal-orphan", State: int32(ifs.Running), ServiceName: "wal",
+		Action: int32(ifs.POST), Source: leader.Resources().SysConfig().LocalUuid, Participant: true, Data: data,
+		Time: time.Now().UnixMilli()})
+	participantServices := participant.Resources().Services().(*manager.ServiceManager)
+	err = participantServices.SetTransactionLog(participantLog, participant)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	defer participantServices.SetTransactionLog(nil, participant)
+	resp := leader.ProximityRequest("wal", 0, ifs.POST, &testtypes.TestProto{MyString: "locked"}, 5)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	tr := resp.Element().(*l8services.L8Transaction)
+	if tr.State != int32(ifs.Failed) || !strings.Contains(tr.ErrMsg, "wal-orphan") {
+		Log.Fail(t, "Expected the replayed transaction to hold its key lock ", ifs.TransactionState(tr.State), " ", tr.ErrMsg)
+		return
+	}
+
+	//The leader never heard of the replayed transaction, so the participant rolls it back once it is stale
+	agreement.For(participant.Resources(), "wal", 0).SetPreCommitTTL(time.Second)
+	time.Sleep(1500 * time.Millisecond)
+	if participantServices.SweepPreCommit("wal", 0, participant) != 1 {
+		Log.Fail(t, "Expected the replayed transaction to be swept")
+		return
+	}
+
+	//The leader replays a transaction it queued before it went down, and commits it on recovery
+	data, err = walElement(leader, "recovered")
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	leaderLog, err := states.NewFileTransactionLog(filepath.Join(t.TempDir(), "leader.log"))
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	defer leaderLog.Close()
+	leaderLog.Append(&states.TransactionLogEntry{TrId: "wal-queued", State: int32(ifs.Queued), ServiceName: "wal",
+		Action: int32(ifs.POST), Source: leader.Resources().SysConfig().LocalUuid, Timeout: 5, Data: data,
+		Time: time.Now().UnixMilli()})
+	leaderServices := leader.Resources().Services().(*manager.ServiceManager)
+	err = leaderServices.SetTransactionLog(leaderLog, leader)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	defer leaderServices.SetTransactionLog(nil, leader)
+	time.Sleep(2 * time.Second)
+
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic := topo.VnicByVnetNum(vnet, vnic)
+			h, _ := nic.Resources().Services().ServiceHandler("wal", 0)
+			if h.(*base.BaseService).Size() != 1 {
+				Log.Fail(t, nic.Resources().SysConfig().LocalAlias, " Expected the recovered transaction to commit ",
+					h.(*base.BaseService).Size())
+				return
+			}
+		}
+	}
+	resp = leader.ProximityRequest("wal", 0, ifs.GET, &l8svcs.L8TransactionStatusQuery{TrId: "wal-queued"}, 5)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	status := resp.Element().(*l8services.L8Transaction)
+	if status.State != int32(ifs.Committed) {
+		Log.Fail(t, "Expected the recovered transaction to be committed ", ifs.TransactionState(status.State))
+		return
+	}
+}
@@ -0,0 +1,113 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/saichler/l8services/go/services/manager"
+	"github.com/saichler/l8services/go/services/transaction/metrics"
+	"github.com/saichler/l8services/go/services/transaction/states"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+)
+
+type countingTracer struct {
+	spans map[string]int
+	mtx   sync.Mutex
+}
+
+func (this *countingTracer) StartSpan(name string, msg *ifs.Message) states.ITransactionSpan {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	this.spans[name]++
+	return this
+}
+
+func (this *countingTracer) End(msg *ifs.Message) {
+}
+
+func (this *countingTracer) count(name string) int {
+	this.mtx.Lock()
+	defer this.mtx.Unlock()
+	return this.spans[name]
+}
+
+func TestTransactionMetrics(t *testing.T) {
+	defer reset("TestTransactionMetrics")
+
+	nic := leaderVnic(ServiceName, 1)
+	if nic == nil {
+		Log.Fail(t, "No leader for ", ServiceName)
+		return
+	}
+	services := nic.Resources().Services().(*manager.ServiceManager)
+	tracer := &countingTracer{spans: make(map[string]int)}
+	services.SetTransactionTracer(tracer)
+	defer services.SetTransactionTracer(nil)
+
+	before := services.TransactionMetrics(ServiceName, 1).Committed
+	if !doTransaction(ifs.PUT, nic, 1, t, true) {
+		return
+	}
+
+	sm := services.TransactionMetrics(ServiceName, 1)
+	if sm.Committed != before+1 {
+		Log.Fail(t, "Expected 1 more committed transaction ", sm.Committed)
+		return
+	}
+	if sm.RunTime.Count == 0 || len(sm.PeerCommit) == 0 {
+		Log.Fail(t, "Expected run and peer commit latencies")
+		return
+	}
+	if tracer.count(states.SpanRun) == 0 || tracer.count(states.SpanCommit) == 0 {
+		Log.Fail(t, "Expected run and commit spans")
+		return
+	}
+
+	sla := ifs.NewServiceLevelAgreement(&metrics.TransactionMetricsService{}, metrics.ServiceName, metrics.ServiceArea, false, nil)
+	_, err := services.Activate(sla, nic)
+	if err != nil {
+		Log.Fail(t, err.Error())
+		return
+	}
+	defer services.DeActivate(metrics.ServiceName, metrics.ServiceArea, nic.Resources(), nic)
+	resp := metrics.Service(nic.Resources()).Get(object.New(nil, &l8svcs.L8ServiceMetrics{ServiceName: ServiceName, ServiceArea: 1}), nic)
+	queried, ok := resp.Element().(*l8svcs.L8ServiceMetrics)
+	if !ok || queried.Committed != sm.Committed {
+		Log.Fail(t, "Expected the metrics service to return the service metrics")
+		return
+	}
+
+	//query the metrics from another node
+	other := topo.VnicByVnetNum(1, 1)
+	if other == nic {
+		other = topo.VnicByVnetNum(1, 2)
+	}
+	resp = other.Request(nic.Resources().SysConfig().LocalUuid, metrics.ServiceName, metrics.ServiceArea, ifs.GET,
+		&l8svcs.L8ServiceMetrics{}, 5)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	list, ok := resp.Element().(*l8svcs.L8ServiceMetricsList)
+	if !ok || len(list.List) == 0 {
+		Log.Fail(t, "Expected the remote query to return the metrics of all the services")
+		return
+	}
+}
@@ -0,0 +1,58 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/saichler/l8services/go/types/l8svcs"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	. "github.com/saichler/l8test/go/infra/t_service"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+	"github.com/saichler/l8types/go/types/l8services"
+)
+
+func TestTransactionStatus(t *testing.T) {
+	defer reset("TestTransactionStatus")
+
+	eg2_1 := topo.VnicByVnetNum(2, 1)
+	pb := &testtypes.TestProto{MyString: "status"}
+	resp := eg2_1.ProximityRequest(ServiceName, 1, ifs.PUT, pb, 5)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	tr := resp.Element().(*l8services.L8Transaction)
+
+	//Query from a different node than the one that started the transaction
+	eg3_3 := topo.VnicByVnetNum(3, 3)
+	resp = eg3_3.ProximityRequest(ServiceName, 1, ifs.GET, &l8svcs.L8TransactionStatusQuery{TrId: tr.Id}, 5)
+	if resp != nil && resp.Error() != nil {
+		Log.Fail(t, resp.Error().Error())
+		return
+	}
+	status := resp.Element().(*l8services.L8Transaction)
+	if status.Id != tr.Id || status.State != tr.State {
+		Log.Fail(t, "Expected status of ", tr.Id, " ", ifs.TransactionState(tr.State), " but got ",
+			status.Id, " ", ifs.TransactionState(status.State))
+		return
+	}
+
+	resp = eg3_3.ProximityRequest(ServiceName, 1, ifs.GET, &l8svcs.L8TransactionStatusQuery{TrId: "unknown"}, 5)
+	if resp == nil || resp.Error() == nil {
+		Log.Fail(t, "Expected an error for an unknown transaction")
+		return
+	}
+}
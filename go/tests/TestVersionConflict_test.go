@@ -0,0 +1,195 @@
+// © 2025 Sharon Aicler (saichler@gmail.com)
+//
+// Layer 8 Ecosystem is licensed under the Apache License, Version 2.0.
+// You may obtain a copy of the License at:
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tests
+
+import (
+	"testing"
+
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/types/l8svcs"
+	"github.com/saichler/l8srlz/go/serialize/object"
+	. "github.com/saichler/l8test/go/infra/t_resources"
+	"github.com/saichler/l8types/go/ifs"
+	"github.com/saichler/l8types/go/testtypes"
+)
+
+func versionedService(name string, nic ifs.IVNic) (*base.BaseService, *ifs.ServiceLevelAgreement) {
+	sc := ifs.NewServiceLevelAgreement(&base.BaseService{}, name, 0, true, nil)
+	sc.SetServiceItem(&testtypes.TestProto{})
+	sc.SetServiceItemList(&testtypes.TestProtoList{})
+	sc.SetPrimaryKeys("MyString")
+	sc.SetVoter(true)
+	h, _ := base.Activate(sc, nic)
+	return h.(*base.BaseService), sc
+}
+
+func TestVersionConflict(t *testing.T) {
+	defer reset("TestVersionConflict")
+	nic := topo.VnicByVnetNum(1, 1)
+	bs, sc := versionedService("versioned", nic)
+	defer nic.Resources().Services().DeActivate("versioned", 0, nic.Resources(), nic)
+	agreement.Of(sc, nic.Resources()).SetVersionField("MyInt32")
+
+	bs.Post(object.New(nil, &testtypes.TestProto{MyString: "v"}), nic)
+	resp := bs.Put(object.New(nil, &testtypes.TestProto{MyString: "v", MyInt32: 0}), nic)
+	if resp.Error() != nil {
+		Log.Fail(t, "Expected the first write to succeed ", resp.Error().Error())
+		return
+	}
+	resp = bs.Put(object.New(nil, &testtypes.TestProto{MyString: "v", MyInt32: 0}), nic)
+	if !base.IsConflict(resp.Error()) {
+		Log.Fail(t, "Expected a stale write to be rejected with a conflict")
+		return
+	}
+	err := bs.ValidateTransaction(object.New(nil, &testtypes.TestProto{MyString: "v", MyInt32: 0}), ifs.PUT, nic)
+	if !base.IsConflict(err) {
+		Log.Fail(t, "Expected the prepare of a stale write to vote no")
+		return
+	}
+	err = bs.ValidateTransaction(object.New(nil, &testtypes.TestProto{MyString: "v", MyInt32: 1}), ifs.PUT, nic)
+	if err != nil {
+		Log.Fail(t, "Expected the prepare of a current write to vote yes ", err.Error())
+		return
+	}
+	resp = bs.Put(object.New(nil, &testtypes.TestProto{MyString: "v", MyInt32: 1}), nic)
+	if resp.Error() != nil {
+		Log.Fail(t, "Expected a write of the current version to succeed ", resp.Error().Error())
+		return
+	}
+
+	//A batch with a stale element writes none of its elements
+	bs.Post(object.New(nil, &testtypes.TestProto{MyString: "w"}), nic)
+	batch := []interface{}{&testtypes.TestProto{MyString: "w", MyInt32: 0}, &testtypes.TestProto{MyString: "v", MyInt32: 0}}
+	resp = bs.Put(object.New(nil, batch), nic)
+	if !base.IsConflict(resp.Error()) {
+		Log.Fail(t, "Expected a batch with a stale write to be rejected with a conflict")
+		return
+	}
+	resp = bs.Get(object.New(nil, &testtypes.TestProto{MyString: "w"}), nic)
+	if resp.Element() == nil || resp.Element().(*testtypes.TestProto).MyInt32 != 0 {
+		Log.Fail(t, "Expected the rejected batch to leave its first element unwritten")
+		return
+	}
+}
+
+func TestManagedRevision(t *testing.T) {
+	defer reset("TestManagedRevision")
+	nic := topo.VnicByVnetNum(1, 1)
+	bs, sc := versionedService("revisioned", nic)
+	defer nic.Resources().Services().DeActivate("revisioned", 0, nic.Resources(), nic)
+	agreement.Of(sc, nic.Resources()).SetManagedRevision(true)
+
+	elem := &testtypes.TestProto{MyString: "r"}
+	bs.Post(object.New(nil, elem), nic)
+	revision := bs.Revision(elem, nic.Resources())
+	if revision != 1 {
+		Log.Fail(t, "Expected revision 1, got ", revision)
+		return
+	}
+	resp := bs.Put(object.New(nil, &testtypes.TestProto{MyString: "r", MyInt32: 4}), nic)
+	if !base.IsConflict(resp.Error()) {
+		Log.Fail(t, "Expected a plain write of an existing element to be rejected")
+		return
+	}
+	resp = bs.PutIfRevision(&testtypes.TestProto{MyString: "r", MyInt32: 5}, revision, nic)
+	if resp.Error() != nil {
+		Log.Fail(t, "Expected the conditional write to succeed ", resp.Error().Error())
+		return
+	}
+	resp = bs.PatchIfRevision(&testtypes.TestProto{MyString: "r", MyInt32: 6}, revision, nic)
+	if !base.IsConflict(resp.Error()) {
+		Log.Fail(t, "Expected a conditional write of an old revision to be rejected")
+		return
+	}
+
+	stale, _ := base.Revisioned(&testtypes.TestProto{MyString: "r", MyInt32: 7}, revision)
+	err := bs.ValidateTransaction(object.New(nil, stale), ifs.PUT, nic)
+	if !base.IsConflict(err) {
+		Log.Fail(t, "Expected the prepare of a write of an old revision to vote no")
+		return
+	}
+	current, _ := base.Revisioned(&testtypes.TestProto{MyString: "r", MyInt32: 7}, revision+1)
+	err = bs.ValidateTransaction(object.New(nil, current), ifs.PUT, nic)
+	if err != nil {
+		Log.Fail(t, "Expected the prepare of a write of the current revision to vote yes ", err.Error())
+		return
+	}
+
+	//A rollback restores the element and its revision
+	resp = bs.Restore(object.New(nil, &testtypes.TestProto{MyString: "r"}), ifs.PUT, nic)
+	if resp.Error() != nil {
+		Log.Fail(t, "Expected the restore to succeed ", resp.Error().Error())
+		return
+	}
+	if bs.Revision(elem, nic.Resources()) != revision {
+		Log.Fail(t, "Expected the restore to move the revision back to ", revision)
+		return
+	}
+}
+
+func TestRemoteManagedRevision(t *testing.T) {
+	defer reset("TestRemoteManagedRevision")
+	nic := topo.VnicByVnetNum(1, 1)
+	agreement.For(nic.Resources(), "remoterev", 0).SetManagedRevision(true)
+	bs, _ := versionedService("remoterev", nic)
+	defer nic.Resources().Services().DeActivate("remoterev", 0, nic.Resources(), nic)
+	bs.Post(object.New(nil, &testtypes.TestProto{MyString: "rr"}), nic)
+
+	client := topo.VnicByVnetNum(2, 1)
+	uuid := nic.Resources().SysConfig().LocalUuid
+	filter, _ := base.Revisioned(&testtypes.TestProto{MyString: "rr"}, 0)
+	resp := client.Request(uuid, "remoterev", 0, ifs.GET, filter, 5)
+	if resp == nil || resp.Error() != nil {
+		Log.Fail(t, "Expected the remote GET to succeed")
+		return
+	}
+	read, ok := resp.Element().(*l8svcs.L8Revisioned)
+	if !ok || read.Revision != 1 {
+		Log.Fail(t, "Expected the remote GET to return the element at revision 1")
+		return
+	}
+
+	write, _ := base.Revisioned(&testtypes.TestProto{MyString: "rr", MyInt32: 8}, read.Revision)
+	resp = client.Request(uuid, "remoterev", 0, ifs.PUT, write, 5)
+	if resp == nil || resp.Error() != nil {
+		Log.Fail(t, "Expected the remote write of the current revision to succeed")
+		return
+	}
+	resp = client.Request(uuid, "remoterev", 0, ifs.PUT, write, 5)
+	if resp == nil || !base.IsConflict(resp.Error()) {
+		Log.Fail(t, "Expected the remote write of an old revision to be rejected with a conflict")
+		return
+	}
+	if bs.Revision(&testtypes.TestProto{MyString: "rr"}, nic.Resources()) != 2 {
+		Log.Fail(t, "Expected the element to be at revision 2")
+		return
+	}
+}
+
+func TestAgreementDeactivate(t *testing.T) {
+	defer reset("TestAgreementDeactivate")
+	nic := topo.VnicByVnetNum(1, 1)
+	_, sc := versionedService("forgotten", nic)
+	agreement.Of(sc, nic.Resources()).SetVersionField("MyInt32")
+	if agreement.For(topo.VnicByVnetNum(1, 2).Resources(), "forgotten", 0).VersionField() != "" {
+		Log.Fail(t, "Expected the agreement of another node to be kept apart")
+		return
+	}
+	nic.Resources().Services().DeActivate("forgotten", 0, nic.Resources(), nic)
+	if agreement.For(nic.Resources(), "forgotten", 0).VersionField() != "" {
+		Log.Fail(t, "Expected the agreement to be dropped when the service is deactivated")
+		return
+	}
+}
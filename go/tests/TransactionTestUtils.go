@@ -14,9 +14,13 @@
 package tests
 
 import (
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/saichler/l8services/go/services/agreement"
+	"github.com/saichler/l8services/go/services/base"
+	"github.com/saichler/l8services/go/services/manager"
 	. "github.com/saichler/l8test/go/infra/t_resources"
 	. "github.com/saichler/l8test/go/infra/t_service"
 	"github.com/saichler/l8types/go/ifs"
@@ -145,3 +149,59 @@ func waitForElection(t *testing.T) bool {
 	}
 	return true
 }
+
+// activateOnAll activates the service of the agreement on all the nodes of the topology.
+func activateOnAll(sla *ifs.ServiceLevelAgreement) {
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			base.Activate(sla, topo.VnicByVnetNum(vnet, vnic))
+		}
+	}
+}
+
+// agreeOnAll applies a setting to the agreement of a service on all the nodes of the topology.
+func agreeOnAll(serviceName string, serviceArea byte, set func(a *agreement.Agreement)) {
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			set(agreement.For(topo.VnicByVnetNum(vnet, vnic).Resources(), serviceName, serviceArea))
+		}
+	}
+}
+
+// leaderVnic returns the node of the topology leading a service, or nil if there is none.
+func leaderVnic(serviceName string, serviceArea byte) ifs.IVNic {
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic := topo.VnicByVnetNum(vnet, vnic)
+			if nic.Resources().Services().GetLeader(serviceName, serviceArea) == nic.Resources().SysConfig().LocalUuid {
+				return nic
+			}
+		}
+	}
+	return nil
+}
+
+// leadWith makes the given node the leader of a service too, e.g. to lead several
+// services from the same node.
+func leadWith(serviceName string, serviceArea byte, nic ifs.IVNic) error {
+	leader := leaderVnic(serviceName, serviceArea)
+	if leader == nil {
+		return errors.New("No leader for " + serviceName)
+	}
+	if leader == nic {
+		return nil
+	}
+	_, err := leader.Resources().Services().(*manager.ServiceManager).TransferLeadership(serviceName, serviceArea,
+		nic.Resources().SysConfig().LocalUuid, leader)
+	return err
+}
+
+// deactivateOnAll deactivates a service on all the nodes of the topology.
+func deactivateOnAll(serviceName string, serviceArea byte) {
+	for vnet := 1; vnet <= 3; vnet++ {
+		for vnic := 1; vnic <= 3; vnic++ {
+			nic := topo.VnicByVnetNum(vnet, vnic)
+			nic.Resources().Services().DeActivate(serviceName, serviceArea, nic.Resources(), nic)
+		}
+	}
+}